@@ -3,9 +3,12 @@ module github.com/fe-dudu/netmon
 go 1.25
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/gdamore/tcell/v2 v2.8.1
 	github.com/google/gopacket v1.1.19
 	github.com/rivo/tview v0.42.0
+	golang.org/x/net v0.25.0
+	golang.org/x/sys v0.29.0
 )
 
 require (
@@ -13,7 +16,6 @@ require (
 	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
 	github.com/mattn/go-runewidth v0.0.16 // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
-	golang.org/x/sys v0.29.0 // indirect
 	golang.org/x/term v0.28.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
 )
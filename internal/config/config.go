@@ -0,0 +1,224 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+type TabConfig struct {
+	Label  string   `toml:"label"`
+	Desc   string   `toml:"desc"`
+	Protos []string `toml:"protos"`
+	Ports  []int    `toml:"ports"`
+	Hosts  []string `toml:"hosts"`
+}
+
+type OutputConfig struct {
+	Type string `toml:"type"`
+	Path string `toml:"path"`
+}
+
+type Settings struct {
+	Interfaces      []string          `toml:"interfaces"`
+	IncludeLoopback *bool             `toml:"include_loopback"`
+	IncludeVPN      *bool             `toml:"include_vpn"`
+	CaptureFilter   *string           `toml:"capture_filter"`
+	User            *string           `toml:"user"`
+	Backend         *string           `toml:"backend"`
+	Fanout          *int              `toml:"fanout"`
+	RingMB          *int              `toml:"ring_mb"`
+	Workers         *int              `toml:"workers"`
+	RedactQueries   *bool             `toml:"redact_queries"`
+	BufferSize      *int              `toml:"buffer_size"`
+	DefaultTab      *string           `toml:"default_tab"`
+	DisplayMode     *string           `toml:"display_mode"`
+	Colors          map[string]string `toml:"colors"`
+	Tabs            []TabConfig       `toml:"tabs"`
+	Outputs         []OutputConfig    `toml:"outputs"`
+}
+
+type Config struct {
+	Settings
+	Profiles map[string]Settings `toml:"profiles"`
+}
+
+func DefaultPath() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "netmon", "config.toml")
+	}
+	home, err := os.UserHomeDir()
+	if sudoUser := os.Getenv("SUDO_USER"); sudoUser != "" {
+		if u, lookupErr := user.Lookup(sudoUser); lookupErr == nil {
+			home, err = u.HomeDir, nil
+		}
+	}
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "netmon", "config.toml")
+}
+
+func Load(path string) (*Config, error) {
+	explicit := path != ""
+	if !explicit {
+		path = DefaultPath()
+	}
+
+	cfg := &Config{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	md, err := toml.DecodeFile(path, cfg)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return &Config{}, nil
+		}
+		return nil, err
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, 0, len(undecoded))
+		for _, k := range undecoded {
+			keys = append(keys, k.String())
+		}
+		return nil, fmt.Errorf("%s: unknown keys: %s", path, strings.Join(keys, ", "))
+	}
+	return cfg, nil
+}
+
+func (c *Config) Resolve(profile string) (Settings, error) {
+	if profile == "" {
+		return c.Settings, nil
+	}
+
+	p, ok := c.Profiles[profile]
+	if !ok {
+		names := make([]string, 0, len(c.Profiles))
+		for name := range c.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return Settings{}, fmt.Errorf("profile %q not found (available: %s)", profile, strings.Join(names, ", "))
+	}
+	return merge(c.Settings, p), nil
+}
+
+func merge(base, over Settings) Settings {
+	out := base
+	if len(over.Interfaces) > 0 {
+		out.Interfaces = over.Interfaces
+	}
+	if over.IncludeLoopback != nil {
+		out.IncludeLoopback = over.IncludeLoopback
+	}
+	if over.IncludeVPN != nil {
+		out.IncludeVPN = over.IncludeVPN
+	}
+	if over.CaptureFilter != nil {
+		out.CaptureFilter = over.CaptureFilter
+	}
+	if over.User != nil {
+		out.User = over.User
+	}
+	if over.Backend != nil {
+		out.Backend = over.Backend
+	}
+	if over.Fanout != nil {
+		out.Fanout = over.Fanout
+	}
+	if over.RingMB != nil {
+		out.RingMB = over.RingMB
+	}
+	if over.Workers != nil {
+		out.Workers = over.Workers
+	}
+	if over.RedactQueries != nil {
+		out.RedactQueries = over.RedactQueries
+	}
+	if over.BufferSize != nil {
+		out.BufferSize = over.BufferSize
+	}
+	if over.DefaultTab != nil {
+		out.DefaultTab = over.DefaultTab
+	}
+	if over.DisplayMode != nil {
+		out.DisplayMode = over.DisplayMode
+	}
+	if len(over.Colors) > 0 {
+		out.Colors = make(map[string]string, len(base.Colors)+len(over.Colors))
+		for k, v := range base.Colors {
+			out.Colors[k] = v
+		}
+		for k, v := range over.Colors {
+			out.Colors[k] = v
+		}
+	}
+	if len(over.Tabs) > 0 {
+		out.Tabs = append(append([]TabConfig{}, base.Tabs...), over.Tabs...)
+	}
+	if len(over.Outputs) > 0 {
+		out.Outputs = over.Outputs
+	}
+	return out
+}
+
+// ApplyFlags fills the flags left unset on the command line from the
+// settings, so explicit flags take precedence over the config file.
+func (s Settings) ApplyFlags(fs *flag.FlagSet) error {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	values := make(map[string]string)
+	if s.IncludeLoopback != nil {
+		values["include-loopback"] = strconv.FormatBool(*s.IncludeLoopback)
+	}
+	if s.IncludeVPN != nil {
+		values["include-vpn"] = strconv.FormatBool(*s.IncludeVPN)
+	}
+	if len(s.Interfaces) > 0 {
+		values["i"] = strings.Join(s.Interfaces, ",")
+	}
+	if s.User != nil {
+		values["user"] = *s.User
+	}
+	if s.Backend != nil {
+		values["backend"] = *s.Backend
+	}
+	if s.Fanout != nil {
+		values["fanout"] = strconv.Itoa(*s.Fanout)
+	}
+	if s.RingMB != nil {
+		values["ring-mb"] = strconv.Itoa(*s.RingMB)
+	}
+	if s.Workers != nil {
+		values["workers"] = strconv.Itoa(*s.Workers)
+	}
+	if s.RedactQueries != nil {
+		values["redact-queries"] = strconv.FormatBool(*s.RedactQueries)
+	}
+	if s.CaptureFilter != nil {
+		values["capture-filter"] = *s.CaptureFilter
+	}
+
+	for name, value := range values {
+		if explicit[name] || fs.Lookup(name) == nil {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestResolve(t *testing.T) {
+	cfg := &Config{
+		Settings: Settings{
+			Interfaces:    []string{"eth0"},
+			Workers:       ptr(4),
+			RedactQueries: ptr(false),
+			Colors:        map[string]string{"DNS": "green", "TLS": "red"},
+			Tabs:          []TabConfig{{Label: "Web", Ports: []int{80, 443}}},
+			Outputs:       []OutputConfig{{Type: "jsonl", Path: "base.jsonl"}},
+		},
+		Profiles: map[string]Settings{
+			"lab": {
+				Interfaces:    []string{"eth1", "eth2"},
+				RedactQueries: ptr(true),
+				Colors:        map[string]string{"TLS": "blue"},
+				Tabs:          []TabConfig{{Label: "DB", Ports: []int{5432}}},
+			},
+			"empty": {},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		profile string
+		want    Settings
+		err     string
+	}{
+		{name: "no profile", want: cfg.Settings},
+		{name: "empty profile keeps base", profile: "empty", want: cfg.Settings},
+		{
+			name:    "profile overrides",
+			profile: "lab",
+			want: Settings{
+				Interfaces:    []string{"eth1", "eth2"},
+				Workers:       ptr(4),
+				RedactQueries: ptr(true),
+				Colors:        map[string]string{"DNS": "green", "TLS": "blue"},
+				Tabs:          []TabConfig{{Label: "Web", Ports: []int{80, 443}}, {Label: "DB", Ports: []int{5432}}},
+				Outputs:       []OutputConfig{{Type: "jsonl", Path: "base.jsonl"}},
+			},
+		},
+		{name: "unknown profile", profile: "prod", err: `profile "prod" not found (available: empty, lab)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cfg.Resolve(tt.profile)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Resolve(%q) = %+v, want %+v", tt.profile, got, tt.want)
+			}
+		})
+	}
+	if cfg.Colors["TLS"] != "red" {
+		t.Error("merging a profile changed the base colors")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		toml string
+		err  string
+	}{
+		{name: "valid", toml: "workers = 2\n[profiles.lab]\ninterfaces = [\"eth1\"]\n"},
+		{name: "unknown top-level key", toml: "workers = 2\nworker = 3\n", err: "unknown keys: worker"},
+		{name: "unknown profile key", toml: "[profiles.lab]\nring = 8\n", err: "unknown keys: profiles.lab.ring"},
+		{name: "wrong type", toml: "workers = \"two\"\n", err: "workers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			if err := os.WriteFile(path, []byte(tt.toml), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Load(path)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want it to mention %q", err, tt.err)
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.toml")); err == nil {
+		t.Error("an explicit missing config file loaded without error")
+	}
+}
+
+func TestApplyFlags(t *testing.T) {
+	settings := Settings{
+		Interfaces:    []string{"eth0", "eth1"},
+		Workers:       ptr(8),
+		RedactQueries: ptr(true),
+		CaptureFilter: ptr("tcp"),
+		Backend:       ptr("afpacket"),
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want map[string]string
+	}{
+		{
+			name: "config fills unset flags",
+			want: map[string]string{"i": "eth0,eth1", "workers": "8", "redact-queries": "true", "capture-filter": "tcp", "backend": "afpacket", "fanout": "0"},
+		},
+		{
+			name: "explicit flags win",
+			args: []string{"-i", "wlan0", "-workers", "2", "-redact-queries=false"},
+			want: map[string]string{"i": "wlan0", "workers": "2", "redact-queries": "false", "capture-filter": "tcp"},
+		},
+		{
+			name: "explicit default value still wins",
+			args: []string{"-capture-filter", "", "-backend", "pcap"},
+			want: map[string]string{"capture-filter": "", "backend": "pcap", "workers": "8"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("netmon", flag.ContinueOnError)
+			fs.String("i", "", "")
+			fs.Int("workers", 0, "")
+			fs.Bool("redact-queries", false, "")
+			fs.String("capture-filter", "ip", "")
+			fs.String("backend", "pcap", "")
+			fs.Int("fanout", 0, "")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if err := settings.ApplyFlags(fs); err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.want {
+				if got := fs.Lookup(name).Value.String(); got != want {
+					t.Errorf("-%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package network
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fe-dudu/netmon/internal/types"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/afpacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	afpacketFrameSize = 1 << 16
+	afpacketBlockSize = 1 << 20
+)
+
+// dltRaw is DLT_RAW as libpcap reports it on Linux for tun devices; BPF
+// compilation does not accept LINKTYPE_RAW (101).
+const dltRaw = layers.LinkType(12)
+
+var fanoutSeq uint32
+
+type afpacketSource struct {
+	name      string
+	linkType  layers.LinkType
+	rings     []*afpacketRing
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// afpacketRing is one fanout socket. mu keeps Close from unmapping the ring
+// while a read is in progress.
+type afpacketRing struct {
+	tp     *afpacket.TPacket
+	done   <-chan struct{}
+	mu     sync.Mutex
+	closed bool
+}
+
+func openAFPacket(iface string, opts types.CaptureOptions) (PacketSource, error) {
+	linkType, err := afpacketLinkType(iface)
+	if err != nil {
+		return nil, err
+	}
+	ringMB := opts.RingMB
+	if ringMB <= 0 {
+		ringMB = 64
+	}
+	sockets := opts.Fanout
+	if sockets < 1 {
+		sockets = 1
+	}
+	blocks := ringMB * (1 << 20) / afpacketBlockSize / sockets
+	if blocks < 1 {
+		blocks = 1
+	}
+	fanoutID := uint16(os.Getpid()) ^ uint16(atomic.AddUint32(&fanoutSeq, 1))
+
+	s := &afpacketSource{
+		name:     iface,
+		linkType: linkType,
+		done:     make(chan struct{}),
+	}
+	for i := 0; i < sockets; i++ {
+		tp, err := afpacket.NewTPacket(
+			afpacket.OptInterface(iface),
+			afpacket.OptFrameSize(afpacketFrameSize),
+			afpacket.OptBlockSize(afpacketBlockSize),
+			afpacket.OptNumBlocks(blocks),
+			afpacket.OptPollTimeout(200*time.Millisecond),
+			afpacket.TPacketVersion3,
+		)
+		if err != nil {
+			s.closeRings()
+			return nil, err
+		}
+		s.rings = append(s.rings, &afpacketRing{tp: tp, done: s.done})
+		if sockets > 1 {
+			if err := tp.SetFanout(afpacket.FanoutHashWithDefrag, fanoutID); err != nil {
+				s.closeRings()
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// afpacketLinkType maps the interface's ARPHRD type to the framing a raw
+// packet socket delivers, as libpcap does. tun, WireGuard and PPP links
+// carry bare IP packets.
+func afpacketLinkType(iface string) (layers.LinkType, error) {
+	raw, err := os.ReadFile(filepath.Join("/sys/class/net", iface, "type"))
+	if err != nil {
+		return 0, fmt.Errorf("%s: link type: %w", iface, err)
+	}
+	arphrd, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return 0, fmt.Errorf("%s: link type %q: %w", iface, raw, err)
+	}
+	switch arphrd {
+	case unix.ARPHRD_ETHER, unix.ARPHRD_LOOPBACK:
+		return layers.LinkTypeEthernet, nil
+	case unix.ARPHRD_NONE, unix.ARPHRD_PPP, unix.ARPHRD_RAWIP,
+		unix.ARPHRD_TUNNEL, unix.ARPHRD_TUNNEL6, unix.ARPHRD_SIT:
+		return dltRaw, nil
+	}
+	return 0, fmt.Errorf("%s: ARPHRD type %d is not supported by the %s backend, use --backend pcap", iface, arphrd, BackendAFPacket)
+}
+
+func (r *afpacketRing) ReadPacketData() ([]byte, gopacket.CaptureInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for !r.closed {
+		data, ci, err := r.tp.ReadPacketData()
+		if err == afpacket.ErrTimeout {
+			select {
+			case <-r.done:
+				return nil, gopacket.CaptureInfo{}, io.EOF
+			default:
+				continue
+			}
+		}
+		return data, ci, err
+	}
+	return nil, gopacket.CaptureInfo{}, io.EOF
+}
+
+func (r *afpacketRing) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.closed {
+		r.closed = true
+		r.tp.Close()
+	}
+}
+
+func (s *afpacketSource) Name() string {
+	return s.name
+}
+
+func (s *afpacketSource) LinkType() layers.LinkType {
+	return s.linkType
+}
+
+func (s *afpacketSource) Stats() (types.SourceStats, error) {
+	var stats types.SourceStats
+	rings, err := s.RingStats()
+	for _, st := range rings {
+		stats.Received += st.Received
+		stats.Dropped += st.Dropped
+	}
+	return stats, err
+}
+
+func (s *afpacketSource) RingStats() ([]types.SourceStats, error) {
+	stats := make([]types.SourceStats, 0, len(s.rings))
+	for _, r := range s.rings {
+		_, v3, err := r.tp.SocketStats()
+		if err != nil {
+			return stats, err
+		}
+		stats = append(stats, types.SourceStats{Received: uint64(v3.Packets()), Dropped: uint64(v3.Drops())})
+	}
+	return stats, nil
+}
+
+// Rings lets startReader drain every fanout socket from its own goroutine.
+func (s *afpacketSource) Rings() []types.PacketReader {
+	readers := make([]types.PacketReader, len(s.rings))
+	for i, r := range s.rings {
+		readers[i] = r
+	}
+	return readers
+}
+
+// ReadPacketData reads only the first ring; with fanout, use Rings.
+func (s *afpacketSource) ReadPacketData() ([]byte, gopacket.CaptureInfo, error) {
+	return s.rings[0].ReadPacketData()
+}
+
+func (s *afpacketSource) SetFilter(expr string) error {
+	insns, err := pcap.CompileBPFFilter(s.LinkType(), afpacketFrameSize, expr)
+	if err != nil {
+		return err
+	}
+	raw := make([]bpf.RawInstruction, len(insns))
+	for i, ins := range insns {
+		raw[i] = bpf.RawInstruction{Op: ins.Code, Jt: ins.Jt, Jf: ins.Jf, K: ins.K}
+	}
+	for _, r := range s.rings {
+		if err := r.tp.SetBPF(raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *afpacketSource) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.closeRings()
+	})
+}
+
+func (s *afpacketSource) closeRings() {
+	for _, r := range s.rings {
+		r.close()
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package network
+
+import (
+	"fmt"
+
+	"github.com/fe-dudu/netmon/internal/types"
+)
+
+func openAFPacket(iface string, opts types.CaptureOptions) (PacketSource, error) {
+	return nil, fmt.Errorf("%s backend is only available on Linux", BackendAFPacket)
+}
@@ -0,0 +1,31 @@
+package network
+
+import (
+	"bytes"
+	_ "embed"
+	"io"
+
+	"github.com/google/gopacket/pcapgo"
+)
+
+//go:embed demo.pcap
+var demoCapture []byte
+
+// DemoFrames returns the frames --demo replays.
+func DemoFrames() ([][]byte, error) {
+	r, err := pcapgo.NewReader(bytes.NewReader(demoCapture))
+	if err != nil {
+		return nil, err
+	}
+	var frames [][]byte
+	for {
+		data, _, err := r.ReadPacketData()
+		if err == io.EOF {
+			return frames, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, data)
+	}
+}
@@ -0,0 +1,56 @@
+package network
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+var update = flag.Bool("update", false, "rewrite demo.pcap from sampleFrames")
+
+// demoEpoch is when the demo capture was recorded; timestamps inside
+// payloads, such as NTP's, are relative to it.
+var demoEpoch = time.Unix(1700000000, 0)
+
+func TestDemoCapture(t *testing.T) {
+	want := sampleFrames()
+	if *update {
+		var buf bytes.Buffer
+		w := pcapgo.NewWriter(&buf)
+		if err := w.WriteFileHeader(65536, layers.LinkTypeEthernet); err != nil {
+			t.Fatal(err)
+		}
+		for i, frame := range want {
+			ci := gopacket.CaptureInfo{
+				Timestamp:     demoEpoch.Add(time.Duration(i) * time.Millisecond),
+				CaptureLength: len(frame), Length: len(frame),
+			}
+			if err := w.WritePacket(ci, frame); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.WriteFile("demo.pcap", buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	got, err := DemoFrames()
+	if err != nil {
+		t.Fatalf("DemoFrames: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("demo.pcap has %d frames, sampleFrames %d; run with -update", len(got), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(got[i], want[i]) {
+			t.Fatalf("frame %d differs from sampleFrames; run with -update", i)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package network
+
+import (
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/fe-dudu/netmon/internal/types"
+)
+
+const (
+	maxNeighbors     = 1024
+	maxNeighborItems = 16
+)
+
+// trackNeighbor records hosts that announce themselves with mDNS, SSDP,
+// LLMNR or NBNS, keyed by their source address.
+func trackNeighbor(a *types.App, pkt *types.PacketInfo, info *types.DiscoveryInfo) {
+	if (!info.Announce && !info.Bye) || !pkt.SrcAddr.IsValid() || pkt.SrcAddr.IsUnspecified() {
+		return
+	}
+
+	a.NeighborsMutex.Lock()
+	defer a.NeighborsMutex.Unlock()
+
+	if a.Neighbors == nil {
+		a.Neighbors = make(map[netip.Addr]*types.Neighbor)
+	}
+	n, ok := a.Neighbors[pkt.SrcAddr]
+	if !ok {
+		if info.Bye {
+			return
+		}
+		if len(a.Neighbors) >= maxNeighbors {
+			evictOldestNeighbor(a)
+		}
+		n = &types.Neighbor{Addr: pkt.SrcAddr, First: pkt.Timestamp}
+		a.Neighbors[pkt.SrcAddr] = n
+	}
+
+	n.Last = pkt.Timestamp
+	n.Gone = info.Bye
+	if !pkt.SrcMAC.IsZero() {
+		n.MAC = pkt.SrcMAC
+	}
+	n.Protocols = mergeNeighborItems(n.Protocols, pkt.Proto)
+	n.Names = mergeNeighborItems(n.Names, info.Names...)
+	n.Services = mergeNeighborItems(n.Services, info.Services...)
+	if info.Model != "" {
+		n.Model = info.Model
+	}
+	if info.Server != "" {
+		n.Server = info.Server
+	}
+	if info.Location != "" {
+		n.Location = info.Location
+	}
+}
+
+func mergeNeighborItems(list []string, items ...string) []string {
+	for _, item := range items {
+		if item == "" || len(list) >= maxNeighborItems {
+			continue
+		}
+		if !slices.ContainsFunc(list, func(s string) bool { return strings.EqualFold(s, item) }) {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func evictOldestNeighbor(a *types.App) {
+	var oldest *types.Neighbor
+	for _, n := range a.Neighbors {
+		if oldest == nil || n.Last.Before(oldest.Last) {
+			oldest = n
+		}
+	}
+	delete(a.Neighbors, oldest.Addr)
+}
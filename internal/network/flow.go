@@ -0,0 +1,120 @@
+package network
+
+import (
+	"net/netip"
+	"sort"
+
+	"github.com/fe-dudu/netmon/internal/types"
+)
+
+const maxFlows = 4096
+
+func flowKey(pkt *types.PacketInfo) (types.FlowKey, netip.AddrPort, netip.AddrPort) {
+	src := netip.AddrPortFrom(pkt.SrcAddr, pkt.SrcPort)
+	dst := netip.AddrPortFrom(pkt.DstAddr, pkt.DstPort)
+	key := types.FlowKey{L4Proto: pkt.L4Proto, A: src, B: dst}
+	if dst.Compare(src) < 0 {
+		key.A, key.B = dst, src
+	}
+	return key, src, dst
+}
+
+// trackFlow accounts the packet to its TCP/UDP flow. The caller must hold
+// a.FlowsMutex.
+func trackFlow(a *types.App, pkt *types.PacketInfo) *types.Flow {
+	if !pkt.HasPorts || !pkt.SrcAddr.IsValid() {
+		return nil
+	}
+	if a.Flows == nil {
+		a.Flows = make(map[types.FlowKey]*types.Flow)
+	}
+
+	key, src, dst := flowKey(pkt)
+	f, ok := a.Flows[key]
+	if !ok {
+		if len(a.Flows) >= maxFlows {
+			evictFlows(a)
+		}
+		f = &types.Flow{L4Proto: pkt.L4Proto, Client: src, Server: dst, First: pkt.Timestamp}
+		// A SYN/ACK comes from the server; otherwise assume the side on the
+		// lower port is the server unless this is the opening SYN.
+		switch {
+		case pkt.TCPFlags&types.TCPFlagSYN != 0 && pkt.TCPFlags&types.TCPFlagACK != 0:
+			f.Client, f.Server = dst, src
+		case pkt.TCPFlags&types.TCPFlagSYN != 0:
+		case src.Port() < dst.Port():
+			f.Client, f.Server = dst, src
+		}
+		if pkt.L4Proto == types.IPProtoTCP {
+			f.State = "open"
+		}
+		a.Flows[key] = f
+	}
+
+	f.Packets++
+	f.Last = pkt.Timestamp
+	if src == f.Client {
+		f.ClientBytes += pkt.Length
+	} else {
+		f.ServerBytes += pkt.Length
+	}
+	if f.Proto == "" || f.Proto == "TCP" || f.Proto == "UDP" {
+		f.Proto = pkt.Proto
+	}
+	switch {
+	case pkt.TCPFlags&types.TCPFlagRST != 0:
+		f.State = "reset"
+	case pkt.TCPFlags&types.TCPFlagFIN != 0:
+		f.State = "closed"
+	case pkt.TCPFlags&types.TCPFlagSYN != 0 && f.State != "open":
+		f.State = "open"
+	}
+	return f
+}
+
+// flowDir returns 0 for packets from the flow's client and 1 for packets
+// from its server.
+func flowDir(f *types.Flow, pkt *types.PacketInfo) int {
+	if netip.AddrPortFrom(pkt.SrcAddr, pkt.SrcPort) != f.Client {
+		return 1
+	}
+	return 0
+}
+
+// evictFlows drops the least recently active quarter of the flow table.
+func evictFlows(a *types.App) {
+	flows := make([]types.FlowKey, 0, len(a.Flows))
+	for key := range a.Flows {
+		flows = append(flows, key)
+	}
+	sort.Slice(flows, func(i, j int) bool {
+		return a.Flows[flows[i]].Last.Before(a.Flows[flows[j]].Last)
+	})
+	for _, key := range flows[:len(flows)/4+1] {
+		delete(a.Flows, key)
+	}
+}
+
+func trackSSH(f *types.Flow, pkt *types.PacketInfo, ssh *types.SSHInfo) {
+	if f == nil {
+		return
+	}
+	if f.SSH == nil {
+		f.SSH = &types.SSHSession{}
+	}
+	fromClient := netip.AddrPortFrom(pkt.SrcAddr, pkt.SrcPort) == f.Client
+	if ssh.Banner != "" {
+		if fromClient {
+			f.SSH.ClientBanner = ssh.Banner
+		} else {
+			f.SSH.ServerBanner = ssh.Banner
+		}
+	}
+	if ssh.KexInit != nil {
+		if fromClient {
+			f.SSH.ClientKex = ssh.KexInit
+		} else {
+			f.SSH.ServerKex = ssh.KexInit
+		}
+	}
+}
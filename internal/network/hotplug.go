@@ -0,0 +1,100 @@
+package network
+
+import (
+	"net"
+	"time"
+
+	"github.com/fe-dudu/netmon/internal/types"
+	"github.com/google/gopacket/pcap"
+)
+
+type linkChange struct {
+	Name string
+	Up   bool
+}
+
+func WatchInterfaces(a *types.App) {
+	changes := make(chan linkChange, 16)
+
+	a.Wg.Add(2)
+	go func() {
+		defer a.Wg.Done()
+		watchLinks(a.StopCh, changes)
+	}()
+
+	go func() {
+		defer a.Wg.Done()
+		ticker := time.NewTicker(3 * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-a.StopCh:
+				return
+			case ch := <-changes:
+				handleLinkChange(a, ch)
+			case <-ticker.C:
+				Reconcile(a)
+			}
+		}
+	}()
+}
+
+func handleLinkChange(a *types.App, ch linkChange) {
+	if ch.Up {
+		RecordEvent(a, ch.Name, "link up")
+		Reconcile(a)
+		return
+	}
+
+	RecordEvent(a, ch.Name, "link down")
+	a.CapturesMutex.Lock()
+	defer a.CapturesMutex.Unlock()
+	closeCapture(a, ch.Name)
+}
+
+func Reconcile(a *types.App) {
+	devices, err := pcap.FindAllDevs()
+	if err != nil {
+		return
+	}
+
+	a.CapturesMutex.Lock()
+	defer a.CapturesMutex.Unlock()
+
+	a.Devices = devices
+	auto := ActiveInterfaces(devices, a.IfaceOpts)
+	a.AutoIfaces = make(map[string]bool, len(auto))
+	for _, iface := range auto {
+		a.AutoIfaces[iface.Name] = true
+		if _, seen := a.WantedIfaces[iface.Name]; !seen && a.AutoSelect {
+			a.WantedIfaces[iface.Name] = true
+		}
+	}
+
+	for _, dev := range devices {
+		if !a.WantedIfaces[dev.Name] || !linkUp(dev.Name) {
+			continue
+		}
+		if _, ok := a.Captures[dev.Name]; ok {
+			continue
+		}
+		if err := openCapture(a, dev); err != nil {
+			if a.CaptureErrors[dev.Name] != err.Error() {
+				a.CaptureErrors[dev.Name] = err.Error()
+				RecordEvent(a, dev.Name, "open failed: "+err.Error())
+			}
+			continue
+		}
+		RecordEvent(a, dev.Name, "capture started")
+	}
+}
+
+func linkUp(name string) bool {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		// pcap pseudo-devices such as "any" have no kernel link state.
+		return true
+	}
+	return iface.Flags&net.FlagUp != 0 && iface.Flags&net.FlagRunning != 0
+}
@@ -0,0 +1,86 @@
+package network
+
+import (
+	"bytes"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func watchLinks(stop <-chan struct{}, out chan<- linkChange) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		pollLinks(stop, out)
+		return
+	}
+	defer unix.Close(fd)
+
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: unix.RTMGRP_LINK}); err != nil {
+		pollLinks(stop, out)
+		return
+	}
+	// A receive timeout lets the loop notice stop without closing the socket under Recvfrom.
+	tv := unix.Timeval{Sec: 1}
+	_ = unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv)
+
+	state := linkStates()
+	buf := make([]byte, 65536)
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil || n < unix.NLMSG_HDRLEN {
+			continue
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			continue
+		}
+		for i := range msgs {
+			ch, ok := parseLinkMessage(&msgs[i])
+			if !ok {
+				continue
+			}
+			if prev, seen := state[ch.Name]; seen && prev == ch.Up {
+				continue
+			}
+			state[ch.Name] = ch.Up
+			select {
+			case out <- ch:
+			case <-stop:
+				return
+			}
+		}
+	}
+}
+
+func parseLinkMessage(m *syscall.NetlinkMessage) (linkChange, bool) {
+	if m.Header.Type != unix.RTM_NEWLINK && m.Header.Type != unix.RTM_DELLINK {
+		return linkChange{}, false
+	}
+	if len(m.Data) < unix.SizeofIfInfomsg {
+		return linkChange{}, false
+	}
+	info := (*unix.IfInfomsg)(unsafe.Pointer(&m.Data[0]))
+
+	attrs, err := syscall.ParseNetlinkRouteAttr(m)
+	if err != nil {
+		return linkChange{}, false
+	}
+	for _, attr := range attrs {
+		if attr.Attr.Type != unix.IFLA_IFNAME {
+			continue
+		}
+		name := string(bytes.TrimRight(attr.Value, "\x00"))
+		up := m.Header.Type == unix.RTM_NEWLINK &&
+			info.Flags&unix.IFF_UP != 0 &&
+			info.Flags&unix.IFF_RUNNING != 0
+		return linkChange{Name: name, Up: up}, true
+	}
+	return linkChange{}, false
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package network
+
+func watchLinks(stop <-chan struct{}, out chan<- linkChange) {
+	pollLinks(stop, out)
+}
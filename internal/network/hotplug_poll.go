@@ -0,0 +1,55 @@
+package network
+
+import (
+	"net"
+	"time"
+)
+
+func pollLinks(stop <-chan struct{}, out chan<- linkChange) {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	state := linkStates()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		current := linkStates()
+		for name, up := range current {
+			if prev, seen := state[name]; seen && prev == up {
+				continue
+			}
+			select {
+			case out <- linkChange{Name: name, Up: up}:
+			case <-stop:
+				return
+			}
+		}
+		for name, up := range state {
+			if _, ok := current[name]; ok || !up {
+				continue
+			}
+			select {
+			case out <- linkChange{Name: name, Up: false}:
+			case <-stop:
+				return
+			}
+		}
+		state = current
+	}
+}
+
+func linkStates() map[string]bool {
+	states := make(map[string]bool)
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return states
+	}
+	for _, iface := range ifaces {
+		states[iface.Name] = iface.Flags&net.FlagUp != 0 && iface.Flags&net.FlagRunning != 0
+	}
+	return states
+}
@@ -0,0 +1,224 @@
+package network
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/http2/hpack"
+
+	"github.com/fe-dudu/netmon/internal/packet"
+	"github.com/fe-dudu/netmon/internal/types"
+)
+
+const (
+	maxHTTP2Streams   = 256
+	maxHTTP2HeaderLen = 64 << 10
+	maxHeaderValueLen = 120
+)
+
+// trackHTTP2 decodes header blocks with the connection's HPACK state, which
+// only works in capture order, and rewrites the packet's detail.
+func trackHTTP2(f *types.Flow, pkt *types.PacketInfo, info *types.HTTP2Info) {
+	if f == nil {
+		return
+	}
+	if f.HTTP2 == nil {
+		f.HTTP2 = &types.HTTP2Session{Streams: make(map[uint32]*types.HTTP2Stream)}
+	}
+	s := f.HTTP2
+	dir := flowDir(f, pkt)
+	if s.Cut[dir].Missing > 0 {
+		// A new frame started where the rest of the cut one was expected.
+		lostHTTP2Block(s, dir)
+	}
+	describeHTTP2(f, pkt, dir, info)
+}
+
+// trackHTTP2Segment collects the rest of a header frame cut at the end of
+// an earlier segment. Frames that follow it are decoded as well.
+func trackHTTP2Segment(f *types.Flow, pkt *types.PacketInfo, payload []byte) {
+	s := f.HTTP2
+	dir := flowDir(f, pkt)
+	cut := &s.Cut[dir]
+	if cut.Missing == 0 || len(payload) == 0 {
+		return
+	}
+	n := min(len(payload), cut.Missing)
+	cut.Block = append(cut.Block, payload[:n]...)
+	cut.Missing -= n
+	pkt.Proto = f.Proto
+	if cut.Missing > 0 {
+		pkt.Detail = fmt.Sprintf("continued s=%d len=%d", cut.StreamID, n)
+		return
+	}
+
+	info := &types.HTTP2Info{Frames: []types.HTTP2Frame{packet.CompleteHTTP2Frame(*cut)}}
+	*cut = types.HTTP2Frame{}
+	if rest, ok := packet.ParseHTTP2(payload[n:]); ok {
+		info.Frames = append(info.Frames, rest.Frames...)
+	}
+	describeHTTP2(f, pkt, dir, info)
+	if pkt.Detail == "" {
+		pkt.Detail = fmt.Sprintf("continued s=%d len=%d", info.Frames[0].StreamID, n)
+	}
+}
+
+func describeHTTP2(f *types.Flow, pkt *types.PacketInfo, dir int, info *types.HTTP2Info) {
+	s := f.HTTP2
+	headers := make(map[int]string)
+	grpc := false
+	for i, fr := range info.Frames {
+		if st := s.Streams[fr.StreamID]; st != nil && st.GRPC {
+			grpc = true
+		}
+		switch fr.Type {
+		case packet.HTTP2Headers, packet.HTTP2PushPromise, packet.HTTP2Continuation:
+		case packet.HTTP2RSTStream:
+			delete(s.Streams, fr.StreamID)
+			continue
+		default:
+			continue
+		}
+		if fr.Missing > 0 {
+			// Decoding waits for the rest of the block; a partial block
+			// would fail and cost the dynamic table.
+			s.Cut[dir] = fr
+			continue
+		}
+		if desc, isGRPC, ok := addHTTP2Block(s, dir, fr); ok {
+			headers[i] = desc
+			grpc = grpc || isGRPC
+		}
+	}
+
+	if len(headers) > 0 {
+		pkt.Detail = packet.DescribeHTTP2(info, headers)
+	}
+	if grpc {
+		pkt.Proto = "gRPC"
+		f.Proto = "gRPC"
+	}
+}
+
+// addHTTP2Block appends a header frame to the pending block and decodes the
+// block once END_HEADERS is seen.
+func addHTTP2Block(s *types.HTTP2Session, dir int, fr types.HTTP2Frame) (string, bool, bool) {
+	if fr.Type == packet.HTTP2Continuation {
+		s.Pending[dir] = append(s.Pending[dir], fr.Block...)
+	} else {
+		s.Head[dir] = fr
+		s.Head[dir].Block = nil
+		s.Pending[dir] = append(s.Pending[dir][:0], fr.Block...)
+	}
+	head := s.Head[dir]
+	if len(s.Pending[dir]) > maxHTTP2HeaderLen {
+		// The dropped block may have changed the dynamic table.
+		lostHTTP2Block(s, dir)
+		return fmt.Sprintf("HEADERS s=%d (hpack state lost)", head.StreamID), false, true
+	}
+	if fr.Flags&packet.HTTP2FlagEndHeaders == 0 {
+		return "", false, false
+	}
+
+	if s.Decoders[dir] == nil {
+		s.Decoders[dir] = hpack.NewDecoder(4096, nil)
+		s.Decoders[dir].SetAllowedMaxDynamicTableSize(1 << 20)
+	}
+	fields, err := s.Decoders[dir].DecodeFull(s.Pending[dir])
+	s.Pending[dir] = s.Pending[dir][:0]
+	if err != nil {
+		// The dynamic table is out of sync (e.g. capture started mid
+		// connection); start over and let later blocks recover.
+		s.Decoders[dir] = nil
+		return fmt.Sprintf("HEADERS s=%d (hpack state lost)", head.StreamID), false, true
+	}
+	desc, isGRPC := describeHeaders(s, head, fields, dir == 0)
+	return desc, isGRPC, true
+}
+
+func lostHTTP2Block(s *types.HTTP2Session, dir int) {
+	s.Pending[dir] = nil
+	s.Cut[dir] = types.HTTP2Frame{}
+	s.Decoders[dir] = nil
+}
+
+func describeHeaders(s *types.HTTP2Session, fr types.HTTP2Frame, fields []hpack.HeaderField, fromClient bool) (string, bool) {
+	get := func(name string) string {
+		for _, hf := range fields {
+			if hf.Name == name {
+				return headerValue(hf.Value)
+			}
+		}
+		return ""
+	}
+
+	push := fr.Type == packet.HTTP2PushPromise
+	if fromClient || push {
+		st := &types.HTTP2Stream{
+			Method:    get(":method"),
+			Path:      get(":path"),
+			Authority: get(":authority"),
+			GRPC:      strings.HasPrefix(get("content-type"), "application/grpc"),
+		}
+		if st.Method == "" && !push {
+			// Client trailers.
+			return fmt.Sprintf("trailers s=%d", fr.StreamID), false
+		}
+		if len(s.Streams) >= maxHTTP2Streams {
+			clear(s.Streams)
+		}
+		if push {
+			// The promised stream carries the pushed response.
+			s.Streams[fr.Promised] = st
+			return fmt.Sprintf("PUSH_PROMISE s=%d %s %s%s promised=%d", fr.StreamID, st.Method, st.Authority, st.Path, fr.Promised), false
+		}
+		s.Streams[fr.StreamID] = st
+		if st.GRPC {
+			return fmt.Sprintf("gRPC %s s=%d %s", strings.TrimPrefix(st.Path, "/"), fr.StreamID, st.Authority), true
+		}
+		return fmt.Sprintf("%s %s%s s=%d", st.Method, st.Authority, st.Path, fr.StreamID), false
+	}
+
+	st := s.Streams[fr.StreamID]
+	var parts []string
+	if status := get(":status"); status != "" {
+		parts = append(parts, "status "+status)
+	}
+	if status := get("grpc-status"); status != "" {
+		text := "grpc-status=" + status
+		if name := packet.GRPCStatusName(status); name != "" {
+			text += " " + name
+		}
+		if msg := get("grpc-message"); msg != "" {
+			text += " (" + msg + ")"
+		}
+		parts = append(parts, text)
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "HEADERS")
+	}
+	parts = append(parts, fmt.Sprintf("s=%d", fr.StreamID))
+	grpc := false
+	if st != nil {
+		grpc = st.GRPC
+		if st.GRPC {
+			parts = append(parts, strings.TrimPrefix(st.Path, "/"))
+		} else {
+			parts = append(parts, st.Method+" "+st.Path)
+		}
+		if fr.Flags&packet.HTTP2FlagEndStream != 0 {
+			delete(s.Streams, fr.StreamID)
+		}
+	}
+	return strings.Join(parts, " "), grpc
+}
+
+func headerValue(v string) string {
+	var b strings.Builder
+	for i := 0; i < len(v) && b.Len() < maxHeaderValueLen; i++ {
+		if c := v[i]; c >= 0x20 && c < 0x7f {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
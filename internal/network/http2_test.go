@@ -0,0 +1,101 @@
+package network
+
+import (
+	"bytes"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2/hpack"
+
+	"github.com/fe-dudu/netmon/internal/packet"
+	"github.com/fe-dudu/netmon/internal/types"
+)
+
+var (
+	h2Client = netip.MustParseAddrPort("192.168.1.10:50600")
+	h2Server = netip.MustParseAddrPort("10.0.5.50:80")
+)
+
+// h2Segment tracks one segment of an h2c connection the way the pipeline
+// hands it over: as frames when it starts on one, as a raw segment if not.
+func h2Segment(a *types.App, fromClient bool, payload []byte) types.PacketInfo {
+	src, dst := h2Client, h2Server
+	if !fromClient {
+		src, dst = dst, src
+	}
+	pkt := types.PacketInfo{
+		Timestamp: time.Unix(1700000000, 0), Proto: "TCP",
+		SrcAddr: src.Addr(), DstAddr: dst.Addr(), SrcPort: src.Port(), DstPort: dst.Port(),
+		HasPorts: true, L4Proto: types.IPProtoTCP, Length: len(payload) + 54, PayloadLen: len(payload),
+	}
+	if info, ok := packet.ParseHTTP2(payload); ok {
+		pkt.Proto, pkt.Meta = "HTTP2", info
+	} else {
+		pkt.Meta = &types.TCPSegment{Payload: payload}
+	}
+	track(a, &pkt)
+	return pkt
+}
+
+func encodeHeaders(enc *hpack.Encoder, buf *bytes.Buffer, fields ...string) []byte {
+	buf.Reset()
+	for i := 0; i+1 < len(fields); i += 2 {
+		enc.WriteField(hpack.HeaderField{Name: fields[i], Value: fields[i+1]})
+	}
+	return append([]byte(nil), buf.Bytes()...)
+}
+
+func TestHTTP2HeadersAcrossSegments(t *testing.T) {
+	a := newTestApp(1, 1)
+	var buf bytes.Buffer
+	enc := hpack.NewEncoder(&buf)
+
+	// The first block adds x-trace to the dynamic table and the second
+	// refers to it, so both only decode if the cut block is kept whole.
+	first := encodeHeaders(enc, &buf, ":method", "GET", ":scheme", "http", ":path", "/a",
+		":authority", "example.com", "x-trace", strings.Repeat("t", 40))
+	second := encodeHeaders(enc, &buf, ":method", "GET", ":scheme", "http", ":path", "/b",
+		":authority", "example.com", "x-trace", strings.Repeat("t", 40))
+
+	stream := append([]byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"), h2Frame(4, 0, 0, nil)...)
+	stream = append(stream, h2Frame(1, 0x5, 1, first)...)
+	cut := len(stream) - len(first)/2
+	stream = append(stream, h2Frame(1, 0x5, 3, second)...)
+
+	pkt := h2Segment(a, true, stream[:cut])
+	if strings.Contains(pkt.Detail, "lost") {
+		t.Fatalf("cut segment: %q", pkt.Detail)
+	}
+	pkt = h2Segment(a, true, stream[cut:])
+	for _, want := range []string{"GET example.com/a s=1", "GET example.com/b s=3"} {
+		if !strings.Contains(pkt.Detail, want) {
+			t.Errorf("detail %q, want %q", pkt.Detail, want)
+		}
+	}
+	if pkt.Proto != "HTTP2" || pkt.Meta != nil {
+		t.Errorf("Proto = %q Meta = %T, want HTTP2 and no meta", pkt.Proto, pkt.Meta)
+	}
+}
+
+func TestHTTP2PushPromise(t *testing.T) {
+	a := newTestApp(1, 1)
+	var reqBuf, respBuf bytes.Buffer
+	reqEnc, respEnc := hpack.NewEncoder(&reqBuf), hpack.NewEncoder(&respBuf)
+
+	req := encodeHeaders(reqEnc, &reqBuf, ":method", "GET", ":scheme", "http", ":path", "/", ":authority", "example.com")
+	h2Segment(a, true, append([]byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"), h2Frame(1, 0x5, 1, req)...))
+
+	promise := encodeHeaders(respEnc, &respBuf, ":method", "GET", ":scheme", "http", ":path", "/style.css", ":authority", "example.com")
+	pushed := encodeHeaders(respEnc, &respBuf, ":status", "200")
+	resp := h2Frame(5, 0x4, 1, append([]byte{0, 0, 0, 2}, promise...))
+	resp = append(resp, h2Frame(1, 0x4, 2, pushed)...)
+	pkt := h2Segment(a, false, resp)
+
+	for _, want := range []string{"PUSH_PROMISE s=1 GET example.com/style.css promised=2", "status 200 s=2 GET /style.css"} {
+		if !strings.Contains(pkt.Detail, want) {
+			t.Errorf("detail %q, want %q", pkt.Detail, want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package network
+
+import (
+	"slices"
+
+	"github.com/fe-dudu/netmon/internal/types"
+)
+
+const (
+	maxTopics       = 2048
+	maxTopicClients = 16
+)
+
+// trackTopics aggregates MQTT, AMQP and Kafka traffic per topic (or
+// exchange and routing key) so the chattiest ones stand out.
+func trackTopics(a *types.App, pkt *types.PacketInfo, info *types.MessagingInfo) {
+	a.TopicsMutex.Lock()
+	defer a.TopicsMutex.Unlock()
+
+	if a.Topics == nil {
+		a.Topics = make(map[types.TopicKey]*types.Topic)
+	}
+	for _, ev := range info.Topics {
+		key := types.TopicKey{Proto: pkt.Proto, Name: ev.Name}
+		t, ok := a.Topics[key]
+		if !ok {
+			if len(a.Topics) >= maxTopics {
+				evictOldestTopic(a)
+			}
+			t = &types.Topic{Proto: key.Proto, Name: key.Name, First: pkt.Timestamp}
+			a.Topics[key] = t
+		}
+		t.Last = pkt.Timestamp
+		if ev.Publish {
+			t.Published++
+		} else {
+			t.Consumed++
+		}
+		t.Bytes += ev.Bytes
+
+		// The client is whichever end isn't the broker.
+		client := pkt.SrcAddr
+		if pkt.SrcPort < pkt.DstPort {
+			client = pkt.DstAddr
+		}
+		if client.IsValid() && len(t.Clients) < maxTopicClients && !slices.Contains(t.Clients, client) {
+			t.Clients = append(t.Clients, client)
+		}
+	}
+}
+
+func evictOldestTopic(a *types.App) {
+	var oldest *types.Topic
+	for _, t := range a.Topics {
+		if oldest == nil || t.Last.Before(oldest.Last) {
+			oldest = t
+		}
+	}
+	delete(a.Topics, types.TopicKey{Proto: oldest.Proto, Name: oldest.Name})
+}
@@ -4,10 +4,11 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/fe-dudu/netmon/internal/packet"
 	"github.com/fe-dudu/netmon/internal/types"
-	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcap"
 )
 
@@ -47,11 +48,6 @@ var (
 	}
 )
 
-type InterfaceOptions struct {
-	IncludeLoopback bool
-	IncludeVPN      bool
-}
-
 func OpenHandle(iface string) (*pcap.Handle, error) {
 	inactive, err := pcap.NewInactiveHandle(iface)
 	if err != nil {
@@ -79,7 +75,7 @@ func OpenLiveFallback(iface string, activateErr error) (*pcap.Handle, error) {
 	return handle, nil
 }
 
-func ActiveInterfaces(devs []pcap.Interface, opts InterfaceOptions) []pcap.Interface {
+func ActiveInterfaces(devs []pcap.Interface, opts types.InterfaceOptions) []pcap.Interface {
 	var preferred []pcap.Interface
 	var active []pcap.Interface
 	var fallback []pcap.Interface
@@ -147,60 +143,203 @@ func hasNonLoopbackIP(dev pcap.Interface) bool {
 	return false
 }
 
+func SelectInterfaces(devs []pcap.Interface, names []string) ([]pcap.Interface, error) {
+	selected := make([]pcap.Interface, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, dev := range devs {
+			if dev.Name == name {
+				selected = append(selected, dev)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("interface %q not found", name)
+		}
+	}
+	return selected, nil
+}
+
+func StartInterface(a *types.App, iface pcap.Interface) error {
+	a.CapturesMutex.Lock()
+	defer a.CapturesMutex.Unlock()
+
+	a.WantedIfaces[iface.Name] = true
+	return openCapture(a, iface)
+}
+
+func StopInterface(a *types.App, name string) {
+	a.CapturesMutex.Lock()
+	defer a.CapturesMutex.Unlock()
+
+	a.WantedIfaces[name] = false
+	closeCapture(a, name)
+}
+
+func CloseCaptures(a *types.App) {
+	a.CapturesMutex.Lock()
+	defer a.CapturesMutex.Unlock()
+
+	for name := range a.Captures {
+		closeCapture(a, name)
+	}
+}
+
+func CompileFilter(a *types.App, expr string) error {
+	a.CapturesMutex.Lock()
+	defer a.CapturesMutex.Unlock()
+
+	if len(a.Captures) == 0 {
+		_, err := pcap.CompileBPFFilter(layers.LinkTypeEthernet, 65535, expr)
+		return err
+	}
+	for _, c := range a.Captures {
+		if _, err := pcap.CompileBPFFilter(c.Source.LinkType(), 65535, expr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyFilter makes bpf the capture filter for open captures and for those
+// opened later by Reconcile. CaptureBPF is guarded by CapturesMutex.
+func ApplyFilter(a *types.App, bpf string) {
+	a.CapturesMutex.Lock()
+	defer a.CapturesMutex.Unlock()
+
+	a.CaptureBPF = bpf
+	for name, c := range a.Captures {
+		if err := c.Source.SetFilter(bpf); err != nil {
+			RecordEvent(a, name, fmt.Sprintf("failed to set filter %q: %v", bpf, err))
+		}
+	}
+}
+
+func openCapture(a *types.App, iface pcap.Interface) error {
+	if _, ok := a.Captures[iface.Name]; ok {
+		return nil
+	}
+	if a.Unprivileged {
+		return fmt.Errorf("privileges were dropped with --user; restart without it to capture here")
+	}
+
+	src, err := OpenSource(iface.Name, a.CaptureOpts)
+	if err != nil {
+		return err
+	}
+
+	bpf := a.CaptureBPF
+	if err := src.SetFilter(bpf); err != nil {
+		src.Close()
+		return fmt.Errorf("set filter %q: %w", bpf, err)
+	}
+
+	c := &types.Capture{Iface: iface, Source: src, StopCh: make(chan struct{})}
+	a.Captures[iface.Name] = c
+	delete(a.CaptureErrors, iface.Name)
+	startReader(a, c)
+	return nil
+}
+
+func AddSource(a *types.App, src PacketSource) error {
+	a.CapturesMutex.Lock()
+	defer a.CapturesMutex.Unlock()
+
+	if err := src.SetFilter(a.CaptureBPF); err != nil {
+		return fmt.Errorf("set filter %q: %w", a.CaptureBPF, err)
+	}
+
+	iface := pcap.Interface{Name: src.Name(), Description: src.LinkType().String()}
+	a.Devices = append(a.Devices, iface)
+	c := &types.Capture{Iface: iface, Source: src, StopCh: make(chan struct{}), Offline: true}
+	a.Captures[iface.Name] = c
+	startReader(a, c)
+	return nil
+}
+
+func closeCapture(a *types.App, name string) {
+	c, ok := a.Captures[name]
+	if !ok {
+		return
+	}
+	delete(a.Captures, name)
+	close(c.StopCh)
+	c.Source.Close()
+}
+
+func captureLost(a *types.App, c *types.Capture) {
+	a.CapturesMutex.Lock()
+	defer a.CapturesMutex.Unlock()
+
+	if a.Captures[c.Iface.Name] != c {
+		return
+	}
+	closeCapture(a, c.Iface.Name)
+	RecordEvent(a, c.Iface.Name, "capture stopped")
+}
+
+func RecordEvent(a *types.App, iface, msg string) {
+	a.EventsMutex.Lock()
+	defer a.EventsMutex.Unlock()
+
+	a.Events = append(a.Events, types.Event{Timestamp: time.Now(), Iface: iface, Message: msg})
+	if len(a.Events) > 100 {
+		a.Events = a.Events[len(a.Events)-100:]
+	}
+}
+
 func StartPacketCapture(a *types.App) {
 	if a.Wg == nil {
 		a.Wg = &sync.WaitGroup{}
 	}
 	wg := a.Wg
 
-	for idx, handle := range a.Handles {
-		if handle == nil {
-			continue
-		}
-		wg.Add(1)
-		ifaceName := a.Ifaces[idx].Name
-		packetSource := gopacket.NewPacketSource(handle, handle.LinkType())
-		packets := packetSource.Packets()
+	startParsers(a)
 
-		go func(name string, in <-chan gopacket.Packet) {
-			defer wg.Done()
-			for {
-				select {
-				case <-a.StopCh:
-					return
-				case pkt, ok := <-in:
-					if !ok {
-						return
-					}
-					info := packet.ParsePacket(pkt)
-					info.Iface = name
-					select {
-					case <-a.StopCh:
-						return
-					case a.PacketCh <- info:
-					default:
-					}
-				}
-			}
-		}(ifaceName, packets)
+	a.CapturesMutex.Lock()
+	a.Capturing = true
+	for _, c := range a.Captures {
+		startReader(a, c)
+	}
+	a.CapturesMutex.Unlock()
+
+	maxPackets := a.MaxPackets
+	if maxPackets <= 0 {
+		maxPackets = 50000
 	}
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
+		flush := time.NewTicker(time.Second)
+		defer flush.Stop()
+
 		for {
 			select {
 			case <-a.StopCh:
 				return
+			case <-flush.C:
+				for _, sink := range a.Sinks {
+					_ = sink.Flush()
+				}
 			case info, ok := <-a.PacketCh:
 				if !ok {
 					return
 				}
+				track(a, &info)
+				for _, sink := range a.Sinks {
+					_ = sink.Write(info)
+				}
 				a.PacketsMutex.Lock()
 				a.Packets = append(a.Packets, info)
-				if len(a.Packets) > 50000 {
-					newPackets := make([]types.PacketInfo, 50000, 50000)
-					copy(newPackets, a.Packets[len(a.Packets)-50000:])
+				if len(a.Packets) > maxPackets {
+					newPackets := make([]types.PacketInfo, maxPackets, maxPackets)
+					copy(newPackets, a.Packets[len(a.Packets)-maxPackets:])
 					a.Packets = newPackets
 				}
 				a.PacketsMutex.Unlock()
@@ -209,6 +348,64 @@ func StartPacketCapture(a *types.App) {
 	}()
 }
 
+func startReader(a *types.App, c *types.Capture) {
+	if !a.Capturing || c.Source == nil {
+		return
+	}
+	if c.StopCh == nil {
+		c.StopCh = make(chan struct{})
+	}
+	readers := []types.PacketReader{c.Source}
+	if rs, ok := c.Source.(types.RingSource); ok {
+		readers = rs.Rings()
+	}
+	c.Backlog = make([]atomic.Uint64, len(readers))
+	for i, r := range readers {
+		a.Wg.Add(1)
+		go readFrames(a, c, r, &c.Backlog[i])
+	}
+}
+
+// readFrames blocks until a frame arrives or the source is closed, which is
+// how StopInterface, CloseCaptures and ui.Stop end it. A failed reader ends
+// the whole capture so it can be reopened as a unit.
+func readFrames(a *types.App, c *types.Capture, r types.PacketReader, backlog *atomic.Uint64) {
+	defer a.Wg.Done()
+	name, linkType, stop := c.Iface.Name, c.Source.LinkType(), c.StopCh
+	for {
+		data, ci, err := r.ReadPacketData()
+		if err == pcap.NextErrorTimeoutExpired {
+			continue
+		}
+		if err != nil {
+			select {
+			case <-a.StopCh:
+			case <-stop:
+			default:
+				captureLost(a, c)
+			}
+			return
+		}
+
+		frame := types.Frame{Iface: name, LinkType: linkType, Data: data, CI: ci}
+		if c.Offline {
+			select {
+			case <-a.StopCh:
+				return
+			case <-stop:
+				return
+			case a.FrameCh <- frame:
+			}
+			continue
+		}
+		select {
+		case a.FrameCh <- frame:
+		default:
+			backlog.Add(1)
+		}
+	}
+}
+
 func hasPrefix(name string, prefixes []string) bool {
 	for _, p := range prefixes {
 		if strings.HasPrefix(name, p) {
@@ -0,0 +1,127 @@
+package network
+
+import (
+	"container/heap"
+	"runtime"
+	"time"
+
+	"github.com/fe-dudu/netmon/internal/packet"
+	"github.com/fe-dudu/netmon/internal/types"
+)
+
+const (
+	reorderWindow = 50 * time.Millisecond
+	maxReorder    = 10000
+)
+
+type parsed struct {
+	info    types.PacketInfo
+	seq     uint64
+	arrived time.Time
+}
+
+type reorderQueue []parsed
+
+func (q reorderQueue) Len() int { return len(q) }
+
+func (q reorderQueue) Less(i, j int) bool {
+	if q[i].info.Timestamp.Equal(q[j].info.Timestamp) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].info.Timestamp.Before(q[j].info.Timestamp)
+}
+
+func (q reorderQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *reorderQueue) Push(x any) { *q = append(*q, x.(parsed)) }
+
+func (q *reorderQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+func startParsers(a *types.App) {
+	workers := a.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	results := make(chan parsed, cap(a.FrameCh))
+	reassembler := packet.NewReassembler()
+
+	for i := 0; i < workers; i++ {
+		a.Wg.Add(1)
+		go func() {
+			defer a.Wg.Done()
+			parser := packet.NewParser()
+			parser.Reassembler = reassembler
+			parser.RedactQueries = a.RedactQueries
+			for {
+				select {
+				case <-a.StopCh:
+					return
+				case frame := <-a.FrameCh:
+					info := parser.Parse(frame.Data, frame.CI, frame.LinkType)
+					info.Iface = frame.Iface
+					for inner := info.Inner; inner != nil; inner = inner.Inner {
+						inner.Iface = frame.Iface
+					}
+					select {
+					case <-a.StopCh:
+						return
+					case results <- parsed{info: info}:
+					}
+				}
+			}
+		}()
+	}
+
+	a.Wg.Add(1)
+	go func() {
+		defer a.Wg.Done()
+		resequence(a, results)
+	}()
+}
+
+// resequence holds parsed packets briefly so that output follows capture
+// timestamps across workers and interfaces rather than completion order.
+func resequence(a *types.App, in <-chan parsed) {
+	ticker := time.NewTicker(reorderWindow / 5)
+	defer ticker.Stop()
+
+	var seq uint64
+	queue := &reorderQueue{}
+	emit := func(p parsed) bool {
+		select {
+		case <-a.StopCh:
+			return false
+		case a.PacketCh <- p.info:
+			return true
+		}
+	}
+
+	for {
+		select {
+		case <-a.StopCh:
+			return
+		case p := <-in:
+			seq++
+			p.seq = seq
+			p.arrived = time.Now()
+			heap.Push(queue, p)
+			if queue.Len() > maxReorder {
+				if !emit(heap.Pop(queue).(parsed)) {
+					return
+				}
+			}
+		case now := <-ticker.C:
+			cutoff := now.Add(-reorderWindow)
+			for queue.Len() > 0 && (*queue)[0].arrived.Before(cutoff) {
+				if !emit(heap.Pop(queue).(parsed)) {
+					return
+				}
+			}
+		}
+	}
+}
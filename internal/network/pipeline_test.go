@@ -0,0 +1,244 @@
+package network
+
+import (
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+
+	"github.com/fe-dudu/netmon/internal/types"
+)
+
+func newTestApp(frameBuffer, workers int) *types.App {
+	return &types.App{
+		WantedIfaces:  map[string]bool{},
+		CaptureErrors: map[string]string{},
+		Captures:      map[string]*types.Capture{},
+		FrameCh:       make(chan types.Frame, frameBuffer),
+		PacketCh:      make(chan types.PacketInfo, 1000),
+		StopCh:        make(chan struct{}),
+		Wg:            &sync.WaitGroup{},
+		Workers:       workers,
+		Offline:       true,
+	}
+}
+
+// replay runs frames from a synthetic source through the capture pipeline
+// and returns the packets once every frame has come out the other end.
+func replay(t *testing.T, a *types.App, frames [][]byte) []types.PacketInfo {
+	t.Helper()
+	src := NewSyntheticSource("synthetic", layers.LinkTypeEthernet, frames, 0, false)
+	if err := AddSource(a, src); err != nil {
+		t.Fatalf("AddSource: %v", err)
+	}
+	StartPacketCapture(a)
+	t.Cleanup(func() {
+		close(a.StopCh)
+		CloseCaptures(a)
+		a.Wg.Wait()
+	})
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		a.PacketsMutex.RLock()
+		n := len(a.Packets)
+		a.PacketsMutex.RUnlock()
+		if n >= len(frames) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d packets, want %d", n, len(frames))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	a.PacketsMutex.RLock()
+	defer a.PacketsMutex.RUnlock()
+	return append([]types.PacketInfo(nil), a.Packets...)
+}
+
+func findPacket(packets []types.PacketInfo, proto, detail string) (types.PacketInfo, bool) {
+	for _, p := range packets {
+		if p.Proto == proto && strings.Contains(p.Detail, detail) {
+			return p, true
+		}
+	}
+	return types.PacketInfo{}, false
+}
+
+func TestSampleFramesPipeline(t *testing.T) {
+	a := newTestApp(4096, 4)
+	frames := sampleFrames()
+	packets := replay(t, a, frames)
+
+	if len(packets) != len(frames) {
+		t.Fatalf("got %d packets, want %d", len(packets), len(frames))
+	}
+	for i := 1; i < len(packets); i++ {
+		if packets[i].Timestamp.Before(packets[i-1].Timestamp) {
+			t.Fatalf("packet %d (%s) is out of timestamp order", i, packets[i].Proto)
+		}
+	}
+
+	tests := []struct {
+		proto, detail    string
+		src, dst         string
+		srcPort, dstPort uint16
+	}{
+		{"MQTT", "CONNECT v3.1.1 client=thermostat-01 user=iot", "192.168.1.10", "10.0.6.10", 50900, 1883},
+		{"MQTT", "PUBLISH sensors/livingroom/temp qos=1 id=7 len=10", "192.168.1.10", "10.0.6.10", 50900, 1883},
+		{"AMQP", "basic.deliver amq.topic/orders.created", "10.0.6.10", "192.168.1.10", 5672, 51000},
+		{"Kafka", "Produce v7 client=orders-svc topics=orders", "192.168.1.10", "10.0.6.10", 51100, 9092},
+		{"gRPC", "helloworld.Greeter/SayHello", "192.168.1.10", "10.0.5.30", 50400, 50051},
+	}
+	for _, tt := range tests {
+		p, ok := findPacket(packets, tt.proto, tt.detail)
+		if !ok {
+			t.Errorf("no %s packet with %q", tt.proto, tt.detail)
+			continue
+		}
+		if p.Iface != "synthetic" {
+			t.Errorf("%s: Iface = %q, want synthetic", tt.proto, p.Iface)
+		}
+		if !p.HasPorts || p.SrcAddr != netip.MustParseAddr(tt.src) || p.DstAddr != netip.MustParseAddr(tt.dst) ||
+			p.SrcPort != tt.srcPort || p.DstPort != tt.dstPort {
+			t.Errorf("%s: %s -> %s, want %s:%d -> %s:%d", tt.proto, p.Src(), p.Dst(), tt.src, tt.srcPort, tt.dst, tt.dstPort)
+		}
+		if p.L4Proto != types.IPProtoTCP || p.IPVersion != 4 {
+			t.Errorf("%s: L4Proto=%d IPVersion=%d, want TCP over IPv4", tt.proto, p.L4Proto, p.IPVersion)
+		}
+	}
+
+	if _, ok := findPacket(packets, "DNS", ""); !ok {
+		t.Error("no DNS packet")
+	}
+	if _, ok := findPacket(packets, "HTTP", "GET /index.html"); !ok {
+		t.Error("no HTTP request")
+	}
+	for _, detail := range []string{`text len=37 masked "{"type":"subscribe"`, "binary len=3000", "continued len=1604", "close 1000 normal"} {
+		if _, ok := findPacket(packets, "WebSocket", detail); !ok {
+			t.Errorf("no WebSocket packet with %q", detail)
+		}
+	}
+	for _, p := range packets {
+		if _, ok := p.Meta.(*types.TCPSegment); ok {
+			t.Errorf("%s %s: raw segment kept after tracking", p.Proto, p.Detail)
+		}
+	}
+	tunnelled := false
+	for _, p := range packets {
+		if p.Proto == "VXLAN" && p.Inner != nil && p.Inner.Proto == "HTTP" {
+			tunnelled = true
+		}
+	}
+	if !tunnelled {
+		t.Error("no HTTP over VXLAN")
+	}
+
+	a.TopicsMutex.Lock()
+	topic := a.Topics[types.TopicKey{Proto: "MQTT", Name: "sensors/livingroom/temp"}]
+	a.TopicsMutex.Unlock()
+	if topic == nil || topic.Published != 1 || topic.Bytes != 10 {
+		t.Errorf("MQTT topic = %+v, want one 10-byte publish", topic)
+	}
+
+	a.FlowsMutex.Lock()
+	flows := len(a.Flows)
+	a.FlowsMutex.Unlock()
+	if flows == 0 {
+		t.Error("no flows tracked")
+	}
+}
+
+func TestOfflineSourceDoesNotDrop(t *testing.T) {
+	// A one-slot frame channel and a single worker keep the reader blocked
+	// most of the time; a live capture would drop here.
+	a := newTestApp(1, 1)
+	frames := sampleFrames()
+	packets := replay(t, a, frames)
+	if len(packets) != len(frames) {
+		t.Fatalf("got %d packets, want %d", len(packets), len(frames))
+	}
+}
+
+func TestUnprivilegedDoesNotOpen(t *testing.T) {
+	a := newTestApp(1, 1)
+	a.Unprivileged = true
+	err := openCapture(a, pcap.Interface{Name: "eth0"})
+	if err == nil || !strings.Contains(err.Error(), "--user") {
+		t.Fatalf("openCapture after dropping privileges: %v", err)
+	}
+	if len(a.Captures) != 0 {
+		t.Errorf("captures = %v, want none", a.Captures)
+	}
+}
+
+// ringSource stands in for an afpacket fanout group.
+type ringSource struct {
+	*SyntheticSource
+	rings []*SyntheticSource
+}
+
+func (s *ringSource) Rings() []types.PacketReader {
+	readers := make([]types.PacketReader, len(s.rings))
+	for i, r := range s.rings {
+		readers[i] = r
+	}
+	return readers
+}
+
+func (s *ringSource) RingStats() ([]types.SourceStats, error) {
+	stats := make([]types.SourceStats, len(s.rings))
+	for i, r := range s.rings {
+		stats[i], _ = r.Stats()
+	}
+	return stats, nil
+}
+
+func (s *ringSource) Close() {
+	for _, r := range s.rings {
+		r.Close()
+	}
+}
+
+func TestRingsReadAndDropIndependently(t *testing.T) {
+	a := newTestApp(1, 1)
+	a.Offline = false
+	frames := sampleFrames()[:4]
+	rings := make([]*SyntheticSource, 3)
+	for i := range rings {
+		rings[i] = NewSyntheticSource("eth0", layers.LinkTypeEthernet, frames, 0, true)
+	}
+	src := &ringSource{SyntheticSource: rings[0], rings: rings}
+	c := &types.Capture{Iface: pcap.Interface{Name: "eth0"}, Source: src}
+	a.Captures["eth0"] = c
+	StartPacketCapture(a)
+	t.Cleanup(func() {
+		close(a.StopCh)
+		CloseCaptures(a)
+		a.Wg.Wait()
+	})
+
+	if len(c.Backlog) != len(rings) {
+		t.Fatalf("%d backlog counters, want one per ring", len(c.Backlog))
+	}
+	// Looping rings flood a one-slot frame channel, so each reader has to
+	// read and drop on its own.
+	deadline := time.Now().Add(10 * time.Second)
+	for i, r := range rings {
+		for {
+			st, _ := r.Stats()
+			if st.Received > 0 && c.Backlog[i].Load() > 0 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("ring %d: received %d, backlog %d", i, st.Received, c.Backlog[i].Load())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
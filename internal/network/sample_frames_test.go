@@ -0,0 +1,613 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/net/http2/hpack"
+)
+
+// sampleFrames builds the traffic --demo replays from demo.pcap. After
+// changing it, regenerate the capture with
+//
+//	go test ./internal/network -run TestDemoCapture -update
+func sampleFrames() [][]byte {
+	clientMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	routerMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0xfe}
+	client := net.IPv4(192, 168, 1, 10)
+	resolver := net.IPv4(192, 168, 1, 1)
+	web := net.IPv4(93, 184, 216, 34)
+	client6 := net.ParseIP("2001:db8::10")
+	web6 := net.ParseIP("2001:db8:1::443")
+
+	eth := func(t layers.EthernetType) *layers.Ethernet {
+		return &layers.Ethernet{SrcMAC: clientMAC, DstMAC: routerMAC, EthernetType: t}
+	}
+	ip4 := func(src, dst net.IP, proto layers.IPProtocol) *layers.IPv4 {
+		return &layers.IPv4{Version: 4, TTL: 64, SrcIP: src, DstIP: dst, Protocol: proto}
+	}
+	ip6 := func(src, dst net.IP, next layers.IPProtocol) *layers.IPv6 {
+		return &layers.IPv6{Version: 6, HopLimit: 64, SrcIP: src, DstIP: dst, NextHeader: next}
+	}
+
+	var frames [][]byte
+	add := func(ls ...gopacket.SerializableLayer) {
+		var network gopacket.NetworkLayer
+		for _, l := range ls {
+			switch t := l.(type) {
+			case gopacket.NetworkLayer:
+				network = t
+			case *layers.TCP:
+				t.SetNetworkLayerForChecksum(network)
+			case *layers.UDP:
+				t.SetNetworkLayerForChecksum(network)
+			case *layers.ICMPv6:
+				t.SetNetworkLayerForChecksum(network)
+			}
+		}
+		buf := gopacket.NewSerializeBuffer()
+		opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+		if err := gopacket.SerializeLayers(buf, opts, ls...); err == nil {
+			frames = append(frames, buf.Bytes())
+		}
+	}
+
+	dns := &layers.DNS{
+		ID: 0x1234, RD: true, QDCount: 1,
+		Questions: []layers.DNSQuestion{{Name: []byte("example.com"), Type: layers.DNSTypeA, Class: layers.DNSClassIN}},
+	}
+	add(eth(layers.EthernetTypeIPv4), ip4(client, resolver, layers.IPProtocolUDP),
+		&layers.UDP{SrcPort: 53000, DstPort: 53}, dns)
+
+	add(eth(layers.EthernetTypeIPv4), ip4(client, web, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 51000, DstPort: 443, SYN: true, Window: 65535})
+
+	add(eth(layers.EthernetTypeIPv4), ip4(client, web, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 51001, DstPort: 80, PSH: true, ACK: true, Window: 65535},
+		gopacket.Payload("GET /index.html HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+
+	add(eth(layers.EthernetTypeIPv4), ip4(client, web, layers.IPProtocolICMPv4),
+		&layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0), Id: 1, Seq: 1})
+
+	add(&layers.Ethernet{SrcMAC: routerMAC, DstMAC: clientMAC, EthernetType: layers.EthernetTypeIPv4},
+		ip4(web, client, layers.IPProtocolICMPv4),
+		&layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoReply, 0), Id: 1, Seq: 1})
+
+	quoted := []byte{
+		0x45, 0x00, 0x00, 0x1c, 0x00, 0x00, 0x00, 0x00, 0x40, 0x11, 0x00, 0x00,
+		192, 168, 1, 10, 192, 168, 1, 1,
+		0xcf, 0x08, 0x27, 0x0f, 0x00, 0x08, 0x00, 0x00,
+	}
+	add(&layers.Ethernet{SrcMAC: routerMAC, DstMAC: clientMAC, EthernetType: layers.EthernetTypeIPv4},
+		ip4(resolver, client, layers.IPProtocolICMPv4),
+		&layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeDestinationUnreachable, layers.ICMPv4CodePort)},
+		gopacket.Payload(quoted))
+
+	solicited := net.ParseIP("ff02::1:ff00:1")
+	ns := &layers.ICMPv6{TypeCode: layers.CreateICMPv6TypeCode(layers.ICMPv6TypeNeighborSolicitation, 0)}
+	add(&layers.Ethernet{SrcMAC: clientMAC, DstMAC: net.HardwareAddr{0x33, 0x33, 0xff, 0x00, 0x00, 0x01}, EthernetType: layers.EthernetTypeIPv6},
+		&layers.IPv6{Version: 6, HopLimit: 255, SrcIP: client6, DstIP: solicited, NextHeader: layers.IPProtocolICMPv6},
+		ns, gopacket.Payload(append(append(make([]byte, 4), net.ParseIP("2001:db8::1")...), 1, 1, 0x02, 0, 0, 0, 0, 0x01)))
+
+	add(eth(layers.EthernetTypeIPv6), ip6(client6, web6, layers.IPProtocolUDP),
+		&layers.UDP{SrcPort: 52000, DstPort: 443}, gopacket.Payload([]byte{0xc3, 0x00, 0x00, 0x00, 0x01}))
+
+	add(eth(layers.EthernetTypeIPv4), ip4(client, web, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 51002, DstPort: 8443, PSH: true, ACK: true, Window: 65535},
+		gopacket.Payload([]byte{0x16, 0x03, 0x01, 0x00, 0x04, 0x01, 0x00, 0x00, 0x00}))
+
+	add(eth(layers.EthernetTypeIPv4), ip4(web, client, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 2222, DstPort: 51003, PSH: true, ACK: true, Window: 65535},
+		gopacket.Payload("SSH-2.0-OpenSSH_9.6\r\n"))
+	add(eth(layers.EthernetTypeIPv4), ip4(client, web, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 51003, DstPort: 2222, PSH: true, ACK: true, Window: 65535},
+		gopacket.Payload(append([]byte("SSH-2.0-OpenSSH_7.4\r\n"), sshKexInit(
+			"curve25519-sha256,ecdh-sha2-nistp256,diffie-hellman-group14-sha1", "ssh-ed25519,rsa-sha2-512",
+			"aes128-ctr,aes256-ctr", "hmac-sha2-256,hmac-sha1", "none")...)))
+	add(eth(layers.EthernetTypeIPv4), ip4(web, client, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 2222, DstPort: 51003, PSH: true, ACK: true, Window: 65535},
+		gopacket.Payload(sshKexInit(
+			"sntrup761x25519-sha512@openssh.com,curve25519-sha256", "ssh-ed25519",
+			"chacha20-poly1305@openssh.com,aes128-ctr", "hmac-sha2-256", "none,zlib@openssh.com")))
+
+	broadcast := net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	add(&layers.Ethernet{SrcMAC: clientMAC, DstMAC: broadcast, EthernetType: layers.EthernetTypeARP},
+		&layers.ARP{
+			AddrType: layers.LinkTypeEthernet, Protocol: layers.EthernetTypeIPv4,
+			HwAddressSize: 6, ProtAddressSize: 4, Operation: layers.ARPRequest,
+			SourceHwAddress: clientMAC, SourceProtAddress: client.To4(),
+			DstHwAddress: make([]byte, 6), DstProtAddress: resolver.To4(),
+		})
+	add(&layers.Ethernet{SrcMAC: routerMAC, DstMAC: clientMAC, EthernetType: layers.EthernetTypeARP},
+		&layers.ARP{
+			AddrType: layers.LinkTypeEthernet, Protocol: layers.EthernetTypeIPv4,
+			HwAddressSize: 6, ProtAddressSize: 4, Operation: layers.ARPReply,
+			SourceHwAddress: routerMAC, SourceProtAddress: resolver.To4(),
+			DstHwAddress: clientMAC, DstProtAddress: client.To4(),
+		})
+
+	dhcp := func(msg layers.DHCPMsgType, yiaddr net.IP, opts ...layers.DHCPOption) *layers.DHCPv4 {
+		opts = append([]layers.DHCPOption{layers.NewDHCPOption(layers.DHCPOptMessageType, []byte{byte(msg)})}, opts...)
+		return &layers.DHCPv4{
+			Operation: layers.DHCPOpRequest, HardwareType: layers.LinkTypeEthernet, HardwareLen: 6,
+			Xid: 0x5eed, ClientIP: net.IPv4zero, YourClientIP: yiaddr, NextServerIP: net.IPv4zero,
+			RelayAgentIP: net.IPv4zero, ClientHWAddr: clientMAC, Options: opts,
+		}
+	}
+	add(&layers.Ethernet{SrcMAC: clientMAC, DstMAC: broadcast, EthernetType: layers.EthernetTypeIPv4},
+		ip4(net.IPv4zero, net.IPv4bcast, layers.IPProtocolUDP),
+		&layers.UDP{SrcPort: 68, DstPort: 67},
+		dhcp(layers.DHCPMsgTypeDiscover, net.IPv4zero,
+			layers.NewDHCPOption(layers.DHCPOptHostname, []byte("laptop"))))
+	add(&layers.Ethernet{SrcMAC: routerMAC, DstMAC: clientMAC, EthernetType: layers.EthernetTypeIPv4},
+		ip4(resolver, client, layers.IPProtocolUDP),
+		&layers.UDP{SrcPort: 67, DstPort: 68},
+		dhcp(layers.DHCPMsgTypeAck, client,
+			layers.NewDHCPOption(layers.DHCPOptServerID, resolver.To4()),
+			layers.NewDHCPOption(layers.DHCPOptLeaseTime, []byte{0x00, 0x00, 0x0e, 0x10})))
+
+	lldpMAC := net.HardwareAddr{0x01, 0x80, 0xc2, 0x00, 0x00, 0x0e}
+	add(&layers.Ethernet{SrcMAC: routerMAC, DstMAC: lldpMAC, EthernetType: layers.EthernetTypeLinkLayerDiscovery},
+		&layers.LinkLayerDiscovery{
+			ChassisID: layers.LLDPChassisID{Subtype: layers.LLDPChassisIDSubTypeMACAddr, ID: routerMAC},
+			PortID:    layers.LLDPPortID{Subtype: layers.LLDPPortIDSubtypeIfaceName, ID: []byte("ge-0/0/1")},
+			TTL:       120,
+			Values: []layers.LinkLayerDiscoveryValue{
+				{Type: layers.LLDPTLVSysName, Value: []byte("lab-switch"), Length: 10},
+			},
+		})
+
+	stpMAC := net.HardwareAddr{0x01, 0x80, 0xc2, 0x00, 0x00, 0x00}
+	bpdu := []byte{
+		0x00, 0x00, 0x02, 0x02, 0x3c,
+		0x80, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0xfe,
+		0x00, 0x00, 0x00, 0x04,
+		0x80, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0xfe,
+		0x80, 0x01, 0x00, 0x00, 0x00, 0x14, 0x00, 0x02, 0x00, 0x0f, 0x00,
+	}
+	add(&layers.Ethernet{SrcMAC: routerMAC, DstMAC: stpMAC, EthernetType: layers.EthernetTypeLLC, Length: uint16(3 + len(bpdu))},
+		&layers.LLC{DSAP: 0x42, SSAP: 0x42, Control: 0x03}, gopacket.Payload(bpdu))
+
+	// A DNS response too large for one frame, fragmented over IPv4 and IPv6.
+	answers := make([]layers.DNSResourceRecord, 40)
+	for i := range answers {
+		answers[i] = layers.DNSResourceRecord{
+			Name: []byte("cdn.example.com"), Type: layers.DNSTypeA, Class: layers.DNSClassIN,
+			TTL: 300, IP: net.IPv4(203, 0, 113, byte(i+1)),
+		}
+	}
+	bigDNS := &layers.DNS{
+		ID: 0x4321, QR: true, RD: true, RA: true, QDCount: 1, ANCount: uint16(len(answers)),
+		Questions: []layers.DNSQuestion{{Name: []byte("cdn.example.com"), Type: layers.DNSTypeA, Class: layers.DNSClassIN}},
+		Answers:   answers,
+	}
+	datagram := func(network gopacket.NetworkLayer) []byte {
+		udp := &layers.UDP{SrcPort: 53, DstPort: 53001}
+		udp.SetNetworkLayerForChecksum(network)
+		buf := gopacket.NewSerializeBuffer()
+		opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+		if err := gopacket.SerializeLayers(buf, opts, udp, bigDNS); err != nil {
+			return nil
+		}
+		return buf.Bytes()
+	}
+	if data := datagram(ip4(resolver, client, layers.IPProtocolUDP)); len(data) > 512 {
+		for off := 0; off < len(data); off += 512 {
+			end := min(off+512, len(data))
+			frag := ip4(resolver, client, layers.IPProtocolUDP)
+			frag.Id = 0x2a2a
+			frag.FragOffset = uint16(off / 8)
+			if end < len(data) {
+				frag.Flags = layers.IPv4MoreFragments
+			}
+			add(&layers.Ethernet{SrcMAC: routerMAC, DstMAC: clientMAC, EthernetType: layers.EthernetTypeIPv4},
+				frag, gopacket.Payload(data[off:end]))
+		}
+	}
+	resolver6 := net.ParseIP("2001:db8::53")
+	if data := datagram(ip6(resolver6, client6, layers.IPProtocolUDP)); len(data) > 512 {
+		for off := 0; off < len(data); off += 512 {
+			end := min(off+512, len(data))
+			hdr := []byte{byte(layers.IPProtocolUDP), 0, byte(off >> 8), byte(off), 0, 0, 0x2a, 0x2a}
+			if end < len(data) {
+				hdr[3] |= 1
+			}
+			add(&layers.Ethernet{SrcMAC: routerMAC, DstMAC: clientMAC, EthernetType: layers.EthernetTypeIPv6},
+				ip6(resolver6, client6, layers.IPProtocolIPv6Fragment), gopacket.Payload(append(hdr, data[off:end]...)))
+		}
+	}
+
+	// Database traffic: a PostgreSQL login, query and error, a MySQL
+	// greeting and query, and a Redis command.
+	dbHost := net.IPv4(10, 0, 5, 20)
+	pgStartup := binary.BigEndian.AppendUint32(nil, 0)
+	pgStartup = binary.BigEndian.AppendUint32(pgStartup, 196608)
+	pgStartup = append(pgStartup, "user\x00app\x00database\x00orders\x00application_name\x00psql\x00\x00"...)
+	binary.BigEndian.PutUint32(pgStartup, uint32(len(pgStartup)))
+	pgMsg := func(typ byte, body string) []byte {
+		return append(binary.BigEndian.AppendUint32([]byte{typ}, uint32(4+len(body))), body...)
+	}
+	mysqlPkt := func(seq byte, body string) []byte {
+		n := len(body)
+		return append([]byte{byte(n), byte(n >> 8), byte(n >> 16), seq}, body...)
+	}
+	add(eth(layers.EthernetTypeIPv4), ip4(client, dbHost, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 50100, DstPort: 5432, PSH: true, ACK: true, Window: 65535}, gopacket.Payload(pgStartup))
+	add(eth(layers.EthernetTypeIPv4), ip4(client, dbHost, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 50100, DstPort: 5432, PSH: true, ACK: true, Window: 65535},
+		gopacket.Payload(pgMsg('Q', "SELECT id, total FROM orders WHERE email = 'a@example.com' AND total > 100\x00")))
+	add(eth(layers.EthernetTypeIPv4), ip4(dbHost, client, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 5432, DstPort: 50100, PSH: true, ACK: true, Window: 65535},
+		gopacket.Payload(append(pgMsg('E', "SERROR\x00C42P01\x00Mrelation \"orderz\" does not exist\x00\x00"), pgMsg('Z', "I")...)))
+	add(eth(layers.EthernetTypeIPv4), ip4(dbHost, client, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 3306, DstPort: 50200, PSH: true, ACK: true, Window: 65535},
+		gopacket.Payload(mysqlPkt(0, "\x0a8.0.36\x00\x01\x00\x00\x00abcdefgh\x00")))
+	add(eth(layers.EthernetTypeIPv4), ip4(client, dbHost, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 50200, DstPort: 3306, PSH: true, ACK: true, Window: 65535},
+		gopacket.Payload(mysqlPkt(0, "\x03UPDATE users SET name = 'bob' WHERE id = 42")))
+	add(eth(layers.EthernetTypeIPv4), ip4(client, dbHost, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 50300, DstPort: 6379, PSH: true, ACK: true, Window: 65535},
+		gopacket.Payload("*3\r\n$3\r\nSET\r\n$13\r\nsession:12345\r\n$5\r\nhello\r\n"))
+
+	// A cleartext gRPC call: preface and request, then response headers
+	// and trailers.
+	grpcHost := net.IPv4(10, 0, 5, 30)
+	reqHeaders := hpackBlock(":method", "POST", ":scheme", "http", ":path", "/helloworld.Greeter/SayHello",
+		":authority", "greeter:50051", "content-type", "application/grpc", "te", "trailers")
+	request := append([]byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"), h2Frame(4, 0, 0, nil)...)
+	request = append(request, h2Frame(1, 0x4, 1, reqHeaders)...)
+	request = append(request, h2Frame(0, 0x1, 1, []byte{0, 0, 0, 0, 7, 0x0a, 5, 'w', 'o', 'r', 'l', 'd'})...)
+	response := h2Frame(4, 0, 0, nil)
+	response = append(response, h2Frame(1, 0x4, 1, hpackBlock(":status", "200", "content-type", "application/grpc"))...)
+	response = append(response, h2Frame(0, 0, 1, []byte{0, 0, 0, 0, 13, 0x0a, 11, 'H', 'e', 'l', 'l', 'o', ' ', 'w', 'o', 'r', 'l', 'd'})...)
+	response = append(response, h2Frame(1, 0x5, 1, hpackBlock("grpc-status", "0"))...)
+	add(eth(layers.EthernetTypeIPv4), ip4(client, grpcHost, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 50400, DstPort: 50051, PSH: true, ACK: true, Window: 65535}, gopacket.Payload(request))
+	add(eth(layers.EthernetTypeIPv4), ip4(grpcHost, client, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 50051, DstPort: 50400, PSH: true, ACK: true, Window: 65535}, gopacket.Payload(response))
+
+	// A WebSocket session: upgrade, text messages both ways, a binary
+	// message split across two segments and a close.
+	wsHost := net.IPv4(10, 0, 5, 40)
+	wsClient := func(payload []byte) {
+		add(eth(layers.EthernetTypeIPv4), ip4(client, wsHost, layers.IPProtocolTCP),
+			&layers.TCP{SrcPort: 50500, DstPort: 8080, PSH: true, ACK: true, Window: 65535}, gopacket.Payload(payload))
+	}
+	wsServer := func(payload []byte) {
+		add(eth(layers.EthernetTypeIPv4), ip4(wsHost, client, layers.IPProtocolTCP),
+			&layers.TCP{SrcPort: 8080, DstPort: 50500, PSH: true, ACK: true, Window: 65535}, gopacket.Payload(payload))
+	}
+	wsClient([]byte("GET /chat?token=abc HTTP/1.1\r\nHost: chat.example.com\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+	wsServer(append([]byte("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: s3pPLMBiTxaQ9kYGzzhZRbK+xOo=\r\n\r\n"), wsFrame(0x1, nil, []byte(`{"type":"welcome"}`))...))
+	wsClient(wsFrame(0x1, []byte{0x12, 0x34, 0x56, 0x78}, []byte(`{"type":"subscribe","room":"general"}`)))
+	message := wsFrame(0x2, nil, make([]byte, 3000))
+	wsServer(message[:1400])
+	wsServer(message[1400:])
+	wsClient(wsFrame(0x8, []byte{0x9a, 0xbc, 0xde, 0xf0}, []byte("\x03\xe8bye")))
+
+	// LAN discovery: a Chromecast answering an mDNS query, a media server
+	// announcing itself over SSDP, an LLMNR lookup and a NetBIOS name
+	// registration.
+	tv := net.IPv4(192, 168, 1, 20)
+	tvMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x20}
+	nas := net.IPv4(192, 168, 1, 30)
+	nasMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x30}
+	mdnsGroup := net.IPv4(224, 0, 0, 251)
+	mdnsMAC := net.HardwareAddr{0x01, 0x00, 0x5e, 0x00, 0x00, 0xfb}
+	ssdpGroup := net.IPv4(239, 255, 255, 250)
+	ssdpMAC := net.HardwareAddr{0x01, 0x00, 0x5e, 0x7f, 0xff, 0xfa}
+	add(&layers.Ethernet{SrcMAC: clientMAC, DstMAC: mdnsMAC, EthernetType: layers.EthernetTypeIPv4},
+		ip4(client, mdnsGroup, layers.IPProtocolUDP), &layers.UDP{SrcPort: 5353, DstPort: 5353},
+		&layers.DNS{QDCount: 1, Questions: []layers.DNSQuestion{{Name: []byte("_googlecast._tcp.local"), Type: layers.DNSTypePTR, Class: layers.DNSClassIN}}})
+	instance := []byte("Living Room._googlecast._tcp.local")
+	add(&layers.Ethernet{SrcMAC: tvMAC, DstMAC: mdnsMAC, EthernetType: layers.EthernetTypeIPv4},
+		ip4(tv, mdnsGroup, layers.IPProtocolUDP), &layers.UDP{SrcPort: 5353, DstPort: 5353},
+		&layers.DNS{QR: true, AA: true,
+			Answers: []layers.DNSResourceRecord{{Name: []byte("_googlecast._tcp.local"), Type: layers.DNSTypePTR, Class: layers.DNSClassIN, TTL: 120, PTR: instance}},
+			Additionals: []layers.DNSResourceRecord{
+				{Name: instance, Type: layers.DNSTypeSRV, Class: 0x8001, TTL: 120, SRV: layers.DNSSRV{Port: 8009, Name: []byte("chromecast-1a2b.local")}},
+				{Name: instance, Type: layers.DNSTypeTXT, Class: 0x8001, TTL: 4500, TXTs: [][]byte{[]byte("id=1a2b3c"), []byte("md=Chromecast"), []byte("fn=Living Room")}},
+				{Name: []byte("chromecast-1a2b.local"), Type: layers.DNSTypeA, Class: 0x8001, TTL: 120, IP: tv},
+			}})
+	add(&layers.Ethernet{SrcMAC: nasMAC, DstMAC: ssdpMAC, EthernetType: layers.EthernetTypeIPv4},
+		ip4(nas, ssdpGroup, layers.IPProtocolUDP), &layers.UDP{SrcPort: 1900, DstPort: 1900},
+		gopacket.Payload("NOTIFY * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\nCACHE-CONTROL: max-age=1800\r\n"+
+			"LOCATION: http://192.168.1.30:8200/rootDesc.xml\r\nNT: urn:schemas-upnp-org:device:MediaServer:1\r\nNTS: ssdp:alive\r\n"+
+			"SERVER: Linux/5.10 DLNADOC/1.50 UPnP/1.0 MiniDLNA/1.3.0\r\nUSN: uuid:4d696e69-444c-164e-9d41-b827eb000030::urn:schemas-upnp-org:device:MediaServer:1\r\n\r\n"))
+	add(&layers.Ethernet{SrcMAC: clientMAC, DstMAC: ssdpMAC, EthernetType: layers.EthernetTypeIPv4},
+		ip4(client, ssdpGroup, layers.IPProtocolUDP), &layers.UDP{SrcPort: 50600, DstPort: 1900},
+		gopacket.Payload("M-SEARCH * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\nMAN: \"ssdp:discover\"\r\nMX: 2\r\nST: ssdp:all\r\n\r\n"))
+	add(&layers.Ethernet{SrcMAC: clientMAC, DstMAC: net.HardwareAddr{0x01, 0x00, 0x5e, 0x00, 0x00, 0xfc}, EthernetType: layers.EthernetTypeIPv4},
+		ip4(client, net.IPv4(224, 0, 0, 252), layers.IPProtocolUDP), &layers.UDP{SrcPort: 50700, DstPort: 5355},
+		&layers.DNS{ID: 0x4242, QDCount: 1, Questions: []layers.DNSQuestion{{Name: []byte("nas"), Type: layers.DNSTypeA, Class: layers.DNSClassIN}}})
+	add(&layers.Ethernet{SrcMAC: nasMAC, DstMAC: clientMAC, EthernetType: layers.EthernetTypeIPv4},
+		ip4(nas, client, layers.IPProtocolUDP), &layers.UDP{SrcPort: 5355, DstPort: 50700},
+		&layers.DNS{ID: 0x4242, QR: true, QDCount: 1,
+			Questions: []layers.DNSQuestion{{Name: []byte("nas"), Type: layers.DNSTypeA, Class: layers.DNSClassIN}},
+			Answers:   []layers.DNSResourceRecord{{Name: []byte("nas"), Type: layers.DNSTypeA, Class: layers.DNSClassIN, TTL: 30, IP: nas}}})
+	add(&layers.Ethernet{SrcMAC: nasMAC, DstMAC: broadcast, EthernetType: layers.EthernetTypeIPv4},
+		ip4(nas, net.IPv4(192, 168, 1, 255), layers.IPProtocolUDP), &layers.UDP{SrcPort: 137, DstPort: 137},
+		gopacket.Payload(nbnsRegistration(0x7001, "NAS", 0x20, nas)))
+
+	// Infrastructure traffic: an NTP exchange, an SNMP poll and trap, and
+	// syslog from a router.
+	router := net.IPv4(10, 0, 0, 254)
+	ntpServer := net.IPv4(10, 0, 0, 123)
+	now := demoEpoch
+	ntpRequest := make([]byte, 48)
+	ntpRequest[0] = 0x23
+	putNTPTime(ntpRequest[40:], now)
+	ntpReply := make([]byte, 48)
+	ntpReply[0], ntpReply[1], ntpReply[2], ntpReply[3] = 0x24, 2, 6, 0xe9
+	copy(ntpReply[12:16], net.IPv4(10, 0, 0, 1).To4())
+	putNTPTime(ntpReply[16:], now.Add(-time.Minute))
+	putNTPTime(ntpReply[24:], now)
+	putNTPTime(ntpReply[32:], now.Add(6*time.Millisecond))
+	putNTPTime(ntpReply[40:], now.Add(6100*time.Microsecond))
+	add(eth(layers.EthernetTypeIPv4), ip4(client, ntpServer, layers.IPProtocolUDP),
+		&layers.UDP{SrcPort: 123, DstPort: 123}, gopacket.Payload(ntpRequest))
+	add(eth(layers.EthernetTypeIPv4), ip4(ntpServer, client, layers.IPProtocolUDP),
+		&layers.UDP{SrcPort: 123, DstPort: 123}, gopacket.Payload(ntpReply))
+
+	sysName := ber(0x06, 0x2b, 6, 1, 2, 1, 1, 5, 0)
+	sysUpTime := ber(0x06, 0x2b, 6, 1, 2, 1, 1, 3, 0)
+	snmpV2c := func(pdu []byte) []byte {
+		return ber(0x30, slices.Concat(ber(0x02, 1), ber(0x04, []byte("public")...), pdu)...)
+	}
+	add(eth(layers.EthernetTypeIPv4), ip4(client, router, layers.IPProtocolUDP),
+		&layers.UDP{SrcPort: 50800, DstPort: 161},
+		gopacket.Payload(snmpV2c(ber(0xa0, slices.Concat(ber(0x02, 0x2a), ber(0x02, 0), ber(0x02, 0),
+			ber(0x30, slices.Concat(ber(0x30, slices.Concat(sysName, ber(0x05))...), ber(0x30, slices.Concat(sysUpTime, ber(0x05))...))...))...))))
+	add(eth(layers.EthernetTypeIPv4), ip4(router, client, layers.IPProtocolUDP),
+		&layers.UDP{SrcPort: 161, DstPort: 50800},
+		gopacket.Payload(snmpV2c(ber(0xa2, slices.Concat(ber(0x02, 0x2a), ber(0x02, 0), ber(0x02, 0),
+			ber(0x30, slices.Concat(ber(0x30, slices.Concat(sysName, ber(0x04, []byte("core-sw1")...))...),
+				ber(0x30, slices.Concat(sysUpTime, ber(0x43, 0x01, 0x2c, 0x4b, 0x10))...))...))...))))
+	add(eth(layers.EthernetTypeIPv4), ip4(router, client, layers.IPProtocolUDP),
+		&layers.UDP{SrcPort: 50162, DstPort: 162},
+		gopacket.Payload(snmpV2c(ber(0xa7, slices.Concat(ber(0x02, 0x07), ber(0x02, 0), ber(0x02, 0),
+			ber(0x30, slices.Concat(
+				ber(0x30, slices.Concat(sysUpTime, ber(0x43, 0x01, 0x2c, 0x4b, 0x10))...),
+				ber(0x30, slices.Concat(ber(0x06, 0x2b, 6, 1, 6, 3, 1, 1, 4, 1, 0), ber(0x06, 0x2b, 6, 1, 6, 3, 1, 1, 5, 3))...),
+				ber(0x30, slices.Concat(ber(0x06, 0x2b, 6, 1, 2, 1, 2, 2, 1, 1, 3), ber(0x02, 3))...))...))...))))
+
+	add(eth(layers.EthernetTypeIPv4), ip4(router, client, layers.IPProtocolUDP),
+		&layers.UDP{SrcPort: 514, DstPort: 514},
+		gopacket.Payload("<187>Oct 19 08:15:02 core-sw1 %LINK-3-UPDOWN: Interface GigabitEthernet0/3, changed state to down"))
+	add(eth(layers.EthernetTypeIPv4), ip4(router, client, layers.IPProtocolUDP),
+		&layers.UDP{SrcPort: 514, DstPort: 514},
+		gopacket.Payload(`<38>1 2026-10-19T08:15:07.120Z core-sw1 sshd 2211 - [meta sequenceId="42"] Failed password for admin from 10.0.9.9 port 52344 ssh2`))
+
+	// Messaging: an MQTT sensor connecting and publishing, a subscriber
+	// receiving, AMQP publish and delivery, and Kafka produce and fetch.
+	broker := net.IPv4(10, 0, 6, 10)
+	mqttString := func(s string) []byte {
+		return append(binary.BigEndian.AppendUint16(nil, uint16(len(s))), s...)
+	}
+	mqttConnect := slices.Concat(mqttString("MQTT"), []byte{4, 0xc2, 0, 60},
+		mqttString("thermostat-01"), mqttString("iot"), mqttString("s3cret"))
+	add(eth(layers.EthernetTypeIPv4), ip4(client, broker, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 50900, DstPort: 1883, PSH: true, ACK: true, Window: 65535},
+		gopacket.Payload(mqttPacket(0x10, mqttConnect)))
+	add(eth(layers.EthernetTypeIPv4), ip4(client, broker, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 50900, DstPort: 1883, PSH: true, ACK: true, Window: 65535},
+		gopacket.Payload(slices.Concat(
+			mqttPacket(0x32, slices.Concat(mqttString("sensors/livingroom/temp"), []byte{0, 7}, []byte(`{"c":21.5}`))),
+			mqttPacket(0x82, slices.Concat([]byte{0, 8}, mqttString("sensors/+/temp"), []byte{1})))))
+	add(eth(layers.EthernetTypeIPv4), ip4(broker, client, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 1883, DstPort: 50900, PSH: true, ACK: true, Window: 65535},
+		gopacket.Payload(slices.Concat(
+			mqttPacket(0x40, []byte{0, 7}),
+			mqttPacket(0x30, slices.Concat(mqttString("sensors/kitchen/temp"), []byte(`{"c":19.0}`))))))
+
+	amqpBody := []byte(`{"order":1042}`)
+	amqpContent := func(class uint16) []byte {
+		header := binary.BigEndian.AppendUint16(nil, class)
+		header = binary.BigEndian.AppendUint64(append(header, 0, 0), uint64(len(amqpBody)))
+		return slices.Concat(amqpFrame(2, 1, append(header, 0, 0)), amqpFrame(3, 1, amqpBody))
+	}
+	add(eth(layers.EthernetTypeIPv4), ip4(client, broker, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 51000, DstPort: 5672, PSH: true, ACK: true, Window: 65535},
+		gopacket.Payload("AMQP\x00\x00\x09\x01"))
+	add(eth(layers.EthernetTypeIPv4), ip4(client, broker, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 51000, DstPort: 5672, PSH: true, ACK: true, Window: 65535},
+		gopacket.Payload(slices.Concat(
+			amqpFrame(1, 1, slices.Concat([]byte{0, 60, 0, 40, 0, 0, 9}, []byte("amq.topic"), []byte{14}, []byte("orders.created"), []byte{0})),
+			amqpContent(60))))
+	add(eth(layers.EthernetTypeIPv4), ip4(broker, client, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 5672, DstPort: 51000, PSH: true, ACK: true, Window: 65535},
+		gopacket.Payload(slices.Concat(
+			amqpFrame(1, 1, slices.Concat([]byte{0, 60, 0, 60, 6}, []byte("ctag-1"), []byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 9},
+				[]byte("amq.topic"), []byte{14}, []byte("orders.created"))),
+			amqpContent(60))))
+
+	records := make([]byte, 96)
+	produce := binary.BigEndian.AppendUint16(nil, 0xffff)
+	produce = binary.BigEndian.AppendUint16(produce, 0xffff)
+	produce = binary.BigEndian.AppendUint32(produce, 30000)
+	produce = binary.BigEndian.AppendUint32(produce, 1)
+	produce = append(binary.BigEndian.AppendUint16(produce, 6), "orders"...)
+	produce = binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(produce, 1), 0)
+	produce = append(binary.BigEndian.AppendUint32(produce, uint32(len(records))), records...)
+	fetch := binary.BigEndian.AppendUint32(nil, 0xffffffff)
+	fetch = binary.BigEndian.AppendUint32(fetch, 500)
+	fetch = binary.BigEndian.AppendUint32(fetch, 1)
+	fetch = binary.BigEndian.AppendUint32(fetch, 50<<20)
+	fetch = binary.BigEndian.AppendUint32(append(fetch, 0), 1)
+	fetch = append(binary.BigEndian.AppendUint16(fetch, 6), "orders"...)
+	fetch = binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(fetch, 1), 0)
+	fetch = binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint64(fetch, 1041), 1<<20)
+	add(eth(layers.EthernetTypeIPv4), ip4(client, broker, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 51100, DstPort: 9092, PSH: true, ACK: true, Window: 65535},
+		gopacket.Payload(kafkaRequest(0, 7, 11, "orders-svc", produce)))
+	add(eth(layers.EthernetTypeIPv4), ip4(broker, client, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 9092, DstPort: 51100, PSH: true, ACK: true, Window: 65535},
+		gopacket.Payload([]byte{0, 0, 0, 8, 0, 0, 0, 11, 0, 0, 0, 0}))
+	add(eth(layers.EthernetTypeIPv4), ip4(client, broker, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 51200, DstPort: 9092, PSH: true, ACK: true, Window: 65535},
+		gopacket.Payload(kafkaRequest(1, 4, 12, "billing-consumer", fetch)))
+
+	// Overlay traffic: HTTP over VXLAN, ICMP over GRE, IPv6 in IPv4 and a
+	// QinQ-tagged frame.
+	vtep1, vtep2 := net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2)
+	pod1, pod2 := net.IPv4(172, 16, 1, 5), net.IPv4(172, 16, 2, 7)
+	podMAC1 := net.HardwareAddr{0x02, 0x42, 0xac, 0x10, 0x01, 0x05}
+	podMAC2 := net.HardwareAddr{0x02, 0x42, 0xac, 0x10, 0x02, 0x07}
+	add(eth(layers.EthernetTypeIPv4), ip4(vtep1, vtep2, layers.IPProtocolUDP),
+		&layers.UDP{SrcPort: 49152, DstPort: 4789},
+		&layers.VXLAN{ValidIDFlag: true, VNI: 4096},
+		&layers.Ethernet{SrcMAC: podMAC1, DstMAC: podMAC2, EthernetType: layers.EthernetTypeIPv4},
+		ip4(pod1, pod2, layers.IPProtocolTCP),
+		&layers.TCP{SrcPort: 40000, DstPort: 8080, Seq: 1, PSH: true, ACK: true, Window: 64240},
+		gopacket.Payload("GET /healthz HTTP/1.1\r\nHost: pod2\r\n\r\n"))
+	add(eth(layers.EthernetTypeIPv4), ip4(vtep1, vtep2, layers.IPProtocolGRE),
+		&layers.GRE{KeyPresent: true, Key: 42, Protocol: layers.EthernetTypeIPv4},
+		ip4(pod1, pod2, layers.IPProtocolICMPv4),
+		&layers.ICMPv4{TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeEchoRequest, 0), Id: 7, Seq: 1})
+	add(eth(layers.EthernetTypeIPv4), ip4(vtep1, vtep2, layers.IPProtocolIPv6),
+		ip6(client6, web6, layers.IPProtocolUDP),
+		&layers.UDP{SrcPort: 53001, DstPort: 53}, dns)
+	add(&layers.Ethernet{SrcMAC: clientMAC, DstMAC: routerMAC, EthernetType: layers.EthernetTypeQinQ},
+		&layers.Dot1Q{VLANIdentifier: 100, Type: layers.EthernetTypeDot1Q},
+		&layers.Dot1Q{VLANIdentifier: 200, Type: layers.EthernetTypeIPv4},
+		ip4(client, resolver, layers.IPProtocolUDP),
+		&layers.UDP{SrcPort: 53002, DstPort: 53}, dns)
+
+	return frames
+}
+
+// sshKexInit builds an unencrypted SSH_MSG_KEXINIT binary packet using the
+// same lists in both directions.
+func sshKexInit(kex, hostKey, ciphers, macs, comp string) []byte {
+	payload := []byte{20}
+	payload = append(payload, make([]byte, 16)...)
+	for _, list := range []string{kex, hostKey, ciphers, ciphers, macs, macs, comp, comp, "", ""} {
+		payload = binary.BigEndian.AppendUint32(payload, uint32(len(list)))
+		payload = append(payload, list...)
+	}
+	payload = append(payload, 0, 0, 0, 0, 0)
+
+	padLen := 8 - (5+len(payload))%8
+	if padLen < 4 {
+		padLen += 8
+	}
+	pkt := binary.BigEndian.AppendUint32(nil, uint32(1+len(payload)+padLen))
+	pkt = append(pkt, byte(padLen))
+	pkt = append(pkt, payload...)
+	return append(pkt, make([]byte, padLen)...)
+}
+
+func h2Frame(typ, flags uint8, stream uint32, body []byte) []byte {
+	n := len(body)
+	frame := []byte{byte(n >> 16), byte(n >> 8), byte(n), typ, flags}
+	frame = binary.BigEndian.AppendUint32(frame, stream)
+	return append(frame, body...)
+}
+
+func hpackBlock(fields ...string) []byte {
+	var buf bytes.Buffer
+	enc := hpack.NewEncoder(&buf)
+	for i := 0; i+1 < len(fields); i += 2 {
+		enc.WriteField(hpack.HeaderField{Name: fields[i], Value: fields[i+1]})
+	}
+	return buf.Bytes()
+}
+
+// wsFrame builds a final WebSocket frame, masked when mask is set.
+func wsFrame(opcode byte, mask, payload []byte) []byte {
+	frame := []byte{0x80 | opcode}
+	var maskBit byte
+	if mask != nil {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, maskBit|byte(n))
+	case n < 1<<16:
+		frame = binary.BigEndian.AppendUint16(append(frame, maskBit|126), uint16(n))
+	default:
+		frame = binary.BigEndian.AppendUint64(append(frame, maskBit|127), uint64(n))
+	}
+	if mask == nil {
+		return append(frame, payload...)
+	}
+	frame = append(frame, mask...)
+	for i, c := range payload {
+		frame = append(frame, c^mask[i%4])
+	}
+	return frame
+}
+
+// nbnsRegistration builds a broadcast NetBIOS name registration request.
+func nbnsRegistration(id uint16, name string, suffix byte, ip net.IP) []byte {
+	raw := []byte(fmt.Sprintf("%-15s", name))
+	raw = append(raw, suffix)
+	msg := binary.BigEndian.AppendUint16(nil, id)
+	msg = append(msg, 0x29, 0x10, 0, 1, 0, 0, 0, 0, 0, 1, 32)
+	for _, b := range raw {
+		msg = append(msg, 'A'+b>>4, 'A'+b&0x0f)
+	}
+	msg = append(msg, 0, 0, 0x20, 0, 1)
+	msg = append(msg, 0xc0, 0x0c, 0, 0x20, 0, 1, 0, 0x04, 0x93, 0xe0, 0, 6, 0, 0)
+	return append(msg, ip.To4()...)
+}
+
+// ber encodes a BER TLV with a definite length.
+func ber(tag byte, value ...byte) []byte {
+	n := len(value)
+	switch {
+	case n < 0x80:
+		return append([]byte{tag, byte(n)}, value...)
+	case n < 0x100:
+		return append([]byte{tag, 0x81, byte(n)}, value...)
+	}
+	return append([]byte{tag, 0x82, byte(n >> 8), byte(n)}, value...)
+}
+
+func putNTPTime(b []byte, t time.Time) {
+	binary.BigEndian.PutUint32(b[0:4], uint32(t.Unix()+2208988800))
+	binary.BigEndian.PutUint32(b[4:8], uint32((uint64(t.Nanosecond())<<32)/1e9))
+}
+
+// mqttPacket prefixes an MQTT body with its fixed header.
+func mqttPacket(header byte, body []byte) []byte {
+	pkt := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n & 0x7f)
+		n >>= 7
+		if n > 0 {
+			b |= 0x80
+		}
+		pkt = append(pkt, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(pkt, body...)
+}
+
+func amqpFrame(typ byte, channel uint16, payload []byte) []byte {
+	frame := binary.BigEndian.AppendUint16([]byte{typ}, channel)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(payload)))
+	return append(append(frame, payload...), 0xce)
+}
+
+// kafkaRequest builds a request with a v1 (non-flexible) header.
+func kafkaRequest(api, version uint16, correlation uint32, clientID string, body []byte) []byte {
+	req := binary.BigEndian.AppendUint16(make([]byte, 4), api)
+	req = binary.BigEndian.AppendUint16(req, version)
+	req = binary.BigEndian.AppendUint32(req, correlation)
+	req = append(binary.BigEndian.AppendUint16(req, uint16(len(clientID))), clientID...)
+	req = append(req, body...)
+	binary.BigEndian.PutUint32(req, uint32(len(req)-4))
+	return req
+}
@@ -0,0 +1,78 @@
+package network
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/fe-dudu/netmon/internal/types"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+const (
+	BackendPcap     = "pcap"
+	BackendAFPacket = "afpacket"
+)
+
+type PacketSource = types.PacketSource
+
+type pcapSource struct {
+	name   string
+	handle *pcap.Handle
+}
+
+func OpenSource(iface string, opts types.CaptureOptions) (PacketSource, error) {
+	switch opts.Backend {
+	case "", BackendPcap:
+		handle, err := OpenHandle(iface)
+		if err != nil {
+			return nil, err
+		}
+		return &pcapSource{name: iface, handle: handle}, nil
+	case BackendAFPacket:
+		return openAFPacket(iface, opts)
+	default:
+		return nil, fmt.Errorf("unknown capture backend %q (want %s or %s)", opts.Backend, BackendPcap, BackendAFPacket)
+	}
+}
+
+func OpenFile(path string) (PacketSource, error) {
+	handle, err := pcap.OpenOffline(path)
+	if err != nil {
+		return nil, err
+	}
+	return &pcapSource{name: filepath.Base(path), handle: handle}, nil
+}
+
+func (s *pcapSource) Name() string {
+	return s.name
+}
+
+func (s *pcapSource) LinkType() layers.LinkType {
+	return s.handle.LinkType()
+}
+
+func (s *pcapSource) ReadPacketData() ([]byte, gopacket.CaptureInfo, error) {
+	return s.handle.ReadPacketData()
+}
+
+func (s *pcapSource) SetFilter(expr string) error {
+	return s.handle.SetBPFFilter(expr)
+}
+
+func (s *pcapSource) Stats() (types.SourceStats, error) {
+	st, err := s.handle.Stats()
+	if err != nil {
+		return types.SourceStats{}, err
+	}
+	return types.SourceStats{
+		Received:  uint64(st.PacketsReceived),
+		Dropped:   uint64(st.PacketsDropped),
+		IfDropped: uint64(st.PacketsIfDropped),
+	}, nil
+}
+
+func (s *pcapSource) Close() {
+	s.handle.Close()
+}
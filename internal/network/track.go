@@ -0,0 +1,205 @@
+package network
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fe-dudu/netmon/internal/types"
+)
+
+const (
+	maxLeases       = 1024
+	maxEchoRequests = 4096
+	echoTimeout     = 30 * time.Second
+)
+
+func track(a *types.App, pkt *types.PacketInfo) {
+	if pkt.Inner != nil {
+		track(a, pkt.Inner)
+	}
+
+	a.FlowsMutex.Lock()
+	flow := trackFlow(a, pkt)
+	switch meta := pkt.Meta.(type) {
+	case *types.SSHInfo:
+		trackSSH(flow, pkt, meta)
+	case *types.HTTP2Info:
+		trackHTTP2(flow, pkt, meta)
+	case *types.WebSocketInfo:
+		trackWebSocket(flow, pkt, meta)
+	case *types.TCPSegment:
+		switch {
+		case flow != nil && flow.HTTP2 != nil:
+			trackHTTP2Segment(flow, pkt, meta.Payload)
+		case flow != nil && flow.WebSocket != nil:
+			trackWebSocketSegment(flow, pkt, meta.Payload)
+		}
+		pkt.Meta = nil
+	default:
+		if flow != nil && flow.WebSocket != nil {
+			trackWebSocketSegment(flow, pkt, nil)
+		}
+	}
+	a.FlowsMutex.Unlock()
+
+	switch meta := pkt.Meta.(type) {
+	case *types.DHCPInfo:
+		trackLease(a, *pkt, meta)
+	case *types.ICMPInfo:
+		trackEcho(a, pkt, meta)
+	case *types.DiscoveryInfo:
+		trackNeighbor(a, pkt, meta)
+	case *types.NTPInfo:
+		ntpOffset(pkt, meta)
+	case *types.MessagingInfo:
+		trackTopics(a, pkt, meta)
+	}
+}
+
+// ntpOffset appends the clock offset and round-trip delay of an NTP reply,
+// using the capture time as the client's receive time (RFC 5905 section 8).
+func ntpOffset(pkt *types.PacketInfo, ntp *types.NTPInfo) {
+	if ntp.Origin.IsZero() || ntp.Receive.IsZero() || ntp.Transmit.IsZero() {
+		return
+	}
+	arrived := pkt.Timestamp
+	offset := (ntp.Receive.Sub(ntp.Origin) + ntp.Transmit.Sub(arrived)) / 2
+	delay := arrived.Sub(ntp.Origin) - ntp.Transmit.Sub(ntp.Receive)
+	if delay < 0 || delay > time.Minute {
+		// The request was not sent from this host's clock.
+		return
+	}
+	pkt.Detail += fmt.Sprintf(" offset=%+.3fms delay=%.3fms",
+		float64(offset)/float64(time.Millisecond), float64(delay)/float64(time.Millisecond))
+}
+
+func trackEcho(a *types.App, pkt *types.PacketInfo, echo *types.ICMPInfo) {
+	if a.EchoRequests == nil {
+		a.EchoRequests = make(map[types.EchoKey]time.Time)
+	}
+
+	if !echo.Reply {
+		if len(a.EchoRequests) >= maxEchoRequests {
+			for key, sent := range a.EchoRequests {
+				if pkt.Timestamp.Sub(sent) > echoTimeout {
+					delete(a.EchoRequests, key)
+				}
+			}
+			if len(a.EchoRequests) >= maxEchoRequests {
+				clear(a.EchoRequests)
+			}
+		}
+		key := types.EchoKey{Src: pkt.SrcAddr, Dst: pkt.DstAddr, ID: echo.ID, Seq: echo.Seq}
+		a.EchoRequests[key] = pkt.Timestamp
+		return
+	}
+
+	key := types.EchoKey{Src: pkt.DstAddr, Dst: pkt.SrcAddr, ID: echo.ID, Seq: echo.Seq}
+	sent, ok := a.EchoRequests[key]
+	if !ok {
+		return
+	}
+	delete(a.EchoRequests, key)
+	if rtt := pkt.Timestamp.Sub(sent); rtt >= 0 {
+		pkt.Detail += fmt.Sprintf(" rtt=%.3fms", float64(rtt)/float64(time.Millisecond))
+	}
+}
+
+func leaseState(d *types.DHCPInfo) string {
+	if d.V6 {
+		switch d.MsgType {
+		case "Solicit":
+			return "soliciting"
+		case "Advertise":
+			return "offered"
+		case "Request", "Renew", "Rebind", "Confirm":
+			return "requesting"
+		case "Reply":
+			if d.Addr.IsValid() {
+				return "bound"
+			}
+		case "Release":
+			return "released"
+		case "Decline":
+			return "declined"
+		}
+		return ""
+	}
+
+	switch d.MsgType {
+	case "DISCOVER":
+		return "discovering"
+	case "OFFER":
+		return "offered"
+	case "REQUEST":
+		return "requesting"
+	case "ACK":
+		return "bound"
+	case "NAK":
+		return "rejected"
+	case "RELEASE":
+		return "released"
+	case "DECLINE":
+		return "declined"
+	}
+	return ""
+}
+
+func trackLease(a *types.App, pkt types.PacketInfo, d *types.DHCPInfo) {
+	state := leaseState(d)
+	if d.ClientID == "" || state == "" {
+		return
+	}
+	key := d.ClientID
+	if d.V6 {
+		key = "v6/" + key
+	}
+
+	a.LeasesMutex.Lock()
+	defer a.LeasesMutex.Unlock()
+
+	if a.Leases == nil {
+		a.Leases = make(map[string]*types.Lease)
+	}
+	l, ok := a.Leases[key]
+	if !ok {
+		if len(a.Leases) >= maxLeases {
+			evictOldestLease(a)
+		}
+		l = &types.Lease{ClientID: d.ClientID, ClientMAC: d.ClientMAC, V6: d.V6}
+		a.Leases[key] = l
+	}
+
+	l.State = state
+	l.Updated = pkt.Timestamp
+	if d.Addr.IsValid() {
+		l.Addr = d.Addr
+	}
+	if d.Hostname != "" {
+		l.Hostname = d.Hostname
+	}
+	switch {
+	case d.Server.IsValid():
+		l.Server = d.Server.String()
+	case d.V6 && d.ServerID != "":
+		l.Server = d.ServerID
+	case !d.V6 && (state == "offered" || state == "bound") && pkt.SrcAddr.IsValid():
+		l.Server = pkt.SrcAddr.String()
+	}
+	if d.LeaseTime > 0 {
+		l.LeaseTime = d.LeaseTime
+	}
+	if state == "bound" && l.LeaseTime > 0 {
+		l.Expires = pkt.Timestamp.Add(l.LeaseTime)
+	}
+}
+
+func evictOldestLease(a *types.App) {
+	var oldest string
+	for key, l := range a.Leases {
+		if oldest == "" || l.Updated.Before(a.Leases[oldest].Updated) {
+			oldest = key
+		}
+	}
+	delete(a.Leases, oldest)
+}
@@ -0,0 +1,73 @@
+package network
+
+import (
+	"fmt"
+
+	"github.com/fe-dudu/netmon/internal/packet"
+	"github.com/fe-dudu/netmon/internal/types"
+)
+
+// trackWebSocket follows the upgrade handshake carried by HTTP requests and
+// responses.
+func trackWebSocket(f *types.Flow, pkt *types.PacketInfo, info *types.WebSocketInfo) {
+	if f == nil || f.L4Proto != types.IPProtoTCP {
+		return
+	}
+	switch {
+	case info.Upgrade:
+		f.WebSocket = &types.WebSocketSession{Path: info.Path}
+	case info.Accept:
+		if f.WebSocket == nil {
+			f.WebSocket = &types.WebSocketSession{}
+		}
+		f.WebSocket.Open = true
+		f.Proto = "WebSocket"
+		countWebSocketFrames(f.WebSocket, info)
+		f.WebSocket.Remaining[flowDir(f, pkt)] = info.Missing
+	}
+}
+
+// trackWebSocketSegment frames a segment of an upgraded connection. payload
+// is nil when another dissector claimed the segment.
+func trackWebSocketSegment(f *types.Flow, pkt *types.PacketInfo, payload []byte) {
+	ws := f.WebSocket
+	if ws == nil || !ws.Open || pkt.PayloadLen == 0 {
+		return
+	}
+	dir := flowDir(f, pkt)
+	if payload == nil {
+		// The claimed bytes still belong to the frame in progress.
+		ws.Remaining[dir] -= min(ws.Remaining[dir], pkt.PayloadLen)
+		return
+	}
+	pkt.Proto = "WebSocket"
+	continued := ""
+	if rem := ws.Remaining[dir]; rem > 0 {
+		if rem >= len(payload) {
+			ws.Remaining[dir] -= len(payload)
+			pkt.Detail = fmt.Sprintf("continued len=%d", len(payload))
+			return
+		}
+		// The rest of the segment starts with the next frame.
+		ws.Remaining[dir] = 0
+		continued = fmt.Sprintf("continued len=%d; ", rem)
+		payload = payload[rem:]
+	}
+	info, ok := packet.ParseWebSocket(payload)
+	if !ok {
+		pkt.Detail = continued + fmt.Sprintf("len=%d (frame boundary lost)", len(payload))
+		return
+	}
+	countWebSocketFrames(ws, info)
+	ws.Remaining[dir] = info.Missing
+	pkt.Detail = continued + packet.DescribeWebSocket(info)
+}
+
+func countWebSocketFrames(ws *types.WebSocketSession, info *types.WebSocketInfo) {
+	ws.Frames += len(info.Frames)
+	for _, fr := range info.Frames {
+		if fr.CloseCode != 0 && ws.CloseCode == 0 {
+			ws.CloseCode = fr.CloseCode
+		}
+	}
+}
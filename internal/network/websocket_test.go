@@ -0,0 +1,79 @@
+package network
+
+import (
+	"bytes"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fe-dudu/netmon/internal/types"
+)
+
+var (
+	wsClient = netip.MustParseAddrPort("192.168.1.10:50700")
+	wsServer = netip.MustParseAddrPort("10.0.5.60:80")
+)
+
+// wsSegment tracks one segment of a WebSocket connection. A nil meta hands
+// the payload over as a raw segment, as the parser does for unclaimed ones.
+func wsSegment(a *types.App, fromClient bool, proto string, meta any, payload []byte) types.PacketInfo {
+	src, dst := wsClient, wsServer
+	if !fromClient {
+		src, dst = dst, src
+	}
+	pkt := types.PacketInfo{
+		Timestamp: time.Unix(1700000000, 0), Proto: proto,
+		SrcAddr: src.Addr(), DstAddr: dst.Addr(), SrcPort: src.Port(), DstPort: dst.Port(),
+		HasPorts: true, L4Proto: types.IPProtoTCP, Length: len(payload) + 54, PayloadLen: len(payload),
+		Meta: meta,
+	}
+	if meta == nil {
+		pkt.Meta = &types.TCPSegment{Payload: payload}
+	}
+	track(a, &pkt)
+	return pkt
+}
+
+func TestWebSocketFrameAfterContinuation(t *testing.T) {
+	a := newTestApp(1, 1)
+	wsSegment(a, true, "HTTP", &types.WebSocketInfo{Upgrade: true, Path: "/chat"}, []byte("GET /chat HTTP/1.1\r\n\r\n"))
+	wsSegment(a, false, "HTTP", &types.WebSocketInfo{Accept: true}, []byte("HTTP/1.1 101 Switching Protocols\r\n\r\n"))
+
+	big := wsFrame(0x2, nil, bytes.Repeat([]byte{0xab}, 3000))
+	first := wsSegment(a, false, "TCP", nil, big[:1400])
+	if !strings.Contains(first.Detail, "binary len=3000") {
+		t.Fatalf("first segment: %q", first.Detail)
+	}
+
+	// One segment carries the tail of the binary frame and the whole of a
+	// text frame; the framing has to survive into the next segment too.
+	tail := append(append([]byte(nil), big[1400:]...), wsFrame(0x1, nil, []byte("hi"))...)
+	pkt := wsSegment(a, false, "TCP", nil, tail)
+	want := "continued len=1604; text len=2"
+	if pkt.Proto != "WebSocket" || !strings.HasPrefix(pkt.Detail, want) {
+		t.Errorf("tail segment: %s %q, want WebSocket %q", pkt.Proto, pkt.Detail, want)
+	}
+	pkt = wsSegment(a, false, "TCP", nil, wsFrame(0x9, nil, nil))
+	if !strings.HasPrefix(pkt.Detail, "ping") {
+		t.Errorf("next segment: %q, want ping", pkt.Detail)
+	}
+}
+
+func TestWebSocketClaimedSegment(t *testing.T) {
+	a := newTestApp(1, 1)
+	wsSegment(a, true, "HTTP", &types.WebSocketInfo{Upgrade: true, Path: "/chat"}, []byte("GET /chat HTTP/1.1\r\n\r\n"))
+	wsSegment(a, false, "HTTP", &types.WebSocketInfo{Accept: true}, []byte("HTTP/1.1 101 Switching Protocols\r\n\r\n"))
+
+	// A segment another dissector claimed keeps its label and detail.
+	payload := []byte("GET /x HTTP/1.1\r\n\r\n")
+	pkt := types.PacketInfo{
+		Timestamp: time.Unix(1700000000, 0), Proto: "HTTP", Detail: "GET /x HTTP/1.1",
+		SrcAddr: wsClient.Addr(), DstAddr: wsServer.Addr(), SrcPort: wsClient.Port(), DstPort: wsServer.Port(),
+		HasPorts: true, L4Proto: types.IPProtoTCP, Length: len(payload) + 54, PayloadLen: len(payload),
+	}
+	track(a, &pkt)
+	if pkt.Proto != "HTTP" || pkt.Detail != "GET /x HTTP/1.1" {
+		t.Errorf("claimed segment became %s %q", pkt.Proto, pkt.Detail)
+	}
+}
@@ -0,0 +1,140 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fe-dudu/netmon/internal/packet"
+	"github.com/fe-dudu/netmon/internal/types"
+)
+
+type fileSink struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	format func(*bufio.Writer, types.PacketInfo) error
+}
+
+type jsonRecord struct {
+	Timestamp  time.Time   `json:"ts"`
+	Iface      string      `json:"iface"`
+	Proto      string      `json:"proto"`
+	Src        string      `json:"src"`
+	Dst        string      `json:"dst"`
+	SrcPort    uint16      `json:"src_port,omitempty"`
+	DstPort    uint16      `json:"dst_port,omitempty"`
+	IPVersion  uint8       `json:"ip_version,omitempty"`
+	L4Proto    uint8       `json:"l4_proto,omitempty"`
+	TTL        uint8       `json:"ttl,omitempty"`
+	Length     int         `json:"len"`
+	PayloadLen int         `json:"payload_len"`
+	VLAN       uint16      `json:"vlan,omitempty"`
+	OuterVLAN  uint16      `json:"outer_vlan,omitempty"`
+	EtherType  uint16      `json:"ethertype,omitempty"`
+	SrcMAC     string      `json:"src_mac,omitempty"`
+	DstMAC     string      `json:"dst_mac,omitempty"`
+	TCPFlags   string      `json:"tcp_flags,omitempty"`
+	Detail     string      `json:"detail,omitempty"`
+	Tunnel     string      `json:"tunnel,omitempty"`
+	Inner      *jsonRecord `json:"inner,omitempty"`
+}
+
+func Open(kind, path string) (types.Sink, error) {
+	if path == "" {
+		return nil, fmt.Errorf("output %q: path is required", kind)
+	}
+
+	var format func(*bufio.Writer, types.PacketInfo) error
+	switch kind {
+	case "jsonl", "json":
+		format = writeJSON
+	case "text", "":
+		format = writeText
+	default:
+		return nil, fmt.Errorf("output %q: unsupported type (want jsonl or text)", kind)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{file: f, writer: bufio.NewWriter(f), format: format}, nil
+}
+
+func (s *fileSink) Write(pkt types.PacketInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.format(s.writer, pkt)
+}
+
+func (s *fileSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writer.Flush()
+}
+
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.writer.Flush(); err != nil {
+		s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
+
+func writeJSON(w *bufio.Writer, pkt types.PacketInfo) error {
+	b, err := json.Marshal(newRecord(pkt))
+	if err != nil {
+		return err
+	}
+	w.Write(b)
+	return w.WriteByte('\n')
+}
+
+func newRecord(pkt types.PacketInfo) *jsonRecord {
+	rec := &jsonRecord{
+		Timestamp:  pkt.Timestamp,
+		Iface:      pkt.Iface,
+		Proto:      pkt.Proto,
+		SrcPort:    pkt.SrcPort,
+		DstPort:    pkt.DstPort,
+		IPVersion:  pkt.IPVersion,
+		L4Proto:    pkt.L4Proto,
+		TTL:        pkt.TTL,
+		Length:     pkt.Length,
+		PayloadLen: pkt.PayloadLen,
+		VLAN:       pkt.VLAN,
+		OuterVLAN:  pkt.OuterVLAN,
+		EtherType:  pkt.EtherType,
+		TCPFlags:   packet.FormatTCPFlags(pkt.TCPFlags),
+		Detail:     pkt.Detail,
+		Tunnel:     pkt.Tunnel,
+	}
+	if pkt.SrcAddr.IsValid() {
+		rec.Src = pkt.SrcAddr.String()
+	}
+	if pkt.DstAddr.IsValid() {
+		rec.Dst = pkt.DstAddr.String()
+	}
+	if !pkt.SrcMAC.IsZero() {
+		rec.SrcMAC = pkt.SrcMAC.String()
+	}
+	if !pkt.DstMAC.IsZero() {
+		rec.DstMAC = pkt.DstMAC.String()
+	}
+	if pkt.Inner != nil {
+		rec.Inner = newRecord(*pkt.Inner)
+	}
+	return rec
+}
+
+func writeText(w *bufio.Writer, pkt types.PacketInfo) error {
+	_, err := fmt.Fprintf(w, "%s %-8s %-6s %s -> %s %s\n",
+		pkt.Timestamp.Format("2006-01-02T15:04:05.000000"), pkt.Iface, pkt.Proto, pkt.Src(), pkt.Dst(), pkt.Detail)
+	return err
+}
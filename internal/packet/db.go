@@ -0,0 +1,609 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fe-dudu/netmon/internal/types"
+)
+
+const maxQueryLen = 160
+
+func init() {
+	Register(Dissector{
+		Name:      "PGSQL",
+		Priority:  40,
+		Transport: types.IPProtoTCP,
+		Ports:     []uint16{5432},
+		Match:     func(c *Context) bool { return isPostgresStartup(c.Payload) },
+		Detail:    func(c *Context) string { return c.postgresDetail() },
+		Color:     "skyblue",
+	})
+	Register(Dissector{
+		Name:      "MySQL",
+		Priority:  45,
+		Transport: types.IPProtoTCP,
+		Ports:     []uint16{3306},
+		Match:     func(c *Context) bool { return isMySQLGreeting(c.Payload) },
+		Detail:    func(c *Context) string { return c.mysqlDetail() },
+		Color:     "skyblue",
+	})
+	Register(Dissector{
+		Name:      "Redis",
+		Priority:  50,
+		Transport: types.IPProtoTCP,
+		Ports:     []uint16{6379},
+		Match:     func(c *Context) bool { return isRESPCommand(c.Payload) },
+		Detail:    func(c *Context) string { return c.redisDetail() },
+		Color:     "skyblue",
+	})
+	types.ProtocolFilters = append(types.ProtocolFilters, types.FilterChoice{
+		Label:  "DB",
+		Desc:   "PostgreSQL, MySQL and Redis wire protocols (L7)",
+		Protos: []string{"PGSQL", "MySQL", "Redis"},
+	})
+}
+
+// fromServer guesses the direction from the ports: servers listen on the
+// lower one.
+func (c *Context) fromServer() bool {
+	return c.SrcPort < c.DstPort
+}
+
+// SQLDialect selects how RedactSQL reads quotes that differ between
+// databases.
+type SQLDialect int
+
+const (
+	// DialectPostgres treats "..." as an identifier.
+	DialectPostgres SQLDialect = iota
+	// DialectMySQL treats "..." as a string literal and `...` as an
+	// identifier.
+	DialectMySQL
+)
+
+// query formats statement text for the detail column, replacing literals
+// when redaction is enabled.
+func (c *Context) query(q []byte, dialect SQLDialect) string {
+	s := strings.Join(strings.Fields(printable(bytes.TrimRight(q, "\x00"))), " ")
+	if c.p.RedactQueries {
+		s = RedactSQL(s, dialect)
+	}
+	if len(s) > maxQueryLen {
+		s = s[:maxQueryLen-3] + "..."
+	}
+	return s
+}
+
+// RedactSQL replaces string and numeric literals with '?'. Quoted strings
+// honour both doubled quotes and backslash escapes, so an ambiguous literal
+// is over-redacted rather than cut short. Quoted identifiers are kept as
+// they are; which quotes those are depends on the dialect. PostgreSQL
+// dollar-quoted bodies are dropped.
+func RedactSQL(q string, dialect SQLDialect) string {
+	identQuote := byte('"')
+	if dialect == DialectMySQL {
+		identQuote = '`'
+	}
+	var b strings.Builder
+	for i := 0; i < len(q); i++ {
+		ch := q[i]
+		switch {
+		case ch == identQuote:
+			end := identEnd(q, i)
+			b.WriteString(q[i:end])
+			i = end - 1
+		case ch == '\'' || ch == '"':
+			i = quotedEnd(q, i)
+			b.WriteByte('?')
+		case strings.IndexByte("EeNnBbXx", ch) >= 0 && i+1 < len(q) && q[i+1] == '\'' && (i == 0 || !isIdentByte(q[i-1])):
+			i = quotedEnd(q, i+1)
+			b.WriteByte('?')
+		case ch == '$' && (i == 0 || !isIdentByte(q[i-1])) && dollarTag(q[i:]) != "":
+			tag := dollarTag(q[i:])
+			if end := strings.Index(q[i+len(tag):], tag); end >= 0 {
+				i += len(tag) + end + len(tag) - 1
+			} else {
+				i = len(q)
+			}
+			b.WriteByte('?')
+		case ch >= '0' && ch <= '9' && (i == 0 || !isIdentByte(q[i-1])):
+			for i+1 < len(q) && (isIdentByte(q[i+1]) || q[i+1] == '.') {
+				i++
+			}
+			b.WriteByte('?')
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
+
+// quotedEnd returns the index of the quote closing the string that opens at
+// q[start], or len(q) when it is unterminated.
+func quotedEnd(q string, start int) int {
+	quote := q[start]
+	for i := start + 1; i < len(q); i++ {
+		switch q[i] {
+		case '\\':
+			i++
+		case quote:
+			if i+1 < len(q) && q[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(q)
+}
+
+// identEnd returns the index just past the quoted identifier that opens at
+// q[start]. Doubled quotes are part of the name.
+func identEnd(q string, start int) int {
+	quote := q[start]
+	for i := start + 1; i < len(q); i++ {
+		if q[i] != quote {
+			continue
+		}
+		if i+1 < len(q) && q[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(q)
+}
+
+// dollarTag returns the opening $tag$ of a PostgreSQL dollar-quoted string,
+// or "" when q does not start with one. Positional parameters such as $1
+// are not tags.
+func dollarTag(q string) string {
+	for i := 1; i < len(q); i++ {
+		switch ch := q[i]; {
+		case ch == '$':
+			return q[:i+1]
+		case ch >= '0' && ch <= '9' && i == 1, !isIdentByte(ch):
+			return ""
+		}
+	}
+	return ""
+}
+
+func isIdentByte(ch byte) bool {
+	return ch == '_' || ch == '$' || ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z'
+}
+
+func joinDetails(parts []string) string {
+	if len(parts) > 3 {
+		parts = append(parts[:3], fmt.Sprintf("+%d more", len(parts)-3))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// PostgreSQL frontend/backend protocol 3.0.
+
+const (
+	pgProtocol3   = 196608
+	pgSSLRequest  = 80877103
+	pgGSSENC      = 80877104
+	pgCancelCode  = 80877102
+	maxPGMessages = 32
+)
+
+func isPostgresStartup(b []byte) bool {
+	if len(b) < 8 || int(binary.BigEndian.Uint32(b[0:4])) != len(b) {
+		return false
+	}
+	switch binary.BigEndian.Uint32(b[4:8]) {
+	case pgProtocol3:
+		return len(b) > 8 && b[len(b)-1] == 0
+	case pgSSLRequest, pgGSSENC:
+		return len(b) == 8
+	case pgCancelCode:
+		return len(b) == 16
+	}
+	return false
+}
+
+func (c *Context) postgresDetail() string {
+	b := c.Payload
+	if isPostgresStartup(b) {
+		switch binary.BigEndian.Uint32(b[4:8]) {
+		case pgSSLRequest:
+			return "SSLRequest"
+		case pgGSSENC:
+			return "GSSENCRequest"
+		case pgCancelCode:
+			return "CancelRequest"
+		}
+		var parts []string
+		fields := bytes.Split(b[8:len(b)-1], []byte{0})
+		for i := 0; i+1 < len(fields); i += 2 {
+			switch key := string(fields[i]); key {
+			case "user", "database", "application_name":
+				parts = append(parts, key+"="+printable(fields[i+1]))
+			}
+		}
+		return "startup " + strings.Join(parts, " ")
+	}
+	if len(b) == 1 && (b[0] == 'S' || b[0] == 'N') {
+		return "SSL response " + string(b)
+	}
+
+	server := c.fromServer()
+	var parts, names []string
+	for n := 0; len(b) >= 5 && n < maxPGMessages; n++ {
+		typ := b[0]
+		length := int(binary.BigEndian.Uint32(b[1:5]))
+		if length < 4 {
+			break
+		}
+		body := b[5:]
+		if length-4 < len(body) {
+			body = body[:length-4]
+		}
+		if desc, name := pgMessage(c, typ, body, server); desc != "" {
+			parts = append(parts, desc)
+		} else if name != "" {
+			names = append(names, name)
+		}
+		if 1+length > len(b) {
+			break
+		}
+		b = b[1+length:]
+	}
+	if len(parts) == 0 && len(names) == 0 {
+		return c.p.lenDetail(len(c.Payload))
+	}
+	if len(names) > 0 {
+		parts = append(parts, strings.Join(names, ","))
+	}
+	return joinDetails(parts)
+}
+
+// pgMessage returns a description for messages worth showing on their own
+// and just a name for the rest.
+func pgMessage(c *Context, typ byte, body []byte, server bool) (string, string) {
+	if server {
+		switch typ {
+		case 'R':
+			if len(body) < 4 {
+				return "", "Authentication"
+			}
+			switch code := binary.BigEndian.Uint32(body[0:4]); code {
+			case 0:
+				return "auth ok", ""
+			case 3:
+				return "auth cleartext", ""
+			case 5:
+				return "auth md5", ""
+			case 10:
+				mechs := strings.Fields(strings.ReplaceAll(string(body[4:]), "\x00", " "))
+				return "auth SASL " + printable([]byte(strings.Join(mechs, ","))), ""
+			case 11, 12:
+				return "", "SASLContinue"
+			default:
+				return fmt.Sprintf("auth code=%d", code), ""
+			}
+		case 'E', 'N':
+			kind := "error"
+			if typ == 'N' {
+				kind = "notice"
+			}
+			fields := map[byte]string{}
+			for _, f := range bytes.Split(body, []byte{0}) {
+				if len(f) > 1 {
+					fields[f[0]] = printable(f[1:])
+				}
+			}
+			return strings.TrimSpace(fmt.Sprintf("%s %s %s %s", kind, fields['S'], fields['C'], fields['M'])), ""
+		case 'C':
+			return "complete " + printable(bytes.TrimRight(body, "\x00")), ""
+		case 'Z':
+			return "", "ReadyForQuery"
+		case 'T':
+			return "", "RowDescription"
+		case 'D':
+			return "", "DataRow"
+		case 'S':
+			return "", "ParameterStatus"
+		case 'K':
+			return "", "BackendKeyData"
+		case '1':
+			return "", "ParseComplete"
+		case '2':
+			return "", "BindComplete"
+		}
+		return "", ""
+	}
+
+	switch typ {
+	case 'Q':
+		return "query " + c.query(body, DialectPostgres), ""
+	case 'P':
+		// Parse: statement name, then the query.
+		if i := bytes.IndexByte(body, 0); i >= 0 {
+			return "parse " + c.query(body[i+1:], DialectPostgres), ""
+		}
+	case 'p':
+		return "password (hidden)", ""
+	case 'X':
+		return "terminate", ""
+	case 'B':
+		return "", "Bind"
+	case 'E':
+		return "", "Execute"
+	case 'S':
+		return "", "Sync"
+	case 'D':
+		return "", "Describe"
+	case 'C':
+		return "", "Close"
+	case 'H':
+		return "", "Flush"
+	}
+	return "", ""
+}
+
+// MySQL client/server protocol.
+
+const (
+	mysqlClientConnectWithDB  = 0x00000008
+	mysqlClientSecureConn     = 0x00008000
+	mysqlClientPluginAuthLenc = 0x00200000
+)
+
+var mysqlCommands = map[byte]string{
+	0x01: "QUIT", 0x02: "INIT_DB", 0x03: "QUERY", 0x04: "FIELD_LIST",
+	0x08: "SHUTDOWN", 0x09: "STATISTICS", 0x0e: "PING", 0x11: "CHANGE_USER",
+	0x16: "STMT_PREPARE", 0x17: "STMT_EXECUTE", 0x19: "STMT_CLOSE",
+	0x1a: "STMT_RESET", 0x1f: "RESET_CONNECTION",
+}
+
+func mysqlPacket(b []byte) (seq byte, payload []byte, ok bool) {
+	if len(b) < 5 {
+		return 0, nil, false
+	}
+	n := int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+	payload = b[4:]
+	if n < len(payload) {
+		payload = payload[:n]
+	}
+	return b[3], payload, n > 0
+}
+
+func isMySQLGreeting(b []byte) bool {
+	seq, payload, ok := mysqlPacket(b)
+	if !ok || seq != 0 || len(payload) != len(b)-4 || payload[0] != 10 || len(payload) < 2 {
+		return false
+	}
+	end := bytes.IndexByte(payload[1:], 0)
+	return end > 0 && payload[1] >= '0' && payload[1] <= '9'
+}
+
+func (c *Context) mysqlDetail() string {
+	seq, payload, ok := mysqlPacket(c.Payload)
+	if !ok {
+		return c.p.lenDetail(len(c.Payload))
+	}
+
+	if c.fromServer() {
+		switch {
+		case seq == 0 && payload[0] == 10:
+			version := payload[1:]
+			if end := bytes.IndexByte(version, 0); end >= 0 {
+				version = version[:end]
+			}
+			return "greeting " + printable(version)
+		case payload[0] == 0xff && len(payload) >= 3:
+			code := binary.LittleEndian.Uint16(payload[1:3])
+			msg := payload[3:]
+			state := ""
+			if len(msg) >= 6 && msg[0] == '#' {
+				state, msg = string(msg[1:6]), msg[6:]
+			}
+			return strings.TrimSpace(fmt.Sprintf("error %d %s %s", code, state, c.query(msg, DialectMySQL)))
+		case payload[0] == 0x00 && len(payload) >= 2:
+			rows, _ := lenencInt(payload[1:])
+			return fmt.Sprintf("ok affected=%d", rows)
+		case payload[0] == 0xfe && len(payload) < 9:
+			return "eof"
+		}
+		return c.p.lenDetail(len(c.Payload))
+	}
+
+	// Client: the handshake response follows the greeting, commands start
+	// a new sequence.
+	if seq == 1 && len(payload) >= 32 {
+		return mysqlHandshake(payload)
+	}
+	if seq != 0 {
+		return c.p.lenDetail(len(c.Payload))
+	}
+	name, known := mysqlCommands[payload[0]]
+	if !known {
+		return fmt.Sprintf("command 0x%02x", payload[0])
+	}
+	switch payload[0] {
+	case 0x03, 0x16:
+		return strings.ToLower(name) + " " + c.query(payload[1:], DialectMySQL)
+	case 0x02:
+		return "use " + printable(payload[1:])
+	}
+	return name
+}
+
+func mysqlHandshake(p []byte) string {
+	caps := binary.LittleEndian.Uint32(p[0:4])
+	if len(p) == 32 {
+		return "SSLRequest"
+	}
+	rest := p[32:]
+	end := bytes.IndexByte(rest, 0)
+	if end < 0 {
+		return "login"
+	}
+	parts := []string{"login user=" + printable(rest[:end])}
+	rest = rest[end+1:]
+
+	switch {
+	case caps&mysqlClientPluginAuthLenc != 0:
+		n, size := lenencInt(rest)
+		if size == 0 || size+int(n) > len(rest) {
+			return parts[0]
+		}
+		rest = rest[size+int(n):]
+	case caps&mysqlClientSecureConn != 0:
+		if len(rest) < 1 || 1+int(rest[0]) > len(rest) {
+			return parts[0]
+		}
+		rest = rest[1+int(rest[0]):]
+	default:
+		end := bytes.IndexByte(rest, 0)
+		if end < 0 {
+			return parts[0]
+		}
+		rest = rest[end+1:]
+	}
+	if caps&mysqlClientConnectWithDB != 0 {
+		if end := bytes.IndexByte(rest, 0); end > 0 {
+			parts = append(parts, "database="+printable(rest[:end]))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// lenencInt decodes a MySQL length-encoded integer and returns its size.
+func lenencInt(b []byte) (uint64, int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	switch b[0] {
+	case 0xfc:
+		if len(b) >= 3 {
+			return uint64(binary.LittleEndian.Uint16(b[1:3])), 3
+		}
+	case 0xfd:
+		if len(b) >= 4 {
+			return uint64(b[1]) | uint64(b[2])<<8 | uint64(b[3])<<16, 4
+		}
+	case 0xfe:
+		if len(b) >= 9 {
+			return binary.LittleEndian.Uint64(b[1:9]), 9
+		}
+	default:
+		if b[0] < 0xfb {
+			return uint64(b[0]), 1
+		}
+	}
+	return 0, 0
+}
+
+// Redis serialization protocol (RESP).
+
+var redisSecretArgs = map[string]bool{"AUTH": true, "HELLO": true, "MIGRATE": true}
+
+func isRESPCommand(b []byte) bool {
+	if len(b) < 4 || b[0] != '*' || b[1] < '1' || b[1] > '9' {
+		return false
+	}
+	i := bytes.Index(b, []byte("\r\n"))
+	return i > 1 && i+1 < len(b)-1 && b[i+2] == '$'
+}
+
+func (c *Context) redisDetail() string {
+	b := c.Payload
+	if len(b) == 0 {
+		return c.p.lenDetail(0)
+	}
+	switch b[0] {
+	case '*':
+		if args := respArray(b); len(args) > 0 {
+			return c.redisCommand(args)
+		}
+		if c.fromServer() {
+			return "array"
+		}
+	case '+':
+		return "reply " + printable([]byte(FirstLine(b[1:])))
+	case '-':
+		return "error " + printable([]byte(FirstLine(b[1:])))
+	case ':':
+		return "integer " + printable([]byte(FirstLine(b[1:])))
+	case '$':
+		if bytes.HasPrefix(b, []byte("$-1")) {
+			return "nil"
+		}
+		return "bulk " + c.p.lenDetail(len(b))
+	}
+	if !c.fromServer() {
+		// Inline command, e.g. from redis-cli over telnet.
+		if fields := bytes.Fields([]byte(FirstLine(b))); len(fields) > 0 {
+			return c.redisCommand(fields)
+		}
+	}
+	return c.p.lenDetail(len(b))
+}
+
+func (c *Context) redisCommand(args [][]byte) string {
+	name := strings.ToUpper(printable(args[0]))
+	parts := []string{name}
+	switch {
+	case redisSecretArgs[name]:
+		if len(args) > 1 {
+			parts = append(parts, "(credentials hidden)")
+		}
+	case c.p.RedactQueries:
+		// Keys often carry user or session identifiers, so they go too.
+		for range args[1:] {
+			parts = append(parts, "?")
+		}
+	case len(args) > 1:
+		// The first argument is usually the key.
+		parts = append(parts, printable(args[1]))
+		for _, arg := range args[2:] {
+			parts = append(parts, strconv.Quote(string(arg)))
+		}
+	}
+	s := strings.Join(parts, " ")
+	if len(s) > maxQueryLen {
+		s = s[:maxQueryLen-3] + "..."
+	}
+	return s
+}
+
+// respArray decodes a RESP array of bulk strings, the form clients use for
+// commands. Truncated arrays return the arguments decoded so far.
+func respArray(b []byte) [][]byte {
+	line, rest, ok := bytes.Cut(b[1:], []byte("\r\n"))
+	if !ok {
+		return nil
+	}
+	n, err := strconv.Atoi(string(line))
+	if err != nil || n <= 0 || n > 1024 {
+		return nil
+	}
+	args := make([][]byte, 0, min(n, 16))
+	for i := 0; i < n && len(rest) > 0 && rest[0] == '$'; i++ {
+		line, after, ok := bytes.Cut(rest[1:], []byte("\r\n"))
+		if !ok {
+			break
+		}
+		size, err := strconv.Atoi(string(line))
+		if err != nil || size < 0 {
+			break
+		}
+		if size > len(after) {
+			args = append(args, after)
+			break
+		}
+		args = append(args, after[:size])
+		rest = after[size:]
+		rest = bytes.TrimPrefix(rest, []byte("\r\n"))
+	}
+	return args
+}
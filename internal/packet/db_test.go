@@ -0,0 +1,86 @@
+package packet_test
+
+import (
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/fe-dudu/netmon/internal/packet"
+)
+
+func TestRedactSQL(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"single quoted", "SELECT * FROM users WHERE name = 'bob'", "SELECT * FROM users WHERE name = ?"},
+		{"doubled quote", "UPDATE t SET v = 'it''s secret' WHERE id = 1", "UPDATE t SET v = ? WHERE id = ?"},
+		{"backslash escape", `UPDATE t SET pw = 'it\'s secret'`, "UPDATE t SET pw = ?"},
+		{"escaped backslash", `SELECT 'a\\' , 'b'`, "SELECT ? , ?"},
+		{"escape string", `SELECT E'it\'s secret' AS x`, "SELECT ? AS x"},
+		{"national string", "SELECT N'secret'", "SELECT ?"},
+		{"dollar quoted", "SELECT $$it's a secret$$ AS x", "SELECT ? AS x"},
+		{"tagged dollar quoted", "SELECT $pw$a $$ secret$pw$, 1", "SELECT ?, ?"},
+		{"positional parameter", "SELECT * FROM t WHERE id = $1 AND n > 10", "SELECT * FROM t WHERE id = $1 AND n > ?"},
+		{"numbers", "SELECT 3.14, 42, col2 FROM t", "SELECT ?, ?, col2 FROM t"},
+		{"unterminated", "SELECT 'secret", "SELECT ?"},
+		{"unterminated dollar", "SELECT $$secret", "SELECT ?"},
+		{"identifier with dollar", "SELECT a$b FROM t", "SELECT a$b FROM t"},
+		{"identifier ending in e", "SELECT name'x'", "SELECT name?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, dialect := range []packet.SQLDialect{packet.DialectPostgres, packet.DialectMySQL} {
+				if got := packet.RedactSQL(tt.in, dialect); got != tt.want {
+					t.Errorf("RedactSQL(%q, %d) = %q, want %q", tt.in, dialect, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactSQLDialects(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+		dialect        packet.SQLDialect
+	}{
+		{"double quoted", `SELECT * FROM t WHERE email = "bob@x.com"`, "SELECT * FROM t WHERE email = ?", packet.DialectMySQL},
+		{"double quoted escape", `INSERT INTO t VALUES ("say \"hi\"", 2)`, "INSERT INTO t VALUES (?, ?)", packet.DialectMySQL},
+		{"backtick identifier", "SELECT `order 2` FROM `t1` WHERE id = 7", "SELECT `order 2` FROM `t1` WHERE id = ?", packet.DialectMySQL},
+		{"identifier", `SELECT "Total 2" FROM "orders" WHERE note = 'x'`, `SELECT "Total 2" FROM "orders" WHERE note = ?`, packet.DialectPostgres},
+		{"identifier with quotes", `SELECT "it's ""quoted""", 5 FROM t`, `SELECT "it's ""quoted""", ? FROM t`, packet.DialectPostgres},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := packet.RedactSQL(tt.in, tt.dialect); got != tt.want {
+				t.Errorf("RedactSQL(%q, %d) = %q, want %q", tt.in, tt.dialect, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedisDetail(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		redact  bool
+		want    string
+	}{
+		{"command", "*3\r\n$3\r\nSET\r\n$13\r\nsession:12345\r\n$5\r\nhello\r\n", false, `SET session:12345 "hello"`},
+		{"redacted command", "*3\r\n$3\r\nSET\r\n$13\r\nsession:12345\r\n$5\r\nhello\r\n", true, "SET ? ?"},
+		{"redacted key only", "*2\r\n$3\r\nGET\r\n$7\r\nuser:42\r\n", true, "GET ?"},
+		{"auth", "*2\r\n$4\r\nAUTH\r\n$6\r\nsecret\r\n", false, "AUTH (credentials hidden)"},
+		{"redacted auth", "*2\r\n$4\r\nAUTH\r\n$6\r\nsecret\r\n", true, "AUTH (credentials hidden)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame := tcpFrame(t, 50300, 6379, []byte(tt.payload))
+			p := packet.NewParser()
+			p.RedactQueries = tt.redact
+			pkt := p.Parse(frame, gopacket.CaptureInfo{CaptureLength: len(frame), Length: len(frame)}, layers.LinkTypeEthernet)
+			if pkt.Proto != "Redis" || pkt.Detail != tt.want {
+				t.Errorf("got %s %q, want Redis %q", pkt.Proto, pkt.Detail, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket/ip4defrag"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	fragTimeout      = 30 * time.Second
+	maxFragDatagrams = 1024
+	maxFragsPerIPv6  = 64
+)
+
+// Reassembler collects IP fragments across parser workers.
+type Reassembler struct {
+	mu        sync.Mutex
+	v4        *ip4defrag.IPv4Defragmenter
+	v6        map[frag6Key]*frag6
+	lastSweep time.Time
+}
+
+type frag6Key struct {
+	src, dst [16]byte
+	id       uint32
+}
+
+type frag6 struct {
+	parts   []frag6Part
+	proto   layers.IPProtocol
+	total   int
+	updated time.Time
+}
+
+type frag6Part struct {
+	offset int
+	data   []byte
+}
+
+type fragInfo struct {
+	ok          bool
+	id          uint32
+	offset      int
+	more        bool
+	proto       layers.IPProtocol
+	reassembled int
+	err         error
+}
+
+func NewReassembler() *Reassembler {
+	return &Reassembler{
+		v4: ip4defrag.NewIPv4Defragmenter(),
+		v6: make(map[frag6Key]*frag6),
+	}
+}
+
+func (r *Reassembler) sweep(ts time.Time) {
+	if ts.Sub(r.lastSweep) < fragTimeout/6 {
+		return
+	}
+	r.lastSweep = ts
+	cutoff := ts.Add(-fragTimeout)
+	r.v4.DiscardOlderThan(cutoff)
+	for key, f := range r.v6 {
+		if f.updated.Before(cutoff) {
+			delete(r.v6, key)
+		}
+	}
+}
+
+// addIPv4 stores a fragment and returns the upper-layer protocol and payload
+// once the datagram is complete.
+func (r *Reassembler) addIPv4(ip *layers.IPv4, ts time.Time) (layers.IPProtocol, []byte, error) {
+	// The defragmenter keeps the fragment, so it must not share the parser's
+	// reused layer or the capture buffer.
+	frag := *ip
+	frag.SrcIP = append([]byte(nil), ip.SrcIP...)
+	frag.DstIP = append([]byte(nil), ip.DstIP...)
+	frag.Payload = append([]byte(nil), ip.Payload...)
+	frag.Contents = nil
+	frag.Options = nil
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sweep(ts)
+
+	out, err := r.v4.DefragIPv4WithTimestamp(&frag, ts)
+	if err != nil || out == nil {
+		return 0, nil, err
+	}
+	return out.Protocol, out.Payload, nil
+}
+
+func (r *Reassembler) addIPv6(src, dst []byte, f fragInfo, data []byte, ts time.Time) (layers.IPProtocol, []byte, error) {
+	if f.offset+len(data) > 65535 {
+		return 0, nil, fmt.Errorf("fragment exceeds maximum datagram size")
+	}
+	var key frag6Key
+	copy(key.src[:], src)
+	copy(key.dst[:], dst)
+	key.id = f.id
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sweep(ts)
+
+	entry, ok := r.v6[key]
+	if !ok {
+		if len(r.v6) >= maxFragDatagrams {
+			return 0, nil, fmt.Errorf("too many incomplete datagrams")
+		}
+		entry = &frag6{}
+		r.v6[key] = entry
+	}
+	if len(entry.parts) >= maxFragsPerIPv6 {
+		delete(r.v6, key)
+		return 0, nil, fmt.Errorf("too many fragments")
+	}
+	entry.updated = ts
+	entry.insert(frag6Part{offset: f.offset, data: append([]byte(nil), data...)})
+	if f.offset == 0 {
+		entry.proto = f.proto
+	}
+	if !f.more {
+		entry.total = f.offset + len(data)
+	}
+	if entry.total == 0 || !entry.complete() {
+		return 0, nil, nil
+	}
+
+	payload := make([]byte, entry.total)
+	for _, part := range entry.parts {
+		if part.offset < entry.total {
+			copy(payload[part.offset:], part.data)
+		}
+	}
+	delete(r.v6, key)
+	return entry.proto, payload, nil
+}
+
+// insert keeps parts ordered by offset so complete can check coverage in
+// one pass.
+func (f *frag6) insert(part frag6Part) {
+	i := sort.Search(len(f.parts), func(i int) bool { return f.parts[i].offset > part.offset })
+	f.parts = append(f.parts, frag6Part{})
+	copy(f.parts[i+1:], f.parts[i:])
+	f.parts[i] = part
+}
+
+func (f *frag6) complete() bool {
+	end := 0
+	for _, part := range f.parts {
+		if part.offset > end {
+			return false
+		}
+		if e := part.offset + len(part.data); e > end {
+			end = e
+		}
+		if end >= f.total {
+			return true
+		}
+	}
+	return false
+}
+
+// reassemble walks IPv6 extension headers and feeds fragments to the
+// reassembler, decoding the upper layer of completed datagrams.
+func (p *Parser) reassemble(ts time.Time) {
+	p.frag = fragInfo{}
+	p.exts = p.exts[:0]
+	p.ipProto, p.ipPayload = 0, nil
+
+	if p.hasIPv4 && (p.ip4.Flags&layers.IPv4MoreFragments != 0 || p.ip4.FragOffset != 0) {
+		p.frag = fragInfo{
+			ok:     true,
+			id:     uint32(p.ip4.Id),
+			offset: int(p.ip4.FragOffset) * 8,
+			more:   p.ip4.Flags&layers.IPv4MoreFragments != 0,
+			proto:  p.ip4.Protocol,
+		}
+		if p.Reassembler == nil {
+			return
+		}
+		proto, payload, err := p.Reassembler.addIPv4(&p.ip4.IPv4, ts)
+		p.frag.err = err
+		if payload != nil {
+			p.frag.reassembled = len(payload)
+			p.ipProto, p.ipPayload = proto, payload
+			p.decodeUpper(proto, payload)
+		}
+		return
+	}
+
+	if p.hasIPv4 {
+		p.ipProto, p.ipPayload = p.ip4.Protocol, p.ip4.Payload
+	} else if p.hasIPv6 {
+		p.ipv6Extensions(ts)
+	}
+}
+
+func (p *Parser) ipv6Extensions(ts time.Time) {
+	next, data := p.ip6.NextHeader, p.ip6.Payload
+	if p.ip6.HopByHop != nil {
+		p.exts = append(p.exts, "HBH")
+		next = p.ip6.HopByHop.NextHeader
+	}
+
+	for {
+		switch next {
+		case layers.IPProtocolIPv6HopByHop, layers.IPProtocolIPv6Routing, layers.IPProtocolIPv6Destination:
+			if len(data) < 8 || len(data) < (int(data[1])+1)*8 {
+				return
+			}
+			p.exts = append(p.exts, extName(next))
+			next, data = layers.IPProtocol(data[0]), data[(int(data[1])+1)*8:]
+		case layers.IPProtocolAH:
+			if len(data) < 8 || len(data) < (int(data[1])+2)*4 {
+				return
+			}
+			p.exts = append(p.exts, "AH")
+			next, data = layers.IPProtocol(data[0]), data[(int(data[1])+2)*4:]
+		case layers.IPProtocolIPv6Fragment:
+			if len(data) < 8 {
+				return
+			}
+			p.exts = append(p.exts, "FRAG")
+			f := fragInfo{
+				ok:     true,
+				id:     binary.BigEndian.Uint32(data[4:8]),
+				offset: int(binary.BigEndian.Uint16(data[2:4]) &^ 7),
+				more:   data[3]&1 != 0,
+				proto:  layers.IPProtocol(data[0]),
+			}
+			data = data[8:]
+			if f.offset == 0 && !f.more {
+				// Atomic fragment (RFC 6946): the payload is complete.
+				next = f.proto
+				continue
+			}
+			p.frag = f
+			if p.Reassembler == nil {
+				return
+			}
+			proto, payload, err := p.Reassembler.addIPv6(p.ip6.SrcIP, p.ip6.DstIP, f, data, ts)
+			p.frag.err = err
+			if payload != nil {
+				p.frag.reassembled = len(payload)
+				p.ipProto, p.ipPayload = proto, payload
+				p.decodeUpper(proto, payload)
+			}
+			return
+		default:
+			p.ipProto, p.ipPayload = next, data
+			if len(p.exts) > 0 && !p.hasTCP && !p.hasUDP && !p.hasICMPv6 {
+				p.decodeUpper(next, data)
+			}
+			return
+		}
+	}
+}
+
+func extName(proto layers.IPProtocol) string {
+	switch proto {
+	case layers.IPProtocolIPv6HopByHop:
+		return "HBH"
+	case layers.IPProtocolIPv6Routing:
+		return "RH"
+	case layers.IPProtocolIPv6Destination:
+		return "DST"
+	}
+	return proto.String()
+}
+
+func (p *Parser) decodeUpper(proto layers.IPProtocol, payload []byte) {
+	dlp, ok := p.parsers[proto.LayerType()]
+	if !ok {
+		return
+	}
+	_ = dlp.DecodeLayers(payload, &p.upper)
+	p.markDecoded(p.upper)
+}
+
+// fragDetail describes fragmentation and extension headers for the detail
+// column.
+func (p *Parser) fragDetail(detail string) string {
+	var parts []string
+	if p.frag.ok {
+		switch {
+		case p.frag.reassembled > 0:
+			parts = append(parts, fmt.Sprintf("(reassembled %d bytes)", p.frag.reassembled))
+		case p.frag.err != nil:
+			parts = append(parts, fmt.Sprintf("fragment id=0x%x off=%d dropped: %v", p.frag.id, p.frag.offset, p.frag.err))
+		default:
+			frag := fmt.Sprintf("fragment %s id=0x%x off=%d", p.frag.proto, p.frag.id, p.frag.offset)
+			if p.frag.more {
+				frag += " MF"
+			}
+			parts = append(parts, frag)
+		}
+	}
+	if len(p.exts) > 0 {
+		parts = append(parts, "ext="+strings.Join(p.exts, ">"))
+	}
+	if len(parts) == 0 {
+		return detail
+	}
+	if detail != "" {
+		parts = append([]string{detail}, parts...)
+	}
+	return strings.Join(parts, " ")
+}
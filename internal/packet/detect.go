@@ -0,0 +1,173 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/fe-dudu/netmon/internal/types"
+)
+
+func init() {
+	Register(Dissector{
+		Name:     "DNS",
+		Priority: 10,
+		Match:    func(c *Context) bool { return c.p.hasDNS },
+		Detail: func(c *Context) string {
+			return c.p.dnsSummary()
+		},
+		Color: "green",
+	})
+	Register(Dissector{
+		Name:      "HTTP",
+		Priority:  35,
+		Transport: types.IPProtoTCP,
+		Match:     func(c *Context) bool { return LooksLikeHTTP(c.Payload) },
+		Detail: func(c *Context) string {
+			line := FirstLine(c.Payload)
+			if line == "" {
+				return ""
+			}
+			detail := sanitizeHTTPLine(line)
+			if ws := webSocketUpgrade(c.Payload); ws != nil {
+				c.Meta = ws
+				detail += " [websocket]"
+				if len(ws.Frames) > 0 {
+					detail += "; " + DescribeWebSocket(ws)
+				}
+			}
+			return detail
+		},
+		Color: "blue",
+	})
+	Register(Dissector{
+		Name:      "TLS",
+		Priority:  15,
+		Transport: types.IPProtoTCP,
+		Ports:     []uint16{443},
+		Match: func(c *Context) bool {
+			_, ok := DetectTLS(c.Payload)
+			return ok
+		},
+		Detail: func(c *Context) string {
+			detail, _ := DetectTLS(c.Payload)
+			return detail
+		},
+		Color: "yellow",
+	})
+	Register(Dissector{
+		Name:      "SSH",
+		Priority:  20,
+		Transport: types.IPProtoTCP,
+		Ports:     []uint16{22},
+		Match: func(c *Context) bool {
+			return bytes.HasPrefix(c.Payload, []byte("SSH-")) || isKexInit(c.Payload)
+		},
+		Detail: func(c *Context) string { return c.sshDetail() },
+		Color:  "teal",
+	})
+	Register(Dissector{
+		Name:      "QUIC",
+		Priority:  25,
+		Transport: types.IPProtoUDP,
+		Ports:     []uint16{443},
+		Match: func(c *Context) bool {
+			_, ok := DetectQUIC(c.Payload)
+			return ok
+		},
+		Detail: func(c *Context) string {
+			if kind, _ := DetectQUIC(c.Payload); kind != "" {
+				return kind
+			}
+			return c.p.lenDetail(len(c.Payload))
+		},
+		Color: "purple",
+	})
+}
+
+var httpMethods = [][]byte{
+	[]byte("GET "), []byte("POST "), []byte("PUT "), []byte("PATCH "), []byte("DELETE "),
+	[]byte("HEAD "), []byte("OPTIONS "), []byte("CONNECT "), []byte("TRACE "),
+}
+
+func LooksLikeHTTP(payload []byte) bool {
+	if bytes.HasPrefix(payload, []byte("HTTP/1.")) {
+		return true
+	}
+	for _, m := range httpMethods {
+		if bytes.HasPrefix(payload, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectTLS reports whether payload starts with a TLS record header and
+// returns a short description of the record.
+func DetectTLS(payload []byte) (string, bool) {
+	if len(payload) < 5 {
+		return "", false
+	}
+	contentType := payload[0]
+	if contentType < 20 || contentType > 24 {
+		return "", false
+	}
+	if payload[1] != 3 || payload[2] > 4 {
+		return "", false
+	}
+	length := binary.BigEndian.Uint16(payload[3:5])
+	if length == 0 || length > 16384+2048 {
+		return "", false
+	}
+
+	switch contentType {
+	case 20:
+		return "ChangeCipherSpec", true
+	case 21:
+		return "Alert", true
+	case 22:
+		if len(payload) > 5 {
+			switch payload[5] {
+			case 1:
+				return "ClientHello", true
+			case 2:
+				return "ServerHello", true
+			case 11:
+				return "Certificate", true
+			}
+		}
+		return "Handshake", true
+	case 23:
+		return "", true
+	default:
+		return "Heartbeat", true
+	}
+}
+
+// DetectQUIC recognises QUIC long header packets (RFC 9000 / RFC 9369).
+// Short header packets carry no version and can only be matched by port.
+func DetectQUIC(payload []byte) (string, bool) {
+	if len(payload) < 7 || payload[0]&0x80 == 0 {
+		return "", false
+	}
+	version := binary.BigEndian.Uint32(payload[1:5])
+	if version == 0 {
+		return "VersionNegotiation", true
+	}
+	if payload[0]&0x40 == 0 {
+		return "", false
+	}
+
+	var kinds [4]string
+	switch {
+	case version == 1 || version>>8 == 0xff0000:
+		kinds = [4]string{"Initial", "0-RTT", "Handshake", "Retry"}
+	case version == 0x6b3343cf:
+		kinds = [4]string{"Retry", "Initial", "0-RTT", "Handshake"}
+	default:
+		return "", false
+	}
+	if dcidLen := int(payload[5]); dcidLen > 20 || len(payload) < 6+dcidLen+1 {
+		return "", false
+	}
+	return kinds[(payload[0]>>4)&0x3], true
+}
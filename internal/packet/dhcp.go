@@ -0,0 +1,201 @@
+package packet
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/fe-dudu/netmon/internal/types"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var dhcpv6MsgNames = map[layers.DHCPv6MsgType]string{
+	layers.DHCPv6MsgTypeSolicit:            "Solicit",
+	layers.DHCPv6MsgTypeAdverstise:         "Advertise",
+	layers.DHCPv6MsgTypeRequest:            "Request",
+	layers.DHCPv6MsgTypeConfirm:            "Confirm",
+	layers.DHCPv6MsgTypeRenew:              "Renew",
+	layers.DHCPv6MsgTypeRebind:             "Rebind",
+	layers.DHCPv6MsgTypeReply:              "Reply",
+	layers.DHCPv6MsgTypeRelease:            "Release",
+	layers.DHCPv6MsgTypeDecline:            "Decline",
+	layers.DHCPv6MsgTypeReconfigure:        "Reconfigure",
+	layers.DHCPv6MsgTypeInformationRequest: "Information-Request",
+	layers.DHCPv6MsgTypeRelayForward:       "Relay-Forward",
+	layers.DHCPv6MsgTypeRelayReply:         "Relay-Reply",
+}
+
+func init() {
+	Register(Dissector{
+		Name:      "DHCP",
+		Priority:  120,
+		Transport: types.IPProtoUDP,
+		Ports:     []uint16{67, 68},
+		Match:     func(c *Context) bool { return isDHCPv4(c.Payload) },
+		Detail: func(c *Context) string {
+			info := c.p.decodeDHCPv4(c.Payload)
+			if info == nil {
+				return c.p.lenDetail(len(c.Payload))
+			}
+			c.Meta = info
+			return describeDHCP(info)
+		},
+		Color: "lime",
+	})
+	Register(Dissector{
+		Name:      "DHCPv6",
+		Priority:  200,
+		Transport: types.IPProtoUDP,
+		Ports:     []uint16{546, 547},
+		Detail: func(c *Context) string {
+			info := c.p.decodeDHCPv6(c.Payload)
+			if info == nil {
+				return c.p.lenDetail(len(c.Payload))
+			}
+			c.Meta = info
+			return describeDHCP(info)
+		},
+		Color: "lime",
+	})
+}
+
+func isDHCPv4(payload []byte) bool {
+	return len(payload) >= 240 &&
+		payload[2] <= 16 &&
+		binary.BigEndian.Uint32(payload[236:240]) == layers.DHCPMagic
+}
+
+func (p *Parser) decodeDHCPv4(payload []byte) *types.DHCPInfo {
+	if !isDHCPv4(payload) {
+		return nil
+	}
+	d := &p.dhcp4
+	if err := d.DecodeFromBytes(payload, gopacket.NilDecodeFeedback); err != nil {
+		return nil
+	}
+
+	info := &types.DHCPInfo{MsgType: "BOOTP"}
+	if d.HardwareType == layers.LinkTypeEthernet && len(d.ClientHWAddr) == 6 {
+		copy(info.ClientMAC[:], d.ClientHWAddr)
+		info.ClientID = info.ClientMAC.String()
+	}
+	if addr, ok := netip.AddrFromSlice(d.YourClientIP); ok && !addr.IsUnspecified() {
+		info.Addr = addr
+	} else if addr, ok := netip.AddrFromSlice(d.ClientIP); ok && !addr.IsUnspecified() {
+		info.Addr = addr
+	}
+
+	for _, o := range d.Options {
+		switch o.Type {
+		case layers.DHCPOptMessageType:
+			if len(o.Data) == 1 {
+				info.MsgType = strings.ToUpper(layers.DHCPMsgType(o.Data[0]).String())
+			}
+		case layers.DHCPOptRequestIP:
+			if addr, ok := netip.AddrFromSlice(o.Data); ok && !info.Addr.IsValid() {
+				info.Addr = addr
+			}
+		case layers.DHCPOptHostname:
+			info.Hostname = printable(o.Data)
+		case layers.DHCPOptLeaseTime:
+			if len(o.Data) == 4 {
+				info.LeaseTime = time.Duration(binary.BigEndian.Uint32(o.Data)) * time.Second
+			}
+		case layers.DHCPOptServerID:
+			info.Server, _ = netip.AddrFromSlice(o.Data)
+		}
+	}
+	return info
+}
+
+func (p *Parser) decodeDHCPv6(payload []byte) *types.DHCPInfo {
+	d := &p.dhcp6
+	if err := d.DecodeFromBytes(payload, gopacket.NilDecodeFeedback); err != nil {
+		return nil
+	}
+
+	info := &types.DHCPInfo{V6: true, MsgType: dhcpv6MsgNames[d.MsgType]}
+	if info.MsgType == "" {
+		info.MsgType = fmt.Sprintf("type-%d", d.MsgType)
+	}
+	for _, o := range d.Options {
+		switch o.Code {
+		case layers.DHCPv6OptClientID:
+			info.ClientID = hex.EncodeToString(o.Data)
+			// DUID-LLT and DUID-LL over Ethernet end with the link-layer address.
+			duidType := uint16(0)
+			if len(o.Data) >= 2 {
+				duidType = binary.BigEndian.Uint16(o.Data[0:2])
+			}
+			if (duidType == 1 || duidType == 3) && len(o.Data) >= 10 &&
+				binary.BigEndian.Uint16(o.Data[2:4]) == uint16(layers.LinkTypeEthernet) {
+				copy(info.ClientMAC[:], o.Data[len(o.Data)-6:])
+			}
+		case layers.DHCPv6OptServerID:
+			info.ServerID = hex.EncodeToString(o.Data)
+		case layers.DHCPv6OptIANA:
+			if len(o.Data) >= 12 {
+				dhcpv6IAAddr(info, o.Data[12:])
+			}
+		case layers.DHCPv6OptClientFQDN:
+			if len(o.Data) > 1 {
+				info.Hostname = dnsWireName(o.Data[1:])
+			}
+		}
+	}
+	return info
+}
+
+func dhcpv6IAAddr(info *types.DHCPInfo, opts []byte) {
+	for len(opts) >= 4 {
+		code := binary.BigEndian.Uint16(opts[0:2])
+		length := int(binary.BigEndian.Uint16(opts[2:4]))
+		if len(opts) < 4+length {
+			return
+		}
+		data := opts[4 : 4+length]
+		if layers.DHCPv6Opt(code) == layers.DHCPv6OptIAAddr && length >= 24 {
+			info.Addr, _ = netip.AddrFromSlice(data[0:16])
+			info.LeaseTime = time.Duration(binary.BigEndian.Uint32(data[20:24])) * time.Second
+			return
+		}
+		opts = opts[4+length:]
+	}
+}
+
+// dnsWireName converts a DNS name in wire format (length-prefixed labels).
+func dnsWireName(b []byte) string {
+	var labels []string
+	for len(b) > 0 {
+		n := int(b[0])
+		if n == 0 || n > len(b)-1 {
+			break
+		}
+		labels = append(labels, printable(b[1:1+n]))
+		b = b[1+n:]
+	}
+	return strings.Join(labels, ".")
+}
+
+func describeDHCP(info *types.DHCPInfo) string {
+	parts := []string{info.MsgType}
+	if info.Addr.IsValid() {
+		parts = append(parts, info.Addr.String())
+	}
+	if !info.ClientMAC.IsZero() {
+		parts = append(parts, "mac="+info.ClientMAC.String())
+	} else if info.ClientID != "" {
+		parts = append(parts, "duid="+info.ClientID)
+	}
+	if info.Hostname != "" {
+		parts = append(parts, "host="+info.Hostname)
+	}
+	if info.LeaseTime > 0 {
+		parts = append(parts, "lease="+info.LeaseTime.String())
+	}
+	return strings.Join(parts, " ")
+}
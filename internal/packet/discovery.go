@@ -0,0 +1,456 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fe-dudu/netmon/internal/types"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	maxDiscoveryItems = 8
+	nbnsTypeNB        = 0x20
+	nbnsTypeNBSTAT    = 0x21
+)
+
+var nbnsOpNames = map[uint8]string{
+	0: "query", 5: "register", 6: "release", 7: "wait", 8: "refresh",
+}
+
+var nbnsSuffixNames = map[byte]string{
+	0x00: "workstation", 0x03: "messenger", 0x1b: "domain master", 0x1c: "domain controller",
+	0x1d: "master browser", 0x1e: "browser election", 0x20: "file server",
+}
+
+var modelKeys = []string{"md=", "model=", "am=", "ty=", "usb_MDL="}
+
+func init() {
+	Register(Dissector{
+		Name:      "mDNS",
+		Priority:  210,
+		Transport: types.IPProtoUDP,
+		Ports:     []uint16{5353},
+		Detail:    func(c *Context) string { return c.mdnsDetail() },
+		Color:     "mediumseagreen",
+	})
+	Register(Dissector{
+		Name:      "LLMNR",
+		Priority:  220,
+		Transport: types.IPProtoUDP,
+		Ports:     []uint16{5355},
+		Detail:    func(c *Context) string { return c.llmnrDetail() },
+		Color:     "mediumseagreen",
+	})
+	Register(Dissector{
+		Name:      "NBNS",
+		Priority:  230,
+		Transport: types.IPProtoUDP,
+		Ports:     []uint16{137},
+		Detail:    func(c *Context) string { return c.nbnsDetail() },
+		Color:     "mediumseagreen",
+	})
+	Register(Dissector{
+		Name:      "SSDP",
+		Priority:  30,
+		Transport: types.IPProtoUDP,
+		Ports:     []uint16{1900},
+		Match: func(c *Context) bool {
+			return bytes.HasPrefix(c.Payload, []byte("NOTIFY * HTTP/1.")) ||
+				bytes.HasPrefix(c.Payload, []byte("M-SEARCH * HTTP/1."))
+		},
+		Detail: func(c *Context) string { return c.ssdpDetail() },
+		Color:  "mediumseagreen",
+	})
+	types.ProtocolFilters = append(types.ProtocolFilters, types.FilterChoice{
+		Label:  "Discovery",
+		Desc:   "mDNS, SSDP, LLMNR and NBNS service and name discovery on the LAN (L7)",
+		Protos: []string{"mDNS", "SSDP", "LLMNR", "NBNS"},
+	})
+}
+
+// decodeDNSPayload decodes mDNS and LLMNR messages, which share the DNS
+// header and record layout. The port 53 DNS layer is not in use here.
+func (c *Context) decodeDNSPayload() *layers.DNS {
+	dns := &c.p.dns
+	if err := dns.DecodeFromBytes(c.Payload, gopacket.NilDecodeFeedback); err != nil {
+		return nil
+	}
+	return dns
+}
+
+func (c *Context) mdnsDetail() string {
+	dns := c.decodeDNSPayload()
+	if dns == nil {
+		return c.p.lenDetail(len(c.Payload))
+	}
+	if !dns.QR {
+		if len(dns.Questions) == 0 {
+			return "query"
+		}
+		q := dns.Questions[0]
+		detail := c.p.dnsDetail("Q ", q.Type, q.Name)
+		if len(dns.Questions) > 1 {
+			detail += fmt.Sprintf(" +%d", len(dns.Questions)-1)
+		}
+		return detail
+	}
+
+	info := &types.DiscoveryInfo{Announce: true, Bye: len(dns.Answers) > 0}
+	addrs := make(map[string][]string)
+	records := slices.Concat(dns.Answers, dns.Additionals)
+	for _, rr := range records {
+		if rr.TTL != 0 && rr.Type != layers.DNSTypeOPT {
+			info.Bye = false
+		}
+		name := string(rr.Name)
+		switch rr.Type {
+		case layers.DNSTypeA, layers.DNSTypeAAAA:
+			if ip, ok := netip.AddrFromSlice(rr.IP); ok {
+				info.Names = appendUnique(info.Names, name)
+				addrs[name] = append(addrs[name], ip.Unmap().String())
+			}
+		case layers.DNSTypePTR:
+			ptr := string(rr.PTR)
+			switch {
+			case strings.HasSuffix(name, ".arpa"):
+				info.Names = appendUnique(info.Names, ptr)
+			case !strings.HasPrefix(ptr, "_"):
+				info.Services = appendUnique(info.Services, serviceInstance(ptr))
+			}
+		case layers.DNSTypeSRV:
+			info.Services = appendUnique(info.Services, serviceInstance(name))
+			info.Names = appendUnique(info.Names, string(rr.SRV.Name))
+		case layers.DNSTypeTXT:
+			if info.Model == "" {
+				info.Model = txtModel(rr.TXTs)
+			}
+		}
+	}
+	c.Meta = info
+
+	var parts []string
+	if info.Bye {
+		parts = append(parts, "goodbye")
+	}
+	for _, name := range info.Names {
+		if ips := addrs[name]; len(ips) > 0 {
+			parts = append(parts, printable([]byte(name))+"="+strings.Join(ips, ","))
+		} else {
+			parts = append(parts, printable([]byte(name)))
+		}
+	}
+	for _, svc := range info.Services {
+		parts = append(parts, "svc="+printable([]byte(svc)))
+	}
+	if info.Model != "" {
+		parts = append(parts, "model="+info.Model)
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("response an=%d", len(dns.Answers))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (c *Context) llmnrDetail() string {
+	dns := c.decodeDNSPayload()
+	if dns == nil || len(dns.Questions) == 0 {
+		return c.p.lenDetail(len(c.Payload))
+	}
+	q := dns.Questions[0]
+	if !dns.QR {
+		return c.p.dnsDetail("Q ", q.Type, q.Name)
+	}
+	info := &types.DiscoveryInfo{Announce: true}
+	var ips []string
+	for _, rr := range dns.Answers {
+		if ip, ok := netip.AddrFromSlice(rr.IP); ok && (rr.Type == layers.DNSTypeA || rr.Type == layers.DNSTypeAAAA) {
+			info.Names = appendUnique(info.Names, string(rr.Name))
+			ips = append(ips, ip.Unmap().String())
+		}
+	}
+	if len(ips) == 0 {
+		return c.p.dnsDetail("A ", q.Type, q.Name) + " (no address)"
+	}
+	c.Meta = info
+	return c.p.dnsDetail("A ", q.Type, q.Name) + "=" + strings.Join(ips, ",")
+}
+
+func (c *Context) nbnsDetail() string {
+	msg, ok := parseNBNS(c.Payload)
+	if !ok {
+		return c.p.lenDetail(len(c.Payload))
+	}
+	op := nbnsOpNames[msg.opcode]
+	if op == "" {
+		op = "op=" + strconv.Itoa(int(msg.opcode))
+	}
+	if msg.qr && msg.opcode == 0 {
+		op = "response"
+	}
+
+	name, suffix := netbiosName(msg.question)
+	var ips []string
+	var names []string
+	for _, rr := range msg.records {
+		switch rr.typ {
+		case nbnsTypeNB:
+			if name == "" {
+				name, suffix = netbiosName(rr.name)
+			}
+			for b := rr.data; len(b) >= 6; b = b[6:] {
+				ips = append(ips, netip.AddrFrom4([4]byte(b[2:6])).String())
+			}
+		case nbnsTypeNBSTAT:
+			if name == "" {
+				name, suffix = netbiosName(rr.name)
+			}
+			names = nbstatNames(rr.data)
+		}
+	}
+	if name == "*" {
+		// Node status requests ask for the wildcard name.
+		name = ""
+	}
+
+	parts := []string{op}
+	if name != "" {
+		label := fmt.Sprintf("%s<%02x>", name, suffix)
+		if role := nbnsSuffixNames[suffix]; role != "" {
+			label += " " + role
+		}
+		parts = append(parts, label)
+	}
+	if len(ips) > 0 {
+		parts = append(parts, strings.Join(ips, ","))
+	}
+	if len(names) > 0 {
+		parts = append(parts, "names="+strings.Join(names, ","))
+	}
+
+	// Registrations, refreshes, releases and positive responses describe
+	// the sender, as do node status responses.
+	release := !msg.qr && msg.opcode == 6
+	announce := release || len(names) > 0 ||
+		(msg.qr && msg.opcode == 0 && len(ips) > 0) || (!msg.qr && (msg.opcode == 5 || msg.opcode == 8))
+	if announce && (name != "" || len(names) > 0) {
+		info := &types.DiscoveryInfo{Announce: !release, Bye: release, Names: []string{name}}
+		if len(names) > 0 {
+			info.Names = names
+		}
+		c.Meta = info
+	}
+	return strings.Join(parts, " ")
+}
+
+type nbnsMessage struct {
+	qr       bool
+	opcode   uint8
+	question []byte
+	records  []nbnsRecord
+}
+
+type nbnsRecord struct {
+	name []byte
+	typ  uint16
+	data []byte
+}
+
+// parseNBNS walks an NBNS message by hand. gopacket's DNS layer reads type
+// 0x21 as SRV and fails on the node status name table.
+func parseNBNS(b []byte) (*nbnsMessage, bool) {
+	if len(b) < 12 {
+		return nil, false
+	}
+	msg := &nbnsMessage{qr: b[2]&0x80 != 0, opcode: b[2] >> 3 & 0x0f}
+	questions := int(binary.BigEndian.Uint16(b[4:6]))
+	records := int(binary.BigEndian.Uint16(b[6:8])) + int(binary.BigEndian.Uint16(b[8:10])) + int(binary.BigEndian.Uint16(b[10:12]))
+
+	off := 12
+	for i := 0; i < questions; i++ {
+		name, next, ok := nbnsName(b, off)
+		if !ok || next+4 > len(b) {
+			return nil, false
+		}
+		if i == 0 {
+			msg.question = name
+		}
+		off = next + 4
+	}
+	for i := 0; i < records; i++ {
+		name, next, ok := nbnsName(b, off)
+		if !ok || next+10 > len(b) {
+			return nil, false
+		}
+		end := next + 10 + int(binary.BigEndian.Uint16(b[next+8:next+10]))
+		if end > len(b) {
+			return nil, false
+		}
+		msg.records = append(msg.records, nbnsRecord{
+			name: name,
+			typ:  binary.BigEndian.Uint16(b[next : next+2]),
+			data: b[next+10 : end],
+		})
+		off = end
+	}
+	return msg, true
+}
+
+// nbnsName reads the labels of the name at off, following compression
+// pointers, and returns it with the offset just past it.
+func nbnsName(b []byte, off int) ([]byte, int, bool) {
+	var name []byte
+	next := -1
+	for hops := 0; off < len(b); {
+		n := int(b[off])
+		switch {
+		case n == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return name, next, true
+		case n&0xc0 == 0xc0:
+			if off+1 >= len(b) || hops >= 8 {
+				return nil, 0, false
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = (n&0x3f)<<8 | int(b[off+1])
+			hops++
+		case n&0xc0 != 0 || off+1+n > len(b):
+			return nil, 0, false
+		default:
+			if len(name) > 0 {
+				name = append(name, '.')
+			}
+			name = append(name, b[off+1:off+1+n]...)
+			off += 1 + n
+		}
+	}
+	return nil, 0, false
+}
+
+// netbiosName undoes the first-level encoding of RFC 1001 section 14.1.
+func netbiosName(encoded []byte) (string, byte) {
+	label, _, _ := bytes.Cut(encoded, []byte("."))
+	if len(label) != 32 {
+		return printable(label), 0
+	}
+	var raw [16]byte
+	for i := range raw {
+		hi, lo := label[2*i]-'A', label[2*i+1]-'A'
+		if hi > 15 || lo > 15 {
+			return printable(label), 0
+		}
+		raw[i] = hi<<4 | lo
+	}
+	return printable(bytes.TrimRight(raw[:15], " \x00")), raw[15]
+}
+
+// nbstatNames lists the unique names of a node status response.
+func nbstatNames(b []byte) []string {
+	if len(b) < 1 {
+		return nil
+	}
+	n := int(b[0])
+	b = b[1:]
+	var names []string
+	for i := 0; i < n && len(b) >= 18; i++ {
+		flags := b[16]
+		if flags&0x80 == 0 {
+			names = appendUnique(names, printable(bytes.TrimRight(b[:15], " \x00")))
+		}
+		b = b[18:]
+	}
+	return names
+}
+
+func (c *Context) ssdpDetail() string {
+	line, rest, _ := bytes.Cut(c.Payload, []byte("\n"))
+	headers := make(map[string]string)
+	for len(rest) > 0 {
+		var h []byte
+		h, rest, _ = bytes.Cut(rest, []byte("\n"))
+		if name, value, ok := bytes.Cut(h, []byte(":")); ok {
+			headers[strings.ToLower(string(bytes.TrimSpace(name)))] = printable(bytes.TrimSpace(value))
+		}
+	}
+
+	info := &types.DiscoveryInfo{Server: headers["server"], Location: headers["location"]}
+	var parts []string
+	var target string
+	switch {
+	case bytes.HasPrefix(line, []byte("NOTIFY")):
+		target = headers["nt"]
+		nts := strings.TrimPrefix(headers["nts"], "ssdp:")
+		parts = append(parts, "NOTIFY "+nts, "nt="+target)
+		info.Announce = nts == "alive" || nts == "update"
+		info.Bye = nts == "byebye"
+	case bytes.HasPrefix(line, []byte("M-SEARCH")):
+		parts = append(parts, "M-SEARCH", "st="+headers["st"])
+		if mx := headers["mx"]; mx != "" {
+			parts = append(parts, "mx="+mx)
+		}
+		return strings.Join(parts, " ")
+	case bytes.HasPrefix(line, []byte("HTTP/1.")):
+		target = headers["st"]
+		parts = append(parts, "reply", "st="+target)
+		info.Announce = true
+	default:
+		return c.p.lenDetail(len(c.Payload))
+	}
+	if svc := ssdpType(target); svc != "" {
+		info.Services = []string{svc}
+	}
+	if info.Location != "" {
+		parts = append(parts, "location="+info.Location)
+	}
+	if info.Server != "" {
+		parts = append(parts, "server="+info.Server)
+	}
+	if info.Announce || info.Bye {
+		c.Meta = info
+	}
+	return strings.Join(parts, " ")
+}
+
+// ssdpType shortens a UPnP device or service URN, e.g.
+// "urn:schemas-upnp-org:device:MediaRenderer:1" to "MediaRenderer:1".
+func ssdpType(nt string) string {
+	fields := strings.Split(nt, ":")
+	if len(fields) == 5 && fields[0] == "urn" && (fields[2] == "device" || fields[2] == "service") {
+		return fields[3] + ":" + fields[4]
+	}
+	return ""
+}
+
+// serviceInstance trims the domain from a DNS-SD instance name, e.g.
+// "Living Room._googlecast._tcp.local" to "Living Room._googlecast._tcp".
+func serviceInstance(name string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(name, "."), ".local")
+}
+
+func txtModel(txts [][]byte) string {
+	for _, key := range modelKeys {
+		for _, txt := range txts {
+			if value, ok := bytes.CutPrefix(txt, []byte(key)); ok && len(value) > 0 {
+				return printable(value)
+			}
+		}
+	}
+	return ""
+}
+
+func appendUnique(list []string, s string) []string {
+	if s == "" || len(list) >= maxDiscoveryItems || slices.Contains(list, s) {
+		return list
+	}
+	return append(list, s)
+}
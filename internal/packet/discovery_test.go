@@ -0,0 +1,73 @@
+package packet_test
+
+import (
+	"encoding/hex"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/fe-dudu/netmon/internal/packet"
+	"github.com/fe-dudu/netmon/internal/types"
+)
+
+// nbstatReply is the NBNS payload of a Windows host answering a node status
+// request (nbtstat -A): FILESRV01 as workstation and file server, member of
+// the WORKGROUP group.
+var nbstatReply = "" +
+	"a1b2" + "8400" + "0000" + "0001" + "0000" + "0000" +
+	"20" + "434b" + strings.Repeat("41", 30) + "00" +
+	"0021" + "0001" + "00000000" + "0065" +
+	"03" +
+	"46494c45535256303120202020202000" + "0400" +
+	"574f524b47524f555020202020202000" + "8400" +
+	"46494c45535256303120202020202020" + "0400" +
+	"00155d0a0b0c" + strings.Repeat("00", 40)
+
+func udpFrame(t *testing.T, src, dst net.IP, sport, dport layers.UDPPort, payload []byte) []byte {
+	t.Helper()
+	ip := &layers.IPv4{Version: 4, TTL: 128, Protocol: layers.IPProtocolUDP, SrcIP: src, DstIP: dst}
+	udp := &layers.UDP{SrcPort: sport, DstPort: dport}
+	if err := udp.SetNetworkLayerForChecksum(ip); err != nil {
+		t.Fatal(err)
+	}
+	buf := gopacket.NewSerializeBuffer()
+	err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true},
+		&layers.Ethernet{SrcMAC: net.HardwareAddr{0, 0x15, 0x5d, 0x0a, 0x0b, 0x0c}, DstMAC: net.HardwareAddr{2, 0, 0, 0, 0, 0x10}, EthernetType: layers.EthernetTypeIPv4},
+		ip, udp, gopacket.Payload(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func parseFrame(frame []byte) types.PacketInfo {
+	ci := gopacket.CaptureInfo{Timestamp: time.Unix(1700000000, 0), CaptureLength: len(frame), Length: len(frame)}
+	return packet.NewParser().Parse(frame, ci, layers.LinkTypeEthernet)
+}
+
+func TestNBSTATResponse(t *testing.T) {
+	payload, err := hex.DecodeString(nbstatReply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkt := parseFrame(udpFrame(t, net.IPv4(192, 168, 1, 40), net.IPv4(192, 168, 1, 10), 137, 137, payload))
+
+	if pkt.Proto != "NBNS" {
+		t.Fatalf("Proto = %q, want NBNS", pkt.Proto)
+	}
+	if want := "response names=FILESRV01"; pkt.Detail != want {
+		t.Errorf("Detail = %q, want %q", pkt.Detail, want)
+	}
+	info, ok := pkt.Meta.(*types.DiscoveryInfo)
+	if !ok {
+		t.Fatalf("Meta = %T, want *types.DiscoveryInfo", pkt.Meta)
+	}
+	if !info.Announce || !slices.Equal(info.Names, []string{"FILESRV01"}) {
+		t.Errorf("DiscoveryInfo = %+v, want an announcement of FILESRV01", info)
+	}
+}
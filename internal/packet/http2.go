@@ -0,0 +1,279 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fe-dudu/netmon/internal/types"
+)
+
+const (
+	http2Preface   = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+	http2HeaderLen = 9
+	maxHTTP2Frames = 64
+)
+
+const (
+	HTTP2Data uint8 = iota
+	HTTP2Headers
+	HTTP2Priority
+	HTTP2RSTStream
+	HTTP2Settings
+	HTTP2PushPromise
+	HTTP2Ping
+	HTTP2GoAway
+	HTTP2WindowUpdate
+	HTTP2Continuation
+)
+
+const (
+	HTTP2FlagEndStream  = 0x1
+	HTTP2FlagEndHeaders = 0x4
+	http2FlagAck        = 0x1
+	http2FlagPadded     = 0x8
+	http2FlagPriority   = 0x20
+)
+
+var http2FrameNames = []string{
+	"DATA", "HEADERS", "PRIORITY", "RST_STREAM", "SETTINGS",
+	"PUSH_PROMISE", "PING", "GOAWAY", "WINDOW_UPDATE", "CONTINUATION",
+}
+
+var http2ErrorNames = []string{
+	"NO_ERROR", "PROTOCOL_ERROR", "INTERNAL_ERROR", "FLOW_CONTROL_ERROR",
+	"SETTINGS_TIMEOUT", "STREAM_CLOSED", "FRAME_SIZE_ERROR", "REFUSED_STREAM",
+	"CANCEL", "COMPRESSION_ERROR", "CONNECT_ERROR", "ENHANCE_YOUR_CALM",
+	"INADEQUATE_SECURITY", "HTTP_1_1_REQUIRED",
+}
+
+func init() {
+	Register(Dissector{
+		Name:      "HTTP2",
+		Priority:  100,
+		Transport: types.IPProtoTCP,
+		Match: func(c *Context) bool {
+			_, ok := parseHTTP2(c.Payload, false)
+			return ok
+		},
+		Detail: func(c *Context) string {
+			info, ok := parseHTTP2(c.Payload, true)
+			if !ok {
+				return c.p.lenDetail(len(c.Payload))
+			}
+			c.Meta = info
+			return DescribeHTTP2(info, nil)
+		},
+		Color: "blue",
+	})
+}
+
+// parseHTTP2 recognises the client preface or a run of well-formed frame
+// headers. Only the last frame may continue into the next segment.
+func parseHTTP2(b []byte, keep bool) (*types.HTTP2Info, bool) {
+	var info *types.HTTP2Info
+	if keep {
+		info = &types.HTTP2Info{}
+	}
+	preface := bytes.HasPrefix(b, []byte(http2Preface))
+	if preface {
+		b = b[len(http2Preface):]
+		if keep {
+			info.Preface = true
+		}
+	}
+
+	complete := 0
+	for n := 0; len(b) >= http2HeaderLen && n < maxHTTP2Frames; n++ {
+		length := int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+		typ, flags := b[3], b[4]
+		stream := binary.BigEndian.Uint32(b[5:9])
+		if typ > HTTP2Continuation || stream&(1<<31) != 0 || !validHTTP2Frame(typ, flags, stream, length) {
+			return nil, false
+		}
+		body := b[http2HeaderLen:]
+		missing := 0
+		if length <= len(body) {
+			body = body[:length]
+			complete++
+		} else if complete == 0 && !preface {
+			return nil, false
+		} else {
+			missing = length - len(body)
+		}
+		if keep {
+			info.Frames = append(info.Frames, http2Frame(typ, flags, stream, length, body, missing))
+		}
+		if length >= len(b)-http2HeaderLen {
+			b = nil
+			break
+		}
+		b = b[http2HeaderLen+length:]
+	}
+	if len(b) != 0 && len(b) < http2HeaderLen && !preface {
+		return nil, false
+	}
+	return info, preface || complete > 0
+}
+
+// validHTTP2Frame applies the per-type stream and length rules of RFC 9113
+// section 6, which keeps random payloads from matching.
+func validHTTP2Frame(typ, flags uint8, stream uint32, length int) bool {
+	switch typ {
+	case HTTP2Data, HTTP2Headers, HTTP2Continuation, HTTP2PushPromise:
+		return stream != 0 && length <= 1<<14
+	case HTTP2Priority:
+		return stream != 0 && length == 5
+	case HTTP2RSTStream:
+		return stream != 0 && length == 4
+	case HTTP2Settings:
+		return stream == 0 && length%6 == 0 && (flags&http2FlagAck == 0 || length == 0)
+	case HTTP2Ping:
+		return stream == 0 && length == 8
+	case HTTP2GoAway:
+		return stream == 0 && length >= 8 && length <= 1<<14
+	case HTTP2WindowUpdate:
+		return length == 4
+	}
+	return false
+}
+
+func http2Frame(typ, flags uint8, stream uint32, length int, body []byte, missing int) types.HTTP2Frame {
+	f := types.HTTP2Frame{Type: typ, Flags: flags, StreamID: stream, Length: length, Missing: missing}
+	switch typ {
+	case HTTP2Headers, HTTP2PushPromise, HTTP2Continuation:
+		if missing > 0 {
+			// Padding and the fields before the block may be cut too; the
+			// tracker extracts the block once it has the whole body.
+			f.Block = append([]byte(nil), body...)
+			break
+		}
+		block := body
+		if typ != HTTP2Continuation && flags&http2FlagPadded != 0 && len(block) > 0 {
+			pad := int(block[0])
+			block = block[1:]
+			if pad > len(block) {
+				pad = len(block)
+			}
+			block = block[:len(block)-pad]
+		}
+		switch {
+		case typ == HTTP2Headers && flags&http2FlagPriority != 0:
+			block = skip(block, 5)
+		case typ == HTTP2PushPromise:
+			if len(block) >= 4 {
+				f.Promised = binary.BigEndian.Uint32(block[0:4]) &^ (1 << 31)
+			}
+			block = skip(block, 4)
+		}
+		f.Block = append([]byte(nil), block...)
+	case HTTP2RSTStream:
+		if len(body) >= 4 {
+			f.ErrorCode = binary.BigEndian.Uint32(body[0:4])
+		}
+	case HTTP2GoAway:
+		if len(body) >= 8 {
+			f.ErrorCode = binary.BigEndian.Uint32(body[4:8])
+		}
+	}
+	return f
+}
+
+// ParseHTTP2 frames a segment that starts on a frame boundary.
+func ParseHTTP2(b []byte) (*types.HTTP2Info, bool) {
+	return parseHTTP2(b, true)
+}
+
+// CompleteHTTP2Frame extracts the header block of a cut frame once the
+// tracker has collected the rest of its body.
+func CompleteHTTP2Frame(f types.HTTP2Frame) types.HTTP2Frame {
+	return http2Frame(f.Type, f.Flags, f.StreamID, f.Length, f.Block, 0)
+}
+
+// DescribeHTTP2 summarises the frames of a segment. headers holds decoded
+// descriptions of HEADERS frames by frame index.
+func DescribeHTTP2(info *types.HTTP2Info, headers map[int]string) string {
+	var parts []string
+	if info.Preface {
+		parts = append(parts, "preface")
+	}
+	for i, f := range info.Frames {
+		if desc, ok := headers[i]; ok {
+			parts = append(parts, desc)
+			continue
+		}
+		name := http2FrameNames[f.Type]
+		switch f.Type {
+		case HTTP2Data:
+			desc := fmt.Sprintf("DATA s=%d len=%d", f.StreamID, f.Length)
+			if f.Flags&HTTP2FlagEndStream != 0 {
+				desc += " end"
+			}
+			parts = append(parts, desc)
+		case HTTP2Headers:
+			parts = append(parts, fmt.Sprintf("HEADERS s=%d", f.StreamID))
+		case HTTP2PushPromise:
+			parts = append(parts, fmt.Sprintf("PUSH_PROMISE s=%d", f.StreamID))
+		case HTTP2RSTStream:
+			parts = append(parts, fmt.Sprintf("RST_STREAM s=%d %s", f.StreamID, http2ErrorName(f.ErrorCode)))
+		case HTTP2GoAway:
+			parts = append(parts, "GOAWAY "+http2ErrorName(f.ErrorCode))
+		case HTTP2Settings:
+			if f.Flags&http2FlagAck != 0 {
+				name += " ack"
+			}
+			parts = append(parts, name)
+		case HTTP2Ping:
+			if f.Flags&http2FlagAck != 0 {
+				name += " ack"
+			}
+			parts = append(parts, name)
+		case HTTP2Continuation:
+		default:
+			parts = append(parts, name)
+		}
+	}
+	return joinHTTP2(parts)
+}
+
+// joinHTTP2 collapses repeated frame names, e.g. "WINDOW_UPDATE x3".
+func joinHTTP2(parts []string) string {
+	var out []string
+	for i := 0; i < len(parts); {
+		j := i + 1
+		for j < len(parts) && parts[j] == parts[i] {
+			j++
+		}
+		if j-i > 1 {
+			out = append(out, fmt.Sprintf("%s x%d", parts[i], j-i))
+		} else {
+			out = append(out, parts[i])
+		}
+		i = j
+	}
+	return strings.Join(out, "; ")
+}
+
+func http2ErrorName(code uint32) string {
+	if int(code) < len(http2ErrorNames) {
+		return http2ErrorNames[code]
+	}
+	return fmt.Sprintf("error=0x%x", code)
+}
+
+var grpcStatusNames = []string{
+	"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED",
+	"NOT_FOUND", "ALREADY_EXISTS", "PERMISSION_DENIED", "RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION", "ABORTED", "OUT_OF_RANGE", "UNIMPLEMENTED",
+	"INTERNAL", "UNAVAILABLE", "DATA_LOSS", "UNAUTHENTICATED",
+}
+
+// GRPCStatusName returns the canonical name of a grpc-status value.
+func GRPCStatusName(status string) string {
+	if n, err := strconv.Atoi(status); err == nil && n >= 0 && n < len(grpcStatusNames) {
+		return grpcStatusNames[n]
+	}
+	return ""
+}
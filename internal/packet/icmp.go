@@ -0,0 +1,251 @@
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/fe-dudu/netmon/internal/types"
+	"github.com/google/gopacket/layers"
+)
+
+var icmpv4Unreachable = []string{
+	"net", "host", "protocol", "port", "needs-frag", "source-route-failed",
+	"net-unknown", "host-unknown", "source-isolated", "net-prohibited",
+	"host-prohibited", "net-tos", "host-tos", "admin-prohibited",
+	"host-precedence", "precedence-cutoff",
+}
+
+var icmpv6Unreachable = []string{
+	"no-route", "admin-prohibited", "beyond-scope", "address",
+	"port", "source-policy", "reject-route",
+}
+
+func codeName(names []string, code uint8) string {
+	if int(code) < len(names) {
+		return names[code]
+	}
+	return fmt.Sprintf("code=%d", code)
+}
+
+func (p *Parser) icmpv4Detail() string {
+	icmp := &p.icmp4
+	typ, code := icmp.TypeCode.Type(), icmp.TypeCode.Code()
+
+	switch typ {
+	case layers.ICMPv4TypeEchoRequest, layers.ICMPv4TypeEchoReply:
+		info := &types.ICMPInfo{ID: icmp.Id, Seq: icmp.Seq, Reply: typ == layers.ICMPv4TypeEchoReply}
+		p.ctx.Meta = info
+		return echoDetail(info)
+	case layers.ICMPv4TypeDestinationUnreachable:
+		detail := "unreachable " + codeName(icmpv4Unreachable, code)
+		if code == layers.ICMPv4CodeFragmentationNeeded {
+			detail += fmt.Sprintf(" mtu=%d", icmp.Seq)
+		}
+		return detail + originalHeader(icmp.Payload)
+	case layers.ICMPv4TypeTimeExceeded:
+		reason := "ttl"
+		if code == layers.ICMPv4CodeFragmentReassemblyTimeExceeded {
+			reason = "reassembly"
+		}
+		return "time-exceeded " + reason + originalHeader(icmp.Payload)
+	case layers.ICMPv4TypeRedirect:
+		var gw [4]byte
+		binary.BigEndian.PutUint16(gw[0:2], icmp.Id)
+		binary.BigEndian.PutUint16(gw[2:4], icmp.Seq)
+		return "redirect to " + netip.AddrFrom4(gw).String() + originalHeader(icmp.Payload)
+	case layers.ICMPv4TypeParameterProblem:
+		return fmt.Sprintf("parameter-problem ptr=%d", icmp.Id>>8) + originalHeader(icmp.Payload)
+	case layers.ICMPv4TypeSourceQuench:
+		return "source-quench" + originalHeader(icmp.Payload)
+	case layers.ICMPv4TypeRouterAdvertisement:
+		return "router-advertisement"
+	case layers.ICMPv4TypeRouterSolicitation:
+		return "router-solicitation"
+	case layers.ICMPv4TypeTimestampRequest:
+		return "timestamp-request"
+	case layers.ICMPv4TypeTimestampReply:
+		return "timestamp-reply"
+	}
+	return fmt.Sprintf("type=%d code=%d", typ, code)
+}
+
+func (p *Parser) icmpv6Detail() string {
+	icmp := &p.icmp6
+	typ, code := icmp.TypeCode.Type(), icmp.TypeCode.Code()
+	body := icmp.Payload
+
+	switch typ {
+	case layers.ICMPv6TypeEchoRequest, layers.ICMPv6TypeEchoReply:
+		if len(body) < 4 {
+			break
+		}
+		info := &types.ICMPInfo{
+			ID:    binary.BigEndian.Uint16(body[0:2]),
+			Seq:   binary.BigEndian.Uint16(body[2:4]),
+			Reply: typ == layers.ICMPv6TypeEchoReply,
+		}
+		p.ctx.Meta = info
+		return echoDetail(info)
+	case layers.ICMPv6TypeDestinationUnreachable:
+		return "unreachable " + codeName(icmpv6Unreachable, code) + originalHeader(skip(body, 4))
+	case layers.ICMPv6TypePacketTooBig:
+		if len(body) < 4 {
+			break
+		}
+		return fmt.Sprintf("packet-too-big mtu=%d", binary.BigEndian.Uint32(body[0:4])) + originalHeader(body[4:])
+	case layers.ICMPv6TypeTimeExceeded:
+		reason := "hop-limit"
+		if code == 1 {
+			reason = "reassembly"
+		}
+		return "time-exceeded " + reason + originalHeader(skip(body, 4))
+	case layers.ICMPv6TypeParameterProblem:
+		ptr := uint32(0)
+		if len(body) >= 4 {
+			ptr = binary.BigEndian.Uint32(body[0:4])
+		}
+		return fmt.Sprintf("parameter-problem code=%d ptr=%d", code, ptr) + originalHeader(skip(body, 4))
+	case layers.ICMPv6TypeRouterSolicitation:
+		return "router-solicitation" + ndpOptions(skip(body, 4))
+	case layers.ICMPv6TypeRouterAdvertisement:
+		return routerAdvertisement(body)
+	case layers.ICMPv6TypeNeighborSolicitation:
+		if len(body) < 20 {
+			break
+		}
+		return "neighbor-solicitation who-has " + net.IP(body[4:20]).String() + ndpOptions(body[20:])
+	case layers.ICMPv6TypeNeighborAdvertisement:
+		if len(body) < 20 {
+			break
+		}
+		var flags []string
+		if body[0]&0x80 != 0 {
+			flags = append(flags, "R")
+		}
+		if body[0]&0x40 != 0 {
+			flags = append(flags, "S")
+		}
+		if body[0]&0x20 != 0 {
+			flags = append(flags, "O")
+		}
+		detail := "neighbor-advertisement " + net.IP(body[4:20]).String()
+		if len(flags) > 0 {
+			detail += " [" + strings.Join(flags, ",") + "]"
+		}
+		return detail + ndpOptions(body[20:])
+	case layers.ICMPv6TypeRedirect:
+		if len(body) < 36 {
+			break
+		}
+		return "redirect " + net.IP(body[20:36]).String() + " via " + net.IP(body[4:20]).String()
+	case 130, 131, 132, 143:
+		return "multicast-listener"
+	}
+	return fmt.Sprintf("type=%d code=%d", typ, code)
+}
+
+func echoDetail(info *types.ICMPInfo) string {
+	kind := "echo request"
+	if info.Reply {
+		kind = "echo reply"
+	}
+	return fmt.Sprintf("%s id=%d seq=%d", kind, info.ID, info.Seq)
+}
+
+func routerAdvertisement(body []byte) string {
+	if len(body) < 12 {
+		return "router-advertisement"
+	}
+	detail := fmt.Sprintf("router-advertisement lifetime=%ds", binary.BigEndian.Uint16(body[2:4]))
+	var flags []string
+	if body[1]&0x80 != 0 {
+		flags = append(flags, "M")
+	}
+	if body[1]&0x40 != 0 {
+		flags = append(flags, "O")
+	}
+	if len(flags) > 0 {
+		detail += " [" + strings.Join(flags, ",") + "]"
+	}
+	return detail + ndpOptions(body[12:])
+}
+
+// ndpOptions summarises link-layer address, prefix and MTU options.
+func ndpOptions(opts []byte) string {
+	var parts []string
+	for len(opts) >= 8 {
+		length := int(opts[1]) * 8
+		if length == 0 || length > len(opts) {
+			break
+		}
+		data := opts[2:length]
+		switch opts[0] {
+		case 1, 2:
+			if len(data) >= 6 {
+				parts = append(parts, "lladdr="+net.HardwareAddr(data[:6]).String())
+			}
+		case 3:
+			if len(data) >= 30 {
+				parts = append(parts, fmt.Sprintf("prefix=%s/%d", net.IP(data[14:30]), data[0]))
+			}
+		case 5:
+			if len(data) >= 6 {
+				parts = append(parts, fmt.Sprintf("mtu=%d", binary.BigEndian.Uint32(data[2:6])))
+			}
+		}
+		opts = opts[length:]
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " " + strings.Join(parts, " ")
+}
+
+// originalHeader describes the IP header (and ports) quoted in an ICMP error.
+func originalHeader(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	var src, dst net.IP
+	var proto layers.IPProtocol
+	var l4 []byte
+
+	switch b[0] >> 4 {
+	case 4:
+		ihl := int(b[0]&0x0f) * 4
+		if len(b) < 20 || ihl < 20 {
+			return ""
+		}
+		src, dst = net.IP(b[12:16]), net.IP(b[16:20])
+		proto = layers.IPProtocol(b[9])
+		l4 = skip(b, ihl)
+	case 6:
+		if len(b) < 40 {
+			return ""
+		}
+		src, dst = net.IP(b[8:24]), net.IP(b[24:40])
+		proto = layers.IPProtocol(b[6])
+		l4 = b[40:]
+	default:
+		return ""
+	}
+
+	switch proto {
+	case layers.IPProtocolTCP, layers.IPProtocolUDP:
+		if len(l4) >= 4 {
+			return fmt.Sprintf(" (orig %s %s:%d > %s:%d)", proto, src, binary.BigEndian.Uint16(l4[0:2]),
+				dst, binary.BigEndian.Uint16(l4[2:4]))
+		}
+	}
+	return fmt.Sprintf(" (orig %s %s > %s)", proto, src, dst)
+}
+
+func skip(b []byte, n int) []byte {
+	if len(b) < n {
+		return nil
+	}
+	return b[n:]
+}
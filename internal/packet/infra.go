@@ -0,0 +1,524 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fe-dudu/netmon/internal/types"
+)
+
+func init() {
+	Register(Dissector{
+		Name:      "NTP",
+		Priority:  240,
+		Transport: types.IPProtoUDP,
+		Ports:     []uint16{123},
+		Detail:    func(c *Context) string { return c.ntpDetail() },
+		Color:     "khaki",
+	})
+	Register(Dissector{
+		Name:      "SNMP",
+		Priority:  250,
+		Transport: types.IPProtoUDP,
+		Ports:     []uint16{161, 162},
+		Detail:    func(c *Context) string { return snmpDetail(c.Payload) },
+		Color:     "khaki",
+	})
+	Register(Dissector{
+		Name:      "Syslog",
+		Priority:  130,
+		Transport: types.IPProtoUDP,
+		Ports:     []uint16{514},
+		Match: func(c *Context) bool {
+			_, _, ok := syslogPriority(c.Payload)
+			return ok
+		},
+		Detail: func(c *Context) string { return syslogDetail(c.Payload) },
+		Color:  "khaki",
+	})
+	types.ProtocolFilters = append(types.ProtocolFilters, types.FilterChoice{
+		Label:  "Infra",
+		Desc:   "NTP, SNMP and syslog from network infrastructure (L7)",
+		Protos: []string{"NTP", "SNMP", "Syslog"},
+	})
+}
+
+// NTP (RFC 5905).
+
+const (
+	ntpHeaderLen  = 48
+	ntpModeServer = 4
+	// ntpEpochOffset is the number of seconds from 1900 to 1970.
+	ntpEpochOffset = 2208988800
+)
+
+var ntpModeNames = []string{
+	"reserved", "symmetric-active", "symmetric-passive", "client",
+	"server", "broadcast", "control", "private",
+}
+
+func (c *Context) ntpDetail() string {
+	b := c.Payload
+	if len(b) < 1 {
+		return c.p.lenDetail(len(b))
+	}
+	leap, version, mode := b[0]>>6, (b[0]>>3)&0x7, b[0]&0x7
+	parts := []string{"v" + strconv.Itoa(int(version)), ntpModeNames[mode]}
+	if mode >= 6 || len(b) < ntpHeaderLen {
+		// Control and private (monlist) messages use a different layout.
+		return strings.Join(append(parts, "len="+strconv.Itoa(len(b))), " ")
+	}
+
+	stratum := b[1]
+	refID := b[12:16]
+	switch {
+	case mode == 3:
+	case stratum == 0:
+		parts = append(parts, "kiss="+printable(bytes.TrimRight(refID, "\x00")))
+	case stratum == 1:
+		parts = append(parts, "stratum=1", "ref="+printable(bytes.TrimRight(refID, "\x00")))
+	case stratum < 16:
+		parts = append(parts, "stratum="+strconv.Itoa(int(stratum)), "ref="+netip.AddrFrom4([4]byte(refID)).String())
+	default:
+		parts = append(parts, "unsynchronized")
+	}
+	if leap == 3 && mode != 3 && stratum > 0 && stratum < 16 {
+		parts = append(parts, "alarm")
+	}
+
+	if mode == ntpModeServer || mode == 2 {
+		c.Meta = &types.NTPInfo{
+			Mode:     mode,
+			Origin:   ntpTime(b[24:32]),
+			Receive:  ntpTime(b[32:40]),
+			Transmit: ntpTime(b[40:48]),
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// ntpTime converts a 64-bit NTP timestamp. Values below 2^31 seconds are
+// taken to be in era 1, which starts in 2036.
+func ntpTime(b []byte) time.Time {
+	secs := int64(binary.BigEndian.Uint32(b[0:4]))
+	frac := int64(binary.BigEndian.Uint32(b[4:8]))
+	if secs == 0 && frac == 0 {
+		return time.Time{}
+	}
+	if secs < 1<<31 {
+		secs += 1 << 32
+	}
+	return time.Unix(secs-ntpEpochOffset, frac*1e9>>32)
+}
+
+// SNMP v1, v2c and v3 (RFC 1157, 3416, 3412).
+
+const maxSNMPOIDs = 3
+
+var snmpPDUNames = map[byte]string{
+	0xa0: "get", 0xa1: "getnext", 0xa2: "response", 0xa3: "set", 0xa4: "trap",
+	0xa5: "getbulk", 0xa6: "inform", 0xa7: "trap", 0xa8: "report",
+}
+
+var snmpErrorNames = []string{
+	"noError", "tooBig", "noSuchName", "badValue", "readOnly", "genErr",
+	"noAccess", "wrongType", "wrongLength", "wrongEncoding", "wrongValue",
+	"noCreation", "inconsistentValue", "resourceUnavailable", "commitFailed",
+	"undoFailed", "authorizationError", "notWritable", "inconsistentName",
+}
+
+// snmpOIDNames names a few common MIB-2 objects so OIDs read as
+// "sysName.0" or "ifInOctets.3".
+var snmpOIDNames = map[string]string{
+	"1.3.6.1.2.1.1.1":          "sysDescr",
+	"1.3.6.1.2.1.1.2":          "sysObjectID",
+	"1.3.6.1.2.1.1.3":          "sysUpTime",
+	"1.3.6.1.2.1.1.4":          "sysContact",
+	"1.3.6.1.2.1.1.5":          "sysName",
+	"1.3.6.1.2.1.1.6":          "sysLocation",
+	"1.3.6.1.2.1.2.2.1.1":      "ifIndex",
+	"1.3.6.1.2.1.2.2.1.2":      "ifDescr",
+	"1.3.6.1.2.1.2.2.1.7":      "ifAdminStatus",
+	"1.3.6.1.2.1.2.2.1.8":      "ifOperStatus",
+	"1.3.6.1.2.1.2.2.1.10":     "ifInOctets",
+	"1.3.6.1.2.1.2.2.1.16":     "ifOutOctets",
+	"1.3.6.1.2.1.31.1.1.1.1":   "ifName",
+	"1.3.6.1.2.1.31.1.1.1.6":   "ifHCInOctets",
+	"1.3.6.1.2.1.31.1.1.1.10":  "ifHCOutOctets",
+	"1.3.6.1.6.3.1.1.4.1":      "snmpTrapOID",
+	"1.3.6.1.6.3.1.1.5.1":      "coldStart",
+	"1.3.6.1.6.3.1.1.5.2":      "warmStart",
+	"1.3.6.1.6.3.1.1.5.3":      "linkDown",
+	"1.3.6.1.6.3.1.1.5.4":      "linkUp",
+	"1.3.6.1.6.3.1.1.5.5":      "authenticationFailure",
+	"1.3.6.1.6.3.15.1.1.4.0":   "usmStatsUnknownEngineIDs",
+	"1.3.6.1.6.3.15.1.1.3.0":   "usmStatsUnknownUserNames",
+	"1.3.6.1.6.3.15.1.1.5.0":   "usmStatsWrongDigests",
+	"1.3.6.1.4.1.9.9.41.2.0.1": "clogMessageGenerated",
+}
+
+func snmpDetail(b []byte) string {
+	tag, msg, _, ok := berNext(b)
+	if !ok || tag != 0x30 {
+		return "len=" + strconv.Itoa(len(b))
+	}
+	tag, v, msg, ok := berNext(msg)
+	if !ok || tag != 0x02 {
+		return "len=" + strconv.Itoa(len(b))
+	}
+	switch berInt(v) {
+	case 0, 1:
+		version := "v1"
+		if berInt(v) == 1 {
+			version = "v2c"
+		}
+		// The community is a shared secret: never show it.
+		tag, _, msg, ok = berNext(msg)
+		if !ok || tag != 0x04 {
+			return version
+		}
+		return version + " community=*** " + snmpPDU(msg)
+	case 3:
+		return snmpV3(msg)
+	}
+	return "version=" + strconv.FormatInt(berInt(v), 10)
+}
+
+func snmpV3(msg []byte) string {
+	parts := []string{"v3"}
+	tag, global, msg, ok := berNext(msg)
+	if !ok || tag != 0x30 {
+		return "v3"
+	}
+	var flags byte
+	for i := 0; i < 3; i++ {
+		var v []byte
+		if tag, v, global, ok = berNext(global); !ok {
+			break
+		}
+		if i == 2 && tag == 0x04 && len(v) == 1 {
+			flags = v[0]
+		}
+	}
+	switch {
+	case flags&0x2 != 0:
+		parts = append(parts, "authPriv")
+	case flags&0x1 != 0:
+		parts = append(parts, "authNoPriv")
+	default:
+		parts = append(parts, "noAuthNoPriv")
+	}
+
+	tag, sec, msg, ok := berNext(msg)
+	if ok && tag == 0x04 {
+		if tag, usm, _, ok := berNext(sec); ok && tag == 0x30 {
+			for i := 0; i < 4; i++ {
+				var v []byte
+				if tag, v, usm, ok = berNext(usm); !ok {
+					break
+				}
+				if i == 3 && tag == 0x04 && len(v) > 0 {
+					parts = append(parts, "user="+printable(v))
+				}
+			}
+		}
+	}
+
+	tag, scoped, _, ok := berNext(msg)
+	switch {
+	case !ok:
+	case tag == 0x04:
+		parts = append(parts, "encrypted")
+	case tag == 0x30:
+		// contextEngineID and contextName precede the PDU.
+		for i := 0; i < 2 && ok; i++ {
+			_, _, scoped, ok = berNext(scoped)
+		}
+		if ok {
+			parts = append(parts, snmpPDU(scoped))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func snmpPDU(b []byte) string {
+	pduTag, pdu, _, ok := berNext(b)
+	name, known := snmpPDUNames[pduTag]
+	if !ok || !known {
+		return "pdu=?"
+	}
+	parts := []string{name}
+
+	var tag byte
+	var fields [3]int64
+	if pduTag == 0xa4 {
+		// SNMPv1 trap: enterprise, agent-addr, generic-trap, specific-trap,
+		// time-stamp.
+		var v []byte
+		tag, v, pdu, ok = berNext(pdu)
+		if ok && tag == 0x06 {
+			parts = append(parts, "enterprise="+oidName(berOID(v)))
+		}
+		for i := 0; i < 4 && ok; i++ {
+			tag, v, pdu, ok = berNext(pdu)
+			if i == 1 && ok {
+				parts = append(parts, "generic="+strconv.FormatInt(berInt(v), 10))
+			}
+		}
+	} else {
+		for i := range fields {
+			var v []byte
+			if tag, v, pdu, ok = berNext(pdu); !ok || tag != 0x02 {
+				return strings.Join(parts, " ")
+			}
+			fields[i] = berInt(v)
+		}
+		if pduTag != 0xa5 && fields[1] != 0 {
+			status := strconv.FormatInt(fields[1], 10)
+			if fields[1] > 0 && int(fields[1]) < len(snmpErrorNames) {
+				status = snmpErrorNames[fields[1]]
+			}
+			parts = append(parts, fmt.Sprintf("error=%s index=%d", status, fields[2]))
+		}
+	}
+
+	tag, list, _, ok := berNext(pdu)
+	if !ok || tag != 0x30 {
+		return strings.Join(parts, " ")
+	}
+	var oids []string
+	n := 0
+	for len(list) > 0 {
+		var bind, oid, value []byte
+		if tag, bind, list, ok = berNext(list); !ok || tag != 0x30 {
+			break
+		}
+		if tag, oid, bind, ok = berNext(bind); !ok || tag != 0x06 {
+			break
+		}
+		id := berOID(oid)
+		// For v2 traps and informs, the second binding names the trap.
+		if id == "1.3.6.1.6.3.1.1.4.1.0" {
+			if tag, value, _, ok = berNext(bind); ok && tag == 0x06 {
+				parts = append(parts, "trap="+oidName(berOID(value)))
+			}
+			continue
+		}
+		if id == "1.3.6.1.2.1.1.3.0" && (pduTag == 0xa7 || pduTag == 0xa6) {
+			continue
+		}
+		n++
+		if len(oids) < maxSNMPOIDs {
+			oids = append(oids, oidName(id))
+		}
+	}
+	if len(oids) > 0 {
+		text := strings.Join(oids, ",")
+		if n > len(oids) {
+			text += fmt.Sprintf(" +%d more", n-len(oids))
+		}
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, " ")
+}
+
+// oidName replaces a known prefix with its MIB name, keeping the index.
+func oidName(oid string) string {
+	for prefix := oid; prefix != ""; {
+		if name, ok := snmpOIDNames[prefix]; ok {
+			return name + strings.TrimPrefix(oid, prefix)
+		}
+		i := strings.LastIndexByte(prefix, '.')
+		if i < 0 {
+			break
+		}
+		prefix = prefix[:i]
+	}
+	return oid
+}
+
+// berNext splits the first BER TLV off b. Only single-byte tags and
+// definite lengths are supported, which is all SNMP uses.
+func berNext(b []byte) (tag byte, value, rest []byte, ok bool) {
+	if len(b) < 2 {
+		return 0, nil, nil, false
+	}
+	tag = b[0]
+	n := int(b[1])
+	hdr := 2
+	if n&0x80 != 0 {
+		size := n & 0x7f
+		if size == 0 || size > 3 || len(b) < 2+size {
+			return 0, nil, nil, false
+		}
+		n = 0
+		for _, c := range b[2 : 2+size] {
+			n = n<<8 | int(c)
+		}
+		hdr += size
+	}
+	if len(b)-hdr < n {
+		return 0, nil, nil, false
+	}
+	return tag, b[hdr : hdr+n], b[hdr+n:], true
+}
+
+func berInt(b []byte) int64 {
+	if len(b) == 0 || len(b) > 8 {
+		return 0
+	}
+	v := int64(int8(b[0]))
+	for _, c := range b[1:] {
+		v = v<<8 | int64(c)
+	}
+	return v
+}
+
+func berOID(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	first := int(b[0])
+	if first >= 80 {
+		fmt.Fprintf(&sb, "2.%d", first-80)
+	} else {
+		fmt.Fprintf(&sb, "%d.%d", first/40, first%40)
+	}
+	var v uint64
+	for _, c := range b[1:] {
+		v = v<<7 | uint64(c&0x7f)
+		if c&0x80 == 0 {
+			sb.WriteByte('.')
+			sb.WriteString(strconv.FormatUint(v, 10))
+			v = 0
+		}
+	}
+	return sb.String()
+}
+
+// Syslog over UDP (RFC 3164 and RFC 5424).
+
+const syslogPreviewLen = 80
+
+var syslogFacilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "audit", "alert", "clock",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var syslogSeverities = []string{
+	"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+}
+
+var syslogMonths = []string{"Jan ", "Feb ", "Mar ", "Apr ", "May ", "Jun ", "Jul ", "Aug ", "Sep ", "Oct ", "Nov ", "Dec "}
+
+// syslogPriority parses the "<PRI>" prefix and requires an RFC 5424 version
+// or an RFC 3164 timestamp after it, so other payloads starting with '<'
+// don't match.
+func syslogPriority(b []byte) (int, []byte, bool) {
+	if len(b) < 4 || b[0] != '<' {
+		return 0, nil, false
+	}
+	end := bytes.IndexByte(b[:min(len(b), 5)], '>')
+	if end < 2 {
+		return 0, nil, false
+	}
+	pri, err := strconv.Atoi(string(b[1:end]))
+	if err != nil || pri < 0 || pri > 191 || (b[1] == '0' && end > 2) {
+		return 0, nil, false
+	}
+	rest := b[end+1:]
+	if bytes.HasPrefix(rest, []byte("1 ")) {
+		return pri, rest, true
+	}
+	for _, m := range syslogMonths {
+		if bytes.HasPrefix(rest, []byte(m)) {
+			return pri, rest, true
+		}
+	}
+	return 0, nil, false
+}
+
+func syslogDetail(b []byte) string {
+	pri, rest, ok := syslogPriority(b)
+	if !ok {
+		return "len=" + strconv.Itoa(len(b))
+	}
+	parts := []string{syslogFacilities[pri/8] + "." + syslogSeverities[pri%8]}
+
+	var host, app string
+	msg := rest
+	if bytes.HasPrefix(rest, []byte("1 ")) {
+		// VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG
+		fields := bytes.SplitN(rest, []byte(" "), 7)
+		if len(fields) >= 6 {
+			host, app = string(fields[2]), string(fields[3])
+		}
+		msg = nil
+		if len(fields) == 7 {
+			msg = skipStructuredData(fields[6])
+		}
+	} else if len(rest) > 16 {
+		// "Mmm dd hh:mm:ss HOSTNAME TAG: MSG"
+		fields := bytes.SplitN(rest[16:], []byte(" "), 2)
+		host = string(fields[0])
+		msg = nil
+		if len(fields) == 2 {
+			msg = fields[1]
+			if tag, after, found := bytes.Cut(msg, []byte(": ")); found && len(tag) < 48 && bytes.IndexByte(tag, ' ') < 0 {
+				app, msg = string(tag), after
+			}
+		}
+	}
+	if host != "" && host != "-" {
+		parts = append(parts, "host="+printable([]byte(host)))
+	}
+	if app != "" && app != "-" {
+		parts = append(parts, "app="+printable([]byte(app)))
+	}
+	msg = bytes.TrimSpace(bytes.TrimPrefix(msg, []byte("\xef\xbb\xbf")))
+	if len(msg) > 0 {
+		preview := msg[:min(len(msg), syslogPreviewLen)]
+		text := printable(preview)
+		if len(msg) > len(preview) {
+			text += "..."
+		}
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, " ")
+}
+
+// skipStructuredData drops the RFC 5424 STRUCTURED-DATA element(s) before
+// the message.
+func skipStructuredData(b []byte) []byte {
+	if bytes.HasPrefix(b, []byte("- ")) || bytes.Equal(b, []byte("-")) {
+		return b[1:]
+	}
+	for len(b) > 0 && b[0] == '[' {
+		end := 0
+		escaped := false
+		for i, c := range b {
+			if escaped {
+				escaped = false
+				continue
+			}
+			if c == '\\' {
+				escaped = true
+			} else if c == ']' {
+				end = i + 1
+				break
+			}
+		}
+		if end == 0 {
+			return nil
+		}
+		b = b[end:]
+	}
+	return b
+}
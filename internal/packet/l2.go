@@ -0,0 +1,204 @@
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func (p *Parser) etherType() layers.EthernetType {
+	switch {
+	case p.hasDot1Q:
+		return p.dot1q.Type
+	case p.hasEth:
+		return p.eth.EthernetType
+	case p.hasSLL:
+		return p.sll.EthernetType
+	}
+	return 0
+}
+
+// l2Payload returns the bytes following the innermost decoded link layer.
+func (p *Parser) l2Payload() []byte {
+	switch {
+	case p.hasSNAP:
+		return p.snap.Payload
+	case p.hasLLC:
+		return p.llc.Payload
+	case p.hasDot1Q:
+		return p.dot1q.Payload
+	case p.hasEth:
+		return p.eth.Payload
+	case p.hasSLL:
+		return p.sll.Payload
+	}
+	return nil
+}
+
+func (p *Parser) classifyL2() (string, string) {
+	switch {
+	case p.hasARP:
+		return "ARP", arpDetail(&p.arp)
+	case p.hasSNAP && p.snap.Type == layers.EthernetTypeCiscoDiscovery:
+		return "CDP", cdpDetail(p.snap.Payload)
+	case p.hasLLC && p.llc.DSAP == 0x42 && p.llc.SSAP == 0x42:
+		return "STP", stpDetail(p.llc.Payload)
+	case p.etherType() == layers.EthernetTypeLinkLayerDiscovery:
+		return "LLDP", lldpDetail(p.l2Payload())
+	case p.hasEth || p.hasSLL:
+		et := p.etherType()
+		if et == layers.EthernetTypeLLC {
+			if !p.hasLLC {
+				return "LLC", "truncated"
+			}
+			return "LLC", fmt.Sprintf("dsap=0x%02x ssap=0x%02x", p.llc.DSAP, p.llc.SSAP)
+		}
+		return "ETH", fmt.Sprintf("type=0x%04x %s", uint16(et), et)
+	}
+	return "PKT", ""
+}
+
+func arpDetail(arp *layers.ARP) string {
+	if arp.AddrType != layers.LinkTypeEthernet || arp.Protocol != layers.EthernetTypeIPv4 {
+		return fmt.Sprintf("op=%d", arp.Operation)
+	}
+	sender := net.IP(arp.SourceProtAddress).String()
+	target := net.IP(arp.DstProtAddress).String()
+	switch arp.Operation {
+	case layers.ARPRequest:
+		if sender == target {
+			return "gratuitous " + sender
+		}
+		if net.IP(arp.SourceProtAddress).IsUnspecified() {
+			return "probe who-has " + target
+		}
+		return "who-has " + target + " tell " + sender
+	case layers.ARPReply:
+		return sender + " is-at " + net.HardwareAddr(arp.SourceHwAddress).String()
+	}
+	return fmt.Sprintf("op=%d %s -> %s", arp.Operation, sender, target)
+}
+
+func lldpDetail(data []byte) string {
+	pkt := gopacket.NewPacket(data, layers.LayerTypeLinkLayerDiscovery, gopacket.DecodeOptions{NoCopy: true})
+	lldp, ok := pkt.Layer(layers.LayerTypeLinkLayerDiscovery).(*layers.LinkLayerDiscovery)
+	if !ok {
+		return "malformed"
+	}
+
+	var parts []string
+	if info, ok := pkt.Layer(layers.LayerTypeLinkLayerDiscoveryInfo).(*layers.LinkLayerDiscoveryInfo); ok {
+		if info.SysName != "" {
+			parts = append(parts, "sys="+info.SysName)
+		}
+		if info.PortDescription != "" {
+			parts = append(parts, "port="+info.PortDescription)
+		} else {
+			parts = append(parts, "port="+lldpPortID(lldp.PortID))
+		}
+		if addr := info.MgmtAddress.Address; len(addr) == 4 || len(addr) == 16 {
+			parts = append(parts, "mgmt="+net.IP(addr).String())
+		}
+	} else {
+		parts = append(parts, "port="+lldpPortID(lldp.PortID))
+	}
+	parts = append(parts, "chassis="+lldpChassisID(lldp.ChassisID))
+	return strings.Join(parts, " ")
+}
+
+func lldpChassisID(id layers.LLDPChassisID) string {
+	if id.Subtype == layers.LLDPChassisIDSubTypeMACAddr && len(id.ID) == 6 {
+		return net.HardwareAddr(id.ID).String()
+	}
+	return printable(id.ID)
+}
+
+func lldpPortID(id layers.LLDPPortID) string {
+	if id.Subtype == layers.LLDPPortIDSubtypeMACAddr && len(id.ID) == 6 {
+		return net.HardwareAddr(id.ID).String()
+	}
+	return printable(id.ID)
+}
+
+func cdpDetail(data []byte) string {
+	pkt := gopacket.NewPacket(data, layers.LayerTypeCiscoDiscovery, gopacket.DecodeOptions{NoCopy: true})
+	info, ok := pkt.Layer(layers.LayerTypeCiscoDiscoveryInfo).(*layers.CiscoDiscoveryInfo)
+	if !ok {
+		return "malformed"
+	}
+
+	var parts []string
+	if info.DeviceID != "" {
+		parts = append(parts, "device="+info.DeviceID)
+	}
+	if info.PortID != "" {
+		parts = append(parts, "port="+info.PortID)
+	}
+	if len(info.Addresses) > 0 {
+		parts = append(parts, "addr="+info.Addresses[0].String())
+	}
+	if info.NativeVLAN != 0 {
+		parts = append(parts, fmt.Sprintf("vlan=%d", info.NativeVLAN))
+	}
+	if info.Platform != "" {
+		parts = append(parts, "platform="+info.Platform)
+	}
+	return strings.Join(parts, " ")
+}
+
+// stpDetail decodes the common part of an 802.1D/802.1w BPDU.
+func stpDetail(data []byte) string {
+	if len(data) < 4 || binary.BigEndian.Uint16(data[0:2]) != 0 {
+		return ""
+	}
+	version, bpduType := data[2], data[3]
+	kind := "STP"
+	if version >= 2 {
+		kind = "RSTP"
+	}
+	if bpduType == 0x80 {
+		return kind + " TCN"
+	}
+	if len(data) < 35 {
+		return kind
+	}
+	flags := data[4]
+	rootPrio := binary.BigEndian.Uint16(data[5:7])
+	rootMAC := net.HardwareAddr(data[7:13])
+	cost := binary.BigEndian.Uint32(data[13:17])
+	bridgePrio := binary.BigEndian.Uint16(data[17:19])
+	bridgeMAC := net.HardwareAddr(data[19:25])
+	port := binary.BigEndian.Uint16(data[25:27])
+
+	detail := fmt.Sprintf("%s root=%d/%s cost=%d bridge=%d/%s port=0x%04x",
+		kind, rootPrio, rootMAC, cost, bridgePrio, bridgeMAC, port)
+	if flags&0x01 != 0 {
+		detail += " TC"
+	}
+	return detail
+}
+
+func printable(b []byte) string {
+	i := 0
+	for i < len(b) && b[i] >= 0x20 && b[i] < 0x7f {
+		i++
+	}
+	if i == len(b) {
+		return string(b)
+	}
+	var sb strings.Builder
+	sb.Grow(len(b) + 8)
+	sb.Write(b[:i])
+	for _, c := range b[i:] {
+		if c >= 0x20 && c < 0x7f {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "\\x%02x", c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,816 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fe-dudu/netmon/internal/types"
+)
+
+const (
+	maxMessagingPackets = 32
+	maxTopicLen         = 120
+)
+
+func init() {
+	Register(Dissector{
+		Name:      "MQTT",
+		Priority:  60,
+		Transport: types.IPProtoTCP,
+		Ports:     []uint16{1883},
+		Match:     func(c *Context) bool { return isMQTTConnect(c.Payload) },
+		Detail:    func(c *Context) string { return c.mqttDetail() },
+		Color:     "lightsalmon",
+	})
+	Register(Dissector{
+		Name:      "AMQP",
+		Priority:  55,
+		Transport: types.IPProtoTCP,
+		Ports:     []uint16{5672},
+		Match:     func(c *Context) bool { return bytes.Equal(c.Payload, amqpHeader) },
+		Detail:    func(c *Context) string { return c.amqpDetail() },
+		Color:     "lightsalmon",
+	})
+	Register(Dissector{
+		Name:      "Kafka",
+		Priority:  110,
+		Transport: types.IPProtoTCP,
+		Ports:     []uint16{9092},
+		Match: func(c *Context) bool {
+			_, ok := kafkaRequestHeader(c.Payload)
+			return ok && !c.fromServer()
+		},
+		Detail: func(c *Context) string { return c.kafkaDetail() },
+		Color:  "lightsalmon",
+	})
+	types.ProtocolFilters = append(types.ProtocolFilters, types.FilterChoice{
+		Label:  "Messaging",
+		Desc:   "MQTT, AMQP 0-9-1 and Kafka publish/subscribe traffic (L7)",
+		Protos: []string{"MQTT", "AMQP", "Kafka"},
+	})
+}
+
+func topicName(b []byte) string {
+	if len(b) > maxTopicLen {
+		b = b[:maxTopicLen]
+	}
+	return printable(b)
+}
+
+func (c *Context) messagingMeta(info *types.MessagingInfo) {
+	if len(info.Topics) > 0 {
+		c.Meta = info
+	}
+}
+
+// MQTT 3.1, 3.1.1 and 5.0.
+
+var mqttPacketNames = []string{
+	"", "CONNECT", "CONNACK", "PUBLISH", "PUBACK", "PUBREC", "PUBREL", "PUBCOMP",
+	"SUBSCRIBE", "SUBACK", "UNSUBSCRIBE", "UNSUBACK", "PINGREQ", "PINGRESP", "DISCONNECT", "AUTH",
+}
+
+var mqttConnackNames = []string{
+	"accepted", "bad protocol", "client id rejected", "unavailable", "bad credentials", "not authorized",
+}
+
+var mqttVersions = map[byte]string{3: "3.1", 4: "3.1.1", 5: "5.0"}
+
+func isMQTTConnect(b []byte) bool {
+	if len(b) < 2 || b[0] != 0x10 {
+		return false
+	}
+	_, used, ok := mqttVarint(b[1:])
+	if !ok {
+		return false
+	}
+	rest := b[1+used:]
+	return bytes.HasPrefix(rest, []byte("\x00\x04MQTT")) || bytes.HasPrefix(rest, []byte("\x00\x06MQIsdp"))
+}
+
+// mqttVarint decodes a variable byte integer of at most four bytes.
+func mqttVarint(b []byte) (int, int, bool) {
+	v, shift := 0, 0
+	for i := 0; i < len(b) && i < 4; i++ {
+		v |= int(b[i]&0x7f) << shift
+		if b[i]&0x80 == 0 {
+			return v, i + 1, true
+		}
+		shift += 7
+	}
+	return 0, 0, false
+}
+
+func mqttString(b []byte) ([]byte, []byte, bool) {
+	if len(b) < 2 {
+		return nil, nil, false
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return nil, nil, false
+	}
+	return b[2 : 2+n], b[2+n:], true
+}
+
+func (c *Context) mqttDetail() string {
+	info := &types.MessagingInfo{}
+	var parts []string
+	b := c.Payload
+	for n := 0; len(b) >= 2 && n < maxMessagingPackets; n++ {
+		typ, flags := b[0]>>4, b[0]&0x0f
+		length, used, ok := mqttVarint(b[1:])
+		if !ok || typ == 0 {
+			break
+		}
+		body := b[1+used:]
+		truncated := length > len(body)
+		if !truncated {
+			body = body[:length]
+		}
+		parts = append(parts, mqttPacket(typ, flags, length, body, c.fromServer(), info))
+		if truncated {
+			break
+		}
+		b = b[1+used+length:]
+	}
+	if len(parts) == 0 {
+		return c.p.lenDetail(len(c.Payload))
+	}
+	c.messagingMeta(info)
+	return joinDetails(parts)
+}
+
+// mqttPacket describes one control packet. A PUBLISH from the broker is a
+// delivery to a subscriber.
+func mqttPacket(typ, flags byte, length int, body []byte, fromServer bool, info *types.MessagingInfo) string {
+	name := mqttPacketNames[typ]
+	switch typ {
+	case 1:
+		return mqttConnect(body)
+	case 2:
+		if len(body) < 2 {
+			return name
+		}
+		if int(body[1]) < len(mqttConnackNames) {
+			return name + " " + mqttConnackNames[body[1]]
+		}
+		return fmt.Sprintf("%s code=0x%02x", name, body[1])
+	case 3:
+		qos := (flags >> 1) & 0x3
+		topic, rest, ok := mqttString(body)
+		if !ok {
+			return name
+		}
+		text := fmt.Sprintf("%s %s qos=%d", name, topicName(topic), qos)
+		if flags&0x1 != 0 {
+			text += " retain"
+		}
+		if flags&0x8 != 0 {
+			text += " dup"
+		}
+		size := length - 2 - len(topic)
+		if qos > 0 && len(rest) >= 2 {
+			size -= 2
+			text += fmt.Sprintf(" id=%d", binary.BigEndian.Uint16(rest))
+		}
+		text += " len=" + strconv.Itoa(size)
+		info.Topics = append(info.Topics, types.TopicEvent{Name: topicName(topic), Publish: !fromServer, Bytes: size})
+		return text
+	case 8, 10:
+		if len(body) < 2 {
+			return name
+		}
+		filters := mqttFilters(body[2:], typ == 8)
+		if filters == nil {
+			// MQTT 5 puts properties before the filters.
+			if n, used, ok := mqttVarint(body[2:]); ok && 2+used+n <= len(body) {
+				filters = mqttFilters(body[2+used+n:], typ == 8)
+			}
+		}
+		return name + " " + strings.Join(filters, ",")
+	case 4, 5, 6, 7, 9, 11:
+		if len(body) >= 2 {
+			return fmt.Sprintf("%s id=%d", name, binary.BigEndian.Uint16(body))
+		}
+	}
+	return name
+}
+
+func mqttConnect(body []byte) string {
+	_, rest, ok := mqttString(body)
+	if !ok || len(rest) < 4 {
+		return "CONNECT"
+	}
+	level, flags := rest[0], rest[1]
+	keepalive := binary.BigEndian.Uint16(rest[2:4])
+	rest = rest[4:]
+	parts := []string{"CONNECT"}
+	if v, ok := mqttVersions[level]; ok {
+		parts = append(parts, "v"+v)
+	}
+	if level == 5 {
+		n, used, ok := mqttVarint(rest)
+		if !ok || used+n > len(rest) {
+			return strings.Join(parts, " ")
+		}
+		rest = rest[used+n:]
+	}
+	if id, after, ok := mqttString(rest); ok {
+		if len(id) > 0 {
+			parts = append(parts, "client="+printable(id))
+		}
+		rest = after
+	}
+	if flags&0x04 != 0 {
+		if level == 5 {
+			if n, used, ok := mqttVarint(rest); ok && used+n <= len(rest) {
+				rest = rest[used+n:]
+			}
+		}
+		if topic, after, ok := mqttString(rest); ok {
+			parts = append(parts, "will="+topicName(topic))
+			_, rest, _ = mqttString(after)
+		}
+	}
+	// The password that may follow is never shown.
+	if flags&0x80 != 0 {
+		if user, _, ok := mqttString(rest); ok {
+			parts = append(parts, "user="+printable(user))
+		}
+	}
+	parts = append(parts, "keepalive="+strconv.Itoa(int(keepalive)))
+	if flags&0x02 != 0 {
+		parts = append(parts, "clean")
+	}
+	return strings.Join(parts, " ")
+}
+
+// mqttFilters parses the topic filters of a SUBSCRIBE (each followed by an
+// options byte) or UNSUBSCRIBE, returning nil if they don't fill b exactly.
+func mqttFilters(b []byte, options bool) []string {
+	var filters []string
+	for len(b) > 0 {
+		f, rest, ok := mqttString(b)
+		if !ok || len(f) == 0 {
+			return nil
+		}
+		if options {
+			if len(rest) < 1 {
+				return nil
+			}
+			rest = rest[1:]
+		}
+		filters = append(filters, topicName(f))
+		b = rest
+	}
+	return filters
+}
+
+// AMQP 0-9-1.
+
+const (
+	amqpFrameMethod    = 1
+	amqpFrameHeader    = 2
+	amqpFrameBody      = 3
+	amqpFrameHeartbeat = 8
+	amqpFrameEnd       = 0xce
+)
+
+var amqpHeader = []byte("AMQP\x00\x00\x09\x01")
+
+var amqpMethodNames = map[uint32]string{
+	10<<16 | 10: "connection.start", 10<<16 | 11: "connection.start-ok",
+	10<<16 | 20: "connection.secure", 10<<16 | 21: "connection.secure-ok",
+	10<<16 | 30: "connection.tune", 10<<16 | 31: "connection.tune-ok",
+	10<<16 | 40: "connection.open", 10<<16 | 41: "connection.open-ok",
+	10<<16 | 50: "connection.close", 10<<16 | 51: "connection.close-ok",
+
+	20<<16 | 10: "channel.open", 20<<16 | 11: "channel.open-ok",
+	20<<16 | 20: "channel.flow", 20<<16 | 21: "channel.flow-ok",
+	20<<16 | 40: "channel.close", 20<<16 | 41: "channel.close-ok",
+
+	40<<16 | 10: "exchange.declare", 40<<16 | 11: "exchange.declare-ok",
+	40<<16 | 20: "exchange.delete", 40<<16 | 21: "exchange.delete-ok",
+	40<<16 | 30: "exchange.bind", 40<<16 | 31: "exchange.bind-ok",
+
+	50<<16 | 10: "queue.declare", 50<<16 | 11: "queue.declare-ok",
+	50<<16 | 20: "queue.bind", 50<<16 | 21: "queue.bind-ok",
+	50<<16 | 30: "queue.purge", 50<<16 | 31: "queue.purge-ok",
+	50<<16 | 40: "queue.delete", 50<<16 | 41: "queue.delete-ok",
+	50<<16 | 50: "queue.unbind", 50<<16 | 51: "queue.unbind-ok",
+
+	60<<16 | 10: "basic.qos", 60<<16 | 11: "basic.qos-ok",
+	60<<16 | 20: "basic.consume", 60<<16 | 21: "basic.consume-ok",
+	60<<16 | 30: "basic.cancel", 60<<16 | 31: "basic.cancel-ok",
+	60<<16 | 40: "basic.publish", 60<<16 | 50: "basic.return",
+	60<<16 | 60: "basic.deliver", 60<<16 | 70: "basic.get",
+	60<<16 | 71: "basic.get-ok", 60<<16 | 72: "basic.get-empty",
+	60<<16 | 80: "basic.ack", 60<<16 | 90: "basic.reject",
+	60<<16 | 110: "basic.recover", 60<<16 | 111: "basic.recover-ok",
+	60<<16 | 120: "basic.nack",
+
+	85<<16 | 10: "confirm.select", 85<<16 | 11: "confirm.select-ok",
+
+	90<<16 | 10: "tx.select", 90<<16 | 20: "tx.commit", 90<<16 | 30: "tx.rollback",
+}
+
+// amqpShortStr reads a shortstr: one length byte and the bytes.
+func amqpShortStr(b []byte) ([]byte, []byte, bool) {
+	if len(b) < 1 {
+		return nil, nil, false
+	}
+	n := int(b[0])
+	if len(b) < 1+n {
+		return nil, nil, false
+	}
+	return b[1 : 1+n], b[1+n:], true
+}
+
+func (c *Context) amqpDetail() string {
+	b := c.Payload
+	if bytes.HasPrefix(b, []byte("AMQP")) && len(b) == 8 {
+		return fmt.Sprintf("protocol header %d-%d-%d", b[5], b[6], b[7])
+	}
+
+	info := &types.MessagingInfo{}
+	var parts []string
+	for n := 0; len(b) >= 7 && n < maxMessagingPackets; n++ {
+		typ := b[0]
+		channel := binary.BigEndian.Uint16(b[1:3])
+		size := int(binary.BigEndian.Uint32(b[3:7]))
+		if typ != amqpFrameMethod && typ != amqpFrameHeader && typ != amqpFrameBody && typ != amqpFrameHeartbeat {
+			break
+		}
+		payload := b[7:]
+		complete := size+1 <= len(payload)
+		if complete {
+			if payload[size] != amqpFrameEnd {
+				break
+			}
+			payload = payload[:size]
+		}
+		switch typ {
+		case amqpFrameMethod:
+			parts = append(parts, amqpMethod(channel, payload, info))
+		case amqpFrameHeader:
+			// A content header carries the body size of the preceding
+			// publish or delivery.
+			if len(payload) >= 12 {
+				body := int(binary.BigEndian.Uint64(payload[4:12]))
+				if len(info.Topics) > 0 {
+					info.Topics[len(info.Topics)-1].Bytes = body
+				}
+				parts = append(parts, fmt.Sprintf("header body=%d", body))
+			}
+		case amqpFrameBody:
+			parts = append(parts, fmt.Sprintf("body len=%d", size))
+		case amqpFrameHeartbeat:
+			parts = append(parts, "heartbeat")
+		}
+		if !complete {
+			break
+		}
+		b = b[7+size+1:]
+	}
+	if len(parts) == 0 {
+		return c.p.lenDetail(len(c.Payload))
+	}
+	c.messagingMeta(info)
+	return joinDetails(parts)
+}
+
+func amqpMethod(channel uint16, b []byte, info *types.MessagingInfo) string {
+	if len(b) < 4 {
+		return "method"
+	}
+	id := binary.BigEndian.Uint32(b[0:4])
+	name, ok := amqpMethodNames[id]
+	if !ok {
+		name = fmt.Sprintf("method %d.%d", id>>16, id&0xffff)
+	}
+	args := b[4:]
+	var fields []string
+	// strs reads n shortstrs after skipping skip bytes.
+	strs := func(skip, n int) [][]byte {
+		if len(args) < skip {
+			return nil
+		}
+		rest := args[skip:]
+		out := make([][]byte, 0, n)
+		for i := 0; i < n; i++ {
+			s, after, ok := amqpShortStr(rest)
+			if !ok {
+				return out
+			}
+			out = append(out, s)
+			rest = after
+		}
+		return out
+	}
+
+	switch name {
+	case "connection.open":
+		if s := strs(0, 1); len(s) == 1 {
+			fields = append(fields, "vhost="+printable(s[0]))
+		}
+	case "connection.close", "channel.close":
+		if len(args) >= 2 {
+			fields = append(fields, "code="+strconv.Itoa(int(binary.BigEndian.Uint16(args))))
+			if s := strs(2, 1); len(s) == 1 && len(s[0]) > 0 {
+				fields = append(fields, printable(s[0][:min(len(s[0]), 80)]))
+			}
+		}
+	case "exchange.declare":
+		if s := strs(2, 2); len(s) == 2 {
+			fields = append(fields, "exchange="+printable(s[0]), "type="+printable(s[1]))
+		}
+	case "queue.declare", "queue.delete", "queue.purge":
+		if s := strs(2, 1); len(s) == 1 {
+			fields = append(fields, "queue="+printable(s[0]))
+		}
+	case "queue.declare-ok":
+		if s := strs(0, 1); len(s) == 1 && len(args) >= 1+len(s[0])+8 {
+			rest := args[1+len(s[0]):]
+			fields = append(fields, "queue="+printable(s[0]),
+				"messages="+strconv.Itoa(int(binary.BigEndian.Uint32(rest))),
+				"consumers="+strconv.Itoa(int(binary.BigEndian.Uint32(rest[4:]))))
+		}
+	case "queue.bind", "queue.unbind":
+		if s := strs(2, 3); len(s) == 3 {
+			fields = append(fields, "queue="+printable(s[0]), "exchange="+printable(s[1]), "key="+printable(s[2]))
+		}
+	case "basic.publish":
+		if s := strs(2, 2); len(s) == 2 {
+			topic := amqpTopic(s[0], s[1])
+			fields = append(fields, topic)
+			info.Topics = append(info.Topics, types.TopicEvent{Name: topic, Publish: true})
+		}
+	case "basic.consume":
+		if s := strs(2, 1); len(s) == 1 {
+			fields = append(fields, "queue="+printable(s[0]))
+		}
+	case "basic.deliver":
+		// consumer-tag, delivery-tag, redelivered, exchange, routing-key
+		if tag, rest, ok := amqpShortStr(args); ok && len(rest) >= 9 {
+			args = rest[9:]
+			if s := strs(0, 2); len(s) == 2 {
+				topic := amqpTopic(s[0], s[1])
+				fields = append(fields, topic, "consumer="+printable(tag))
+				info.Topics = append(info.Topics, types.TopicEvent{Name: topic})
+			}
+		}
+	case "basic.get":
+		if s := strs(2, 1); len(s) == 1 {
+			fields = append(fields, "queue="+printable(s[0]))
+		}
+	case "basic.ack", "basic.nack", "basic.reject":
+		if len(args) >= 8 {
+			fields = append(fields, "tag="+strconv.FormatUint(binary.BigEndian.Uint64(args), 10))
+		}
+	}
+	if channel != 0 {
+		fields = append(fields, "ch="+strconv.Itoa(int(channel)))
+	}
+	if len(fields) == 0 {
+		return name
+	}
+	return name + " " + strings.Join(fields, " ")
+}
+
+// amqpTopic names a publish or delivery by exchange and routing key, e.g.
+// "amq.topic/sensors.temp". The default exchange routes to the queue named
+// by the key.
+func amqpTopic(exchange, key []byte) string {
+	if len(exchange) == 0 {
+		return "queue " + topicName(key)
+	}
+	return topicName(exchange) + "/" + topicName(key)
+}
+
+// Kafka (request headers v0-v2).
+
+const (
+	kafkaProduce  = 0
+	kafkaFetch    = 1
+	kafkaMetadata = 3
+	// maxKafkaMessage bounds the size prefix; brokers default to 100MB.
+	maxKafkaMessage = 100 << 20
+)
+
+var kafkaAPINames = []string{
+	"Produce", "Fetch", "ListOffsets", "Metadata", "LeaderAndIsr", "StopReplica",
+	"UpdateMetadata", "ControlledShutdown", "OffsetCommit", "OffsetFetch",
+	"FindCoordinator", "JoinGroup", "Heartbeat", "LeaveGroup", "SyncGroup",
+	"DescribeGroups", "ListGroups", "SaslHandshake", "ApiVersions", "CreateTopics",
+	"DeleteTopics", "DeleteRecords", "InitProducerId", "OffsetForLeaderEpoch",
+	"AddPartitionsToTxn", "AddOffsetsToTxn", "EndTxn", "WriteTxnMarkers",
+	"TxnOffsetCommit", "DescribeAcls", "CreateAcls", "DeleteAcls", "DescribeConfigs",
+	"AlterConfigs", "AlterReplicaLogDirs", "DescribeLogDirs", "SaslAuthenticate",
+	"CreatePartitions", "CreateDelegationToken", "RenewDelegationToken",
+	"ExpireDelegationToken", "DescribeDelegationToken", "DeleteGroups",
+	"ElectLeaders", "IncrementalAlterConfigs", "AlterPartitionReassignments",
+	"ListPartitionReassignments", "OffsetDelete", "DescribeClientQuotas",
+	"AlterClientQuotas", "DescribeUserScramCredentials", "AlterUserScramCredentials",
+}
+
+// kafkaFlexible reports whether an API version uses the compact encodings
+// of KIP-482 for the APIs whose bodies are decoded.
+func kafkaFlexible(api, version int16) bool {
+	switch api {
+	case kafkaProduce:
+		return version >= 9
+	case kafkaFetch:
+		return version >= 12
+	case kafkaMetadata:
+		return version >= 9
+	}
+	return false
+}
+
+type kafkaHeader struct {
+	api, version int16
+	correlation  int32
+	client       string
+	body         []byte
+}
+
+// kafkaRequestHeader validates the size prefix and request header. The
+// segment may hold only the start of a large request.
+func kafkaRequestHeader(b []byte) (kafkaHeader, bool) {
+	var h kafkaHeader
+	if len(b) < 14 {
+		return h, false
+	}
+	size := int(binary.BigEndian.Uint32(b[0:4]))
+	if size < 10 || size > maxKafkaMessage {
+		return h, false
+	}
+	b = b[:min(len(b), 4+size)]
+	h.api = int16(binary.BigEndian.Uint16(b[4:6]))
+	h.version = int16(binary.BigEndian.Uint16(b[6:8]))
+	h.correlation = int32(binary.BigEndian.Uint32(b[8:12]))
+	if h.api < 0 || int(h.api) >= len(kafkaAPINames) || h.version < 0 || h.version > 20 {
+		return h, false
+	}
+	r := &kafkaReader{b: b[12:], ok: true}
+	client, null := r.str()
+	if !r.ok || (!null && !isPrintableASCII(client)) {
+		return h, false
+	}
+	h.client = string(client)
+	if kafkaFlexible(h.api, h.version) {
+		r.taggedFields()
+	}
+	h.body = r.b
+	return h, r.ok
+}
+
+func isPrintableASCII(b []byte) bool {
+	for _, c := range b {
+		if c < 0x20 || c >= 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Context) kafkaDetail() string {
+	b := c.Payload
+	h, ok := kafkaRequestHeader(b)
+	if !ok {
+		if c.fromServer() && len(b) >= 8 {
+			return fmt.Sprintf("response corr=%d size=%d",
+				int32(binary.BigEndian.Uint32(b[4:8])), binary.BigEndian.Uint32(b[0:4]))
+		}
+		return c.p.lenDetail(len(b))
+	}
+
+	parts := []string{fmt.Sprintf("%s v%d", kafkaAPINames[h.api], h.version)}
+	if h.client != "" {
+		parts = append(parts, "client="+printable([]byte(h.client)))
+	}
+	info := &types.MessagingInfo{}
+	r := &kafkaReader{b: h.body, ok: true, compact: kafkaFlexible(h.api, h.version)}
+	switch h.api {
+	case kafkaProduce:
+		kafkaProduceTopics(r, h.version, info)
+	case kafkaFetch:
+		kafkaFetchTopics(r, h.version, info)
+	case kafkaMetadata:
+		if h.version < 10 {
+			for n := r.arrayLen(); n > 0 && r.ok; n-- {
+				if name, _ := r.str(); r.ok {
+					info.Topics = append(info.Topics, types.TopicEvent{Name: topicName(name)})
+				}
+				if r.compact {
+					r.taggedFields()
+				}
+			}
+		}
+	}
+
+	var topics []string
+	for _, t := range info.Topics {
+		if !slices.Contains(topics, t.Name) {
+			topics = append(topics, t.Name)
+		}
+	}
+	if len(topics) > 0 {
+		text := "topics=" + strings.Join(topics[:min(len(topics), 3)], ",")
+		if len(topics) > 3 {
+			text += fmt.Sprintf(" +%d more", len(topics)-3)
+		}
+		parts = append(parts, text)
+	}
+	if h.api == kafkaProduce {
+		total := 0
+		for _, t := range info.Topics {
+			total += t.Bytes
+		}
+		parts = append(parts, "bytes="+strconv.Itoa(total))
+	} else if h.api == kafkaMetadata {
+		// Metadata lists topics without producing or consuming them.
+		info.Topics = nil
+	}
+	c.messagingMeta(info)
+	return strings.Join(parts, " ")
+}
+
+func kafkaProduceTopics(r *kafkaReader, version int16, info *types.MessagingInfo) {
+	if version >= 13 {
+		// Topics are identified by UUID from v13.
+		return
+	}
+	if version >= 3 {
+		r.str()
+	}
+	r.skip(6)
+	for n := r.arrayLen(); n > 0 && r.ok; n-- {
+		name, _ := r.str()
+		size := 0
+		for p := r.arrayLen(); p > 0 && r.ok; p-- {
+			r.skip(4)
+			size += r.bytesLen()
+			if r.compact {
+				r.taggedFields()
+			}
+		}
+		if r.compact {
+			r.taggedFields()
+		}
+		if len(name) > 0 {
+			info.Topics = append(info.Topics, types.TopicEvent{Name: topicName(name), Publish: true, Bytes: size})
+		}
+	}
+}
+
+func kafkaFetchTopics(r *kafkaReader, version int16, info *types.MessagingInfo) {
+	if version >= 12 {
+		// Flexible fetch requests move to tagged fields and topic IDs.
+		return
+	}
+	fixed := 12
+	if version >= 3 {
+		fixed += 4
+	}
+	if version >= 4 {
+		fixed++
+	}
+	if version >= 7 {
+		fixed += 8
+	}
+	r.skip(fixed)
+	partition := 4 + 8 + 4
+	if version >= 5 {
+		partition += 8
+	}
+	if version >= 9 {
+		partition += 4
+	}
+	for n := r.arrayLen(); n > 0 && r.ok; n-- {
+		name, _ := r.str()
+		parts := r.arrayLen()
+		if parts > 0 {
+			r.skip(parts * partition)
+		}
+		if r.ok && len(name) > 0 {
+			info.Topics = append(info.Topics, types.TopicEvent{Name: topicName(name)})
+		}
+	}
+}
+
+// kafkaReader decodes the primitive types of the Kafka protocol. A failed
+// read clears ok and makes later reads return zero values.
+type kafkaReader struct {
+	b       []byte
+	ok      bool
+	compact bool
+}
+
+func (r *kafkaReader) skip(n int) {
+	if !r.ok || n < 0 || len(r.b) < n {
+		r.ok = false
+		return
+	}
+	r.b = r.b[n:]
+}
+
+func (r *kafkaReader) i16() int {
+	if !r.ok || len(r.b) < 2 {
+		r.ok = false
+		return 0
+	}
+	v := int16(binary.BigEndian.Uint16(r.b))
+	r.b = r.b[2:]
+	return int(v)
+}
+
+func (r *kafkaReader) i32() int {
+	if !r.ok || len(r.b) < 4 {
+		r.ok = false
+		return 0
+	}
+	v := int32(binary.BigEndian.Uint32(r.b))
+	r.b = r.b[4:]
+	return int(v)
+}
+
+func (r *kafkaReader) uvarint() int {
+	if !r.ok {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 || v > maxKafkaMessage {
+		r.ok = false
+		return 0
+	}
+	r.b = r.b[n:]
+	return int(v)
+}
+
+// str reads a nullable string, compact or not, and reports whether it
+// was null.
+func (r *kafkaReader) str() ([]byte, bool) {
+	var n int
+	if r.compact {
+		n = r.uvarint() - 1
+	} else {
+		n = r.i16()
+	}
+	if !r.ok || n < 0 {
+		return nil, r.ok
+	}
+	if len(r.b) < n {
+		r.ok = false
+		return nil, false
+	}
+	s := r.b[:n]
+	r.b = r.b[n:]
+	return s, false
+}
+
+// bytesLen skips a (nullable) bytes field and returns its length, which may
+// extend past the end of the segment.
+func (r *kafkaReader) bytesLen() int {
+	var n int
+	if r.compact {
+		n = r.uvarint() - 1
+	} else {
+		n = r.i32()
+	}
+	if !r.ok || n < 0 {
+		return 0
+	}
+	if len(r.b) < n {
+		r.b = nil
+		r.ok = false
+		return n
+	}
+	r.b = r.b[n:]
+	return n
+}
+
+func (r *kafkaReader) arrayLen() int {
+	var n int
+	if r.compact {
+		n = r.uvarint() - 1
+	} else {
+		n = r.i32()
+	}
+	if n > 10000 {
+		r.ok = false
+		return 0
+	}
+	return n
+}
+
+func (r *kafkaReader) taggedFields() {
+	for n := r.uvarint(); n > 0 && r.ok; n-- {
+		r.uvarint()
+		r.skip(r.uvarint())
+	}
+}
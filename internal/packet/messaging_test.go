@@ -0,0 +1,256 @@
+package packet_test
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/fe-dudu/netmon/internal/packet"
+	"github.com/fe-dudu/netmon/internal/types"
+)
+
+const (
+	mqttPort  = 1883
+	amqpPort  = 5672
+	kafkaPort = 9092
+)
+
+func tcpFrame(t testing.TB, sport, dport layers.TCPPort, payload []byte) []byte {
+	t.Helper()
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP,
+		SrcIP: net.IPv4(192, 168, 1, 10), DstIP: net.IPv4(10, 0, 6, 10)}
+	if sport < dport {
+		ip.SrcIP, ip.DstIP = ip.DstIP, ip.SrcIP
+	}
+	tcp := &layers.TCP{SrcPort: sport, DstPort: dport, PSH: true, ACK: true, Window: 65535}
+	if err := tcp.SetNetworkLayerForChecksum(ip); err != nil {
+		t.Fatal(err)
+	}
+	buf := gopacket.NewSerializeBuffer()
+	err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true},
+		&layers.Ethernet{SrcMAC: net.HardwareAddr{2, 0, 0, 0, 0, 1}, DstMAC: net.HardwareAddr{2, 0, 0, 0, 0, 2}, EthernetType: layers.EthernetTypeIPv4},
+		ip, tcp, gopacket.Payload(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func str16(s string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(len(s))), s...)
+}
+
+func mqttPacket(header byte, body ...[]byte) []byte {
+	var b []byte
+	for _, part := range body {
+		b = append(b, part...)
+	}
+	out := []byte{header}
+	n := len(b)
+	for {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		out = append(out, digit)
+		if n == 0 {
+			break
+		}
+	}
+	return append(out, b...)
+}
+
+func amqpFrame(typ byte, channel uint16, payload []byte) []byte {
+	frame := binary.BigEndian.AppendUint16([]byte{typ}, channel)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(payload)))
+	return append(append(frame, payload...), 0xce)
+}
+
+func amqpMethod(class, method uint16, args ...[]byte) []byte {
+	b := binary.BigEndian.AppendUint16(binary.BigEndian.AppendUint16(nil, class), method)
+	for _, a := range args {
+		b = append(b, a...)
+	}
+	return b
+}
+
+func shortstr(s string) []byte {
+	return append([]byte{byte(len(s))}, s...)
+}
+
+func kafkaRequest(api, version uint16, client string, body ...[]byte) []byte {
+	req := binary.BigEndian.AppendUint16(make([]byte, 4), api)
+	req = binary.BigEndian.AppendUint16(req, version)
+	req = binary.BigEndian.AppendUint32(req, 42)
+	req = append(req, str16(client)...)
+	for _, part := range body {
+		req = append(req, part...)
+	}
+	binary.BigEndian.PutUint32(req, uint32(len(req)-4))
+	return req
+}
+
+func u32(v uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+func TestMessagingDetail(t *testing.T) {
+	longName := strings.Repeat("x", 255)
+	records := make([]byte, 64)
+
+	tests := []struct {
+		name          string
+		sport, dport  layers.TCPPort
+		payload       []byte
+		proto, detail string
+		topic         string
+		publish       bool
+		bytes         int
+	}{
+		{
+			name: "mqtt connect", sport: 50900, dport: mqttPort,
+			payload: mqttPacket(0x10, str16("MQTT"), []byte{4, 0xc2, 0, 60}, str16("thermostat-01"), str16("iot"), str16("secret")),
+			proto:   "MQTT", detail: "CONNECT v3.1.1 client=thermostat-01 user=iot keepalive=60 clean",
+		},
+		{
+			name: "mqtt publish", sport: 50900, dport: mqttPort,
+			payload: mqttPacket(0x32, str16("sensors/livingroom/temp"), []byte{0, 7}, []byte("21.5C+0.1C")),
+			proto:   "MQTT", detail: "PUBLISH sensors/livingroom/temp qos=1 id=7 len=10",
+			topic: "sensors/livingroom/temp", publish: true, bytes: 10,
+		},
+		{
+			name: "mqtt delivery and ack", sport: mqttPort, dport: 50900,
+			payload: append(mqttPacket(0x31, str16("alerts"), []byte("on")), mqttPacket(0x40, []byte{0, 9})...),
+			proto:   "MQTT", detail: "PUBLISH alerts qos=0 retain len=2; PUBACK id=9",
+			topic: "alerts", bytes: 2,
+		},
+		{
+			name: "mqtt subscribe", sport: 50900, dport: mqttPort,
+			payload: mqttPacket(0x82, []byte{0, 1}, str16("home/#"), []byte{1}, str16("office/+/temp"), []byte{0}),
+			proto:   "MQTT", detail: "SUBSCRIBE home/#,office/+/temp",
+		},
+		{
+			name: "mqtt publish cut short", sport: 50900, dport: mqttPort,
+			payload: mqttPacket(0x30, str16("big"), make([]byte, 500))[:40],
+			proto:   "MQTT", detail: "PUBLISH big qos=0 len=500",
+			topic: "big", publish: true, bytes: 500,
+		},
+		{
+			name: "amqp protocol header", sport: 51000, dport: amqpPort,
+			payload: []byte("AMQP\x00\x00\x09\x01"),
+			proto:   "AMQP", detail: "protocol header 0-9-1",
+		},
+		{
+			name: "amqp publish with content", sport: 51000, dport: amqpPort,
+			payload: append(append(
+				amqpFrame(1, 1, amqpMethod(60, 40, []byte{0, 0}, shortstr("amq.topic"), shortstr("orders.created"), []byte{0})),
+				amqpFrame(2, 1, append(append([]byte{0, 60, 0, 0}, binary.BigEndian.AppendUint64(nil, 11)...), 0, 0))...),
+				amqpFrame(3, 1, []byte("hello world"))...),
+			proto: "AMQP", detail: "basic.publish amq.topic/orders.created ch=1; header body=11; body len=11",
+			topic: "amq.topic/orders.created", publish: true, bytes: 11,
+		},
+		{
+			name: "amqp deliver", sport: amqpPort, dport: 51000,
+			payload: amqpFrame(1, 1, amqpMethod(60, 60, shortstr("ctag-1"), make([]byte, 8), []byte{0}, shortstr(""), shortstr("jobs"))),
+			proto:   "AMQP", detail: "basic.deliver queue jobs consumer=ctag-1 ch=1",
+			topic: "queue jobs",
+		},
+		{
+			// A 255-byte shortstr used to overflow the length arithmetic.
+			name: "amqp 255-byte shortstr", sport: 51000, dport: amqpPort,
+			payload: amqpFrame(1, 1, amqpMethod(50, 10, []byte{0, 0}, shortstr(longName))),
+			proto:   "AMQP", detail: "queue.declare queue=" + longName + " ch=1",
+		},
+		{
+			name: "kafka produce", sport: 51100, dport: kafkaPort,
+			payload: kafkaRequest(0, 7, "orders-svc", []byte{0xff, 0xff}, []byte{0, 1}, u32(30000),
+				u32(1), str16("orders"), u32(1), u32(0), u32(uint32(len(records))), records),
+			proto: "Kafka", detail: "Produce v7 client=orders-svc topics=orders bytes=64",
+			topic: "orders", publish: true, bytes: 64,
+		},
+		{
+			name: "kafka metadata", sport: 51100, dport: kafkaPort,
+			payload: kafkaRequest(3, 1, "admin", u32(2), str16("orders"), str16("payments")),
+			proto:   "Kafka", detail: "Metadata v1 client=admin topics=orders,payments",
+		},
+		{
+			name: "kafka fetch", sport: 51100, dport: kafkaPort,
+			payload: kafkaRequest(1, 4, "consumer", u32(0xffffffff), u32(500), u32(1), u32(1<<20), []byte{0},
+				u32(1), str16("orders"), u32(1), make([]byte, 16)),
+			proto: "Kafka", detail: "Fetch v4 client=consumer topics=orders",
+			topic: "orders",
+		},
+		{
+			name: "kafka response", sport: kafkaPort, dport: 51100,
+			payload: append(u32(120), u32(42)...),
+			proto:   "Kafka", detail: "response corr=42 size=120",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkt := parseFrame(tcpFrame(t, tt.sport, tt.dport, tt.payload))
+			if pkt.Proto != tt.proto || pkt.Detail != tt.detail {
+				t.Fatalf("got %s %q, want %s %q", pkt.Proto, pkt.Detail, tt.proto, tt.detail)
+			}
+			info, _ := pkt.Meta.(*types.MessagingInfo)
+			if tt.topic == "" {
+				if info != nil {
+					t.Errorf("Meta = %+v, want none", info)
+				}
+				return
+			}
+			if info == nil || len(info.Topics) == 0 {
+				t.Fatalf("Meta = %#v, want topic %q", pkt.Meta, tt.topic)
+			}
+			got := info.Topics[0]
+			if got.Name != tt.topic || got.Publish != tt.publish || got.Bytes != tt.bytes {
+				t.Errorf("topic = %+v, want %q publish=%v bytes=%d", got, tt.topic, tt.publish, tt.bytes)
+			}
+		})
+	}
+}
+
+func fuzzMessaging(f *testing.F, port layers.TCPPort, seeds ...[]byte) {
+	for _, seed := range seeds {
+		f.Add(seed, false)
+		f.Add(seed, true)
+	}
+	p := packet.NewParser()
+	f.Fuzz(func(t *testing.T, payload []byte, fromServer bool) {
+		sport, dport := layers.TCPPort(50000), port
+		if fromServer {
+			sport, dport = dport, sport
+		}
+		frame := tcpFrame(t, sport, dport, payload)
+		ci := gopacket.CaptureInfo{CaptureLength: len(frame), Length: len(frame)}
+		p.Parse(frame, ci, layers.LinkTypeEthernet)
+	})
+}
+
+func FuzzMQTT(f *testing.F) {
+	fuzzMessaging(f, mqttPort,
+		mqttPacket(0x10, str16("MQTT"), []byte{5, 0xc6, 0, 60, 0}, str16("c"), []byte{0}, str16("will"), str16("bye")),
+		mqttPacket(0x32, str16("a/b"), []byte{0, 1}, []byte("x")),
+		mqttPacket(0x82, []byte{0, 1, 0}, str16("a/#"), []byte{1}))
+}
+
+func FuzzAMQP(f *testing.F) {
+	fuzzMessaging(f, amqpPort,
+		[]byte("AMQP\x00\x00\x09\x01"),
+		amqpFrame(1, 1, amqpMethod(60, 40, []byte{0, 0}, shortstr("ex"), shortstr("key"), []byte{0})),
+		amqpFrame(1, 1, amqpMethod(60, 60, shortstr("tag"), make([]byte, 9), shortstr("ex"), shortstr("key"))),
+		amqpFrame(1, 0, amqpMethod(10, 50, []byte{1, 64}, shortstr("CONNECTION_FORCED"))))
+}
+
+func FuzzKafka(f *testing.F) {
+	fuzzMessaging(f, kafkaPort,
+		kafkaRequest(0, 7, "p", []byte{0xff, 0xff}, []byte{0, 1}, u32(0), u32(1), str16("t"), u32(1), u32(0), u32(1), []byte{0}),
+		kafkaRequest(0, 9, "p", []byte{0, 0, 1, 0, 0, 0, 0, 0, 2, 2, 't', 2, 0, 0, 0, 0, 2, 0, 0, 0, 0}),
+		kafkaRequest(3, 9, "m", []byte{0, 2, 2, 't', 0, 0}),
+		kafkaRequest(1, 11, "c", make([]byte, 40)))
+}
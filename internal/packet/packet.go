@@ -2,121 +2,215 @@ package packet
 
 import (
 	"bytes"
-	"fmt"
+	"encoding/binary"
+	"net/netip"
+	"strconv"
 	"strings"
-	"time"
 
-	"github.com/google/gopacket"
-	"github.com/google/gopacket/layers"
 	"github.com/fe-dudu/netmon/internal/types"
+	"github.com/google/gopacket/layers"
 )
 
-func ParsePacket(packet gopacket.Packet) types.PacketInfo {
-	ts := time.Now()
-	if meta := packet.Metadata(); meta != nil && !meta.Timestamp.IsZero() {
-		ts = meta.Timestamp
+func (p *Parser) Endpoints(info *types.PacketInfo) {
+	if p.hasIPv4 {
+		info.SrcAddr, _ = netip.AddrFromSlice(p.ip4.SrcIP)
+		info.DstAddr, _ = netip.AddrFromSlice(p.ip4.DstIP)
+	} else if p.hasIPv6 {
+		info.SrcAddr, _ = netip.AddrFromSlice(p.ip6.SrcIP)
+		info.DstAddr, _ = netip.AddrFromSlice(p.ip6.DstIP)
 	}
 
-	src, dst := Endpoints(packet)
-	proto, detail := Classify(packet)
-
-	return types.PacketInfo{
-		Timestamp: ts,
-		Proto:     proto,
-		Src:       src,
-		Dst:       dst,
-		Detail:    detail,
+	if p.hasTCP {
+		info.SrcPort, info.DstPort = uint16(p.tcp.SrcPort), uint16(p.tcp.DstPort)
+		info.HasPorts = true
+	} else if p.hasUDP {
+		info.SrcPort, info.DstPort = uint16(p.udp.SrcPort), uint16(p.udp.DstPort)
+		info.HasPorts = true
 	}
 }
 
-func Endpoints(packet gopacket.Packet) (string, string) {
-	src := "unknown"
-	dst := "unknown"
-
-	if ipv4 := packet.Layer(layers.LayerTypeIPv4); ipv4 != nil {
-		ip := ipv4.(*layers.IPv4)
-		src = ip.SrcIP.String()
-		dst = ip.DstIP.String()
-	} else if ipv6 := packet.Layer(layers.LayerTypeIPv6); ipv6 != nil {
-		ip := ipv6.(*layers.IPv6)
-		src = ip.SrcIP.String()
-		dst = ip.DstIP.String()
-	} else if nl := packet.NetworkLayer(); nl != nil {
-		src = nl.NetworkFlow().Src().String()
-		dst = nl.NetworkFlow().Dst().String()
-	}
-
-	if tcpLayer := packet.Layer(layers.LayerTypeTCP); tcpLayer != nil {
-		tcp := tcpLayer.(*layers.TCP)
-		src = fmt.Sprintf("%s:%d", src, tcp.SrcPort)
-		dst = fmt.Sprintf("%s:%d", dst, tcp.DstPort)
-	} else if udpLayer := packet.Layer(layers.LayerTypeUDP); udpLayer != nil {
-		udp := udpLayer.(*layers.UDP)
-		src = fmt.Sprintf("%s:%d", src, udp.SrcPort)
-		dst = fmt.Sprintf("%s:%d", dst, udp.DstPort)
-	}
-
-	return src, dst
+func (p *Parser) Headers(info *types.PacketInfo) {
+	if p.hasEth {
+		copy(info.SrcMAC[:], p.eth.SrcMAC)
+		copy(info.DstMAC[:], p.eth.DstMAC)
+	} else if p.hasSLL && p.sll.AddrLen == 6 {
+		copy(info.SrcMAC[:], p.sll.Addr)
+	}
+	if p.hasDot1Q {
+		info.VLAN = p.dot1q.VLANIdentifier
+		// The Dot1Q layer is reused, so with stacked tags (QinQ) it holds the
+		// inner one; read the outer tag from the Ethernet payload.
+		if p.hasEth && len(p.eth.Payload) >= 4 && isVLANType(binary.BigEndian.Uint16(p.eth.Payload[2:4])) {
+			info.OuterVLAN = binary.BigEndian.Uint16(p.eth.Payload[0:2]) & 0x0fff
+		}
+	}
+	info.EtherType = uint16(p.etherType())
+
+	if p.hasIPv4 {
+		info.IPVersion = 4
+		info.TTL = p.ip4.TTL
+		info.L4Proto = uint8(p.ip4.Protocol)
+		info.PayloadLen = len(p.ip4.Payload)
+	} else if p.hasIPv6 {
+		info.IPVersion = 6
+		info.TTL = p.ip6.HopLimit
+		info.L4Proto = uint8(p.ip6.NextHeader)
+		info.PayloadLen = len(p.ip6.Payload)
+	}
+	if p.frag.ok {
+		info.L4Proto = uint8(p.frag.proto)
+	}
+
+	switch {
+	case p.hasTCP:
+		info.L4Proto = types.IPProtoTCP
+		info.PayloadLen = len(p.tcp.Payload)
+		info.TCPFlags = tcpFlags(&p.tcp)
+	case p.hasUDP:
+		info.L4Proto = types.IPProtoUDP
+		info.PayloadLen = len(p.udp.Payload)
+	case p.hasICMPv4:
+		info.L4Proto = types.IPProtoICMP
+		info.PayloadLen = len(p.icmp4.Payload)
+	case p.hasICMPv6:
+		info.L4Proto = types.IPProtoICMPv6
+		info.PayloadLen = len(p.icmp6.Payload)
+	}
 }
 
-func Classify(packet gopacket.Packet) (string, string) {
-	if dnsLayer := packet.Layer(layers.LayerTypeDNS); dnsLayer != nil {
-		dns := dnsLayer.(*layers.DNS)
-		detail := ""
-		if len(dns.Questions) > 0 {
-			q := dns.Questions[0]
-			name := string(q.Name)
-			if len(name) > 60 {
-				name = name[:57] + "..."
-			}
-			detail = fmt.Sprintf("Q %s %s", q.Type.String(), name)
-		} else if len(dns.Answers) > 0 {
-			a := dns.Answers[0]
-			name := string(a.Name)
-			if len(name) > 60 {
-				name = name[:57] + "..."
-			}
-			detail = fmt.Sprintf("A %s %s", a.Type.String(), name)
-		}
-		return "DNS", detail
+func (p *Parser) Classify() (string, string) {
+	c := &p.ctx
+	*c = Context{p: p}
+	switch {
+	case p.hasTCP:
+		c.Transport = types.IPProtoTCP
+		c.SrcPort, c.DstPort = uint16(p.tcp.SrcPort), uint16(p.tcp.DstPort)
+		c.Payload = p.tcp.Payload
+	case p.hasUDP:
+		c.Transport = types.IPProtoUDP
+		c.SrcPort, c.DstPort = uint16(p.udp.SrcPort), uint16(p.udp.DstPort)
+		c.Payload = p.udp.Payload
 	}
 
-	if tcpLayer := packet.Layer(layers.LayerTypeTCP); tcpLayer != nil {
-		tcp := tcpLayer.(*layers.TCP)
-		if info := MaybeHTTPInfo(tcp); info != "" {
-			return "HTTP", info
+	if c.Transport != 0 {
+		if d, detail := dissect(c); d != nil {
+			return d.Name, detail
 		}
-		if tcp.SrcPort == 443 || tcp.DstPort == 443 {
-			return "TLS", ""
-		}
-		flags := SummarizeTCPFlags(tcp)
-		if flags == "" {
-			return "TCP", ""
-		}
-		return "TCP", fmt.Sprintf("flags=%s", flags)
 	}
 
-	if udpLayer := packet.Layer(layers.LayerTypeUDP); udpLayer != nil {
-		udp := udpLayer.(*layers.UDP)
-		if udp.SrcPort == 443 || udp.DstPort == 443 {
-			return "QUIC", fmt.Sprintf("len=%d", len(udp.Payload))
+	if p.hasTCP {
+		// The tracker knows whether the connection was upgraded to
+		// WebSocket or has an HTTP/2 frame to finish, so it does the framing.
+		if len(c.Payload) > 0 {
+			c.Meta = &types.TCPSegment{Payload: c.Payload}
 		}
-		return "UDP", fmt.Sprintf("len=%d", len(udp.Payload))
+		return "TCP", tcpFlagDetails[tcpFlags(&p.tcp)]
+	}
+	if p.hasUDP {
+		return "UDP", p.lenDetail(len(p.udp.Payload))
 	}
 
-	if icmpLayer := packet.Layer(layers.LayerTypeICMPv4); icmpLayer != nil {
-		return "ICMP", ""
+	if p.hasICMPv4 {
+		return "ICMP", p.icmpv4Detail()
 	}
 
-	if icmpLayer := packet.Layer(layers.LayerTypeICMPv6); icmpLayer != nil {
-		return "ICMPv6", ""
+	if p.hasICMPv6 {
+		return "ICMPv6", p.icmpv6Detail()
 	}
 
-	if l := packet.NetworkLayer(); l != nil {
-		return l.LayerType().String(), ""
+	if p.hasIPv4 {
+		return layers.LayerTypeIPv4.String(), ""
+	}
+	if p.hasIPv6 {
+		return layers.LayerTypeIPv6.String(), ""
 	}
 
-	return "PKT", ""
+	return p.classifyL2()
+}
+
+func (p *Parser) dnsSummary() string {
+	dns := &p.dns
+	if len(dns.Questions) > 0 {
+		q := dns.Questions[0]
+		return p.dnsDetail("Q ", q.Type, q.Name)
+	}
+	if len(dns.Answers) > 0 {
+		a := dns.Answers[0]
+		return p.dnsDetail("A ", a.Type, a.Name)
+	}
+	return ""
+}
+
+func (p *Parser) dnsDetail(prefix string, typ layers.DNSType, name []byte) string {
+	p.buf = append(p.buf[:0], prefix...)
+	p.buf = append(p.buf, typ.String()...)
+	p.buf = append(p.buf, ' ')
+	if len(name) > 60 {
+		p.buf = append(p.buf, name[:57]...)
+		p.buf = append(p.buf, "..."...)
+	} else {
+		p.buf = append(p.buf, name...)
+	}
+	return string(p.buf)
+}
+
+func (p *Parser) lenDetail(n int) string {
+	p.buf = append(p.buf[:0], "len="...)
+	p.buf = strconv.AppendInt(p.buf, int64(n), 10)
+	return string(p.buf)
+}
+
+// tcpFlagDetails holds the "flags=..." detail for every flag combination so
+// classifying a TCP segment does not allocate.
+var tcpFlagDetails = func() [256]string {
+	var details [256]string
+	for bits := range details {
+		flags := FormatTCPFlags(uint8(bits))
+		if flags != "" {
+			details[bits] = "flags=" + flags
+		}
+	}
+	return details
+}()
+
+func tcpFlags(tcp *layers.TCP) uint8 {
+	var flags uint8
+	if tcp.FIN {
+		flags |= types.TCPFlagFIN
+	}
+	if tcp.SYN {
+		flags |= types.TCPFlagSYN
+	}
+	if tcp.RST {
+		flags |= types.TCPFlagRST
+	}
+	if tcp.PSH {
+		flags |= types.TCPFlagPSH
+	}
+	if tcp.ACK {
+		flags |= types.TCPFlagACK
+	}
+	if tcp.URG {
+		flags |= types.TCPFlagURG
+	}
+	if tcp.ECE {
+		flags |= types.TCPFlagECE
+	}
+	if tcp.CWR {
+		flags |= types.TCPFlagCWR
+	}
+	return flags
+}
+
+func FormatTCPFlags(flags uint8) string {
+	return SummarizeTCPFlags(&layers.TCP{
+		SYN: flags&types.TCPFlagSYN != 0,
+		ACK: flags&types.TCPFlagACK != 0,
+		FIN: flags&types.TCPFlagFIN != 0,
+		RST: flags&types.TCPFlagRST != 0,
+		PSH: flags&types.TCPFlagPSH != 0,
+		URG: flags&types.TCPFlagURG != 0,
+	})
 }
 
 func SummarizeTCPFlags(tcp *layers.TCP) string {
@@ -142,31 +236,6 @@ func SummarizeTCPFlags(tcp *layers.TCP) string {
 	return strings.Join(flags, ",")
 }
 
-func MaybeHTTPInfo(tcp *layers.TCP) string {
-	if len(tcp.Payload) == 0 {
-		return ""
-	}
-	if !(tcp.DstPort == 80 || tcp.SrcPort == 80 || tcp.DstPort == 8080 || tcp.SrcPort == 8080) {
-		return ""
-	}
-
-	line := FirstLine(tcp.Payload)
-	if line == "" {
-		return ""
-	}
-
-	httpMethods := []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS", "CONNECT", "TRACE"}
-	for _, m := range httpMethods {
-		if strings.HasPrefix(line, m+" ") {
-			return sanitizeHTTPLine(line)
-		}
-	}
-	if strings.HasPrefix(line, "HTTP/") {
-		return sanitizeHTTPLine(line)
-	}
-	return ""
-}
-
 func sanitizeHTTPLine(line string) string {
 	if idx := strings.Index(line, "?"); idx != -1 {
 		parts := strings.Fields(line)
@@ -180,11 +249,11 @@ func sanitizeHTTPLine(line string) string {
 			line = line[:idx] + " ?..."
 		}
 	}
-	
+
 	if len(line) > 100 {
 		line = line[:97] + "..."
 	}
-	
+
 	return line
 }
 
@@ -202,25 +271,88 @@ func MatchesFilter(filterIdx int, pkt types.PacketInfo) bool {
 	}
 
 	filter := types.ProtocolFilters[filterIdx]
+	if len(filter.Protos) > 0 || len(filter.Ports) > 0 || len(filter.Hosts) > 0 {
+		return MatchesCustomFilter(filter, pkt)
+	}
 
 	switch filter.Label {
 	case "ALL":
 		return true
+	case "L2":
+		return pkt.IPVersion == 0
 	case "TCP":
-		return pkt.Proto == "TCP" || pkt.Proto == "HTTP" || pkt.Proto == "TLS"
+		return pkt.L4Proto == types.IPProtoTCP
 	case "UDP":
-		return pkt.Proto == "UDP" || pkt.Proto == "DNS"
+		return pkt.L4Proto == types.IPProtoUDP
 	case "QUIC":
 		return pkt.Proto == "QUIC"
 	case "DNS":
 		return pkt.Proto == "DNS"
 	case "HTTP":
-		return pkt.Proto == "HTTP"
+		return pkt.Proto == "HTTP" || pkt.Proto == "HTTP2" || pkt.Proto == "gRPC" || pkt.Proto == "WebSocket"
 	case "HTTPS":
 		return pkt.Proto == "TLS"
 	case "ICMP":
-		return pkt.Proto == "ICMP" || pkt.Proto == "ICMPv6"
+		return pkt.L4Proto == types.IPProtoICMP || pkt.L4Proto == types.IPProtoICMPv6
 	default:
 		return true
 	}
 }
+
+func MatchesCustomFilter(filter types.FilterChoice, pkt types.PacketInfo) bool {
+	if len(filter.Protos) > 0 {
+		matched := false
+		for _, p := range filter.Protos {
+			if strings.EqualFold(p, pkt.Proto) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(filter.Ports) > 0 {
+		matched := false
+		for _, p := range filter.Ports {
+			if pkt.HasPorts && (int(pkt.SrcPort) == p || int(pkt.DstPort) == p) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(filter.Hosts) > 0 {
+		matched := false
+		for _, h := range filter.Hosts {
+			if MatchesHost(pkt.SrcAddr, h) || MatchesHost(pkt.DstAddr, h) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+func MatchesHost(addr netip.Addr, host string) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap()
+	if prefix, err := netip.ParsePrefix(host); err == nil {
+		return prefix.Contains(addr)
+	}
+	want, err := netip.ParseAddr(host)
+	if err != nil {
+		return addr.String() == host
+	}
+	return addr == want.Unmap()
+}
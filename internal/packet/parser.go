@@ -0,0 +1,190 @@
+package packet
+
+import (
+	"time"
+
+	"github.com/fe-dudu/netmon/internal/types"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+type Parser struct {
+	eth     layers.Ethernet
+	loop    layers.Loopback
+	sll     layers.LinuxSLL
+	dot1q   layers.Dot1Q
+	arp     layers.ARP
+	llc     layers.LLC
+	snap    layers.SNAP
+	ip4     ipv4
+	ip6     ipv6
+	tcp     layers.TCP
+	udp     layers.UDP
+	icmp4   layers.ICMPv4
+	icmp6   layers.ICMPv6
+	dns     layers.DNS
+	dhcp4   layers.DHCPv4
+	dhcp6   layers.DHCPv6
+	payload gopacket.Payload
+
+	parsers map[gopacket.LayerType]*gopacket.DecodingLayerParser
+	decoded []gopacket.LayerType
+	buf     []byte
+	ctx     Context
+
+	// Reassembler, when set, is shared between parsers so fragments decoded
+	// by different workers can be joined.
+	Reassembler *Reassembler
+	// RedactQueries replaces literals in database queries and keys and
+	// values in Redis commands.
+	RedactQueries bool
+	upper         []gopacket.LayerType
+	frag          fragInfo
+	exts          []string
+	// ipProto and ipPayload are the upper layer after extension headers and
+	// reassembly.
+	ipProto   layers.IPProtocol
+	ipPayload []byte
+
+	hasEth, hasSLL       bool
+	hasDot1Q             bool
+	hasARP               bool
+	hasLLC, hasSNAP      bool
+	hasIPv4, hasIPv6     bool
+	hasTCP, hasUDP       bool
+	hasICMPv4, hasICMPv6 bool
+	hasDNS               bool
+}
+
+func NewParser() *Parser {
+	p := &Parser{
+		parsers: make(map[gopacket.LayerType]*gopacket.DecodingLayerParser),
+		decoded: make([]gopacket.LayerType, 0, 8),
+		upper:   make([]gopacket.LayerType, 0, 4),
+		exts:    make([]string, 0, 4),
+		buf:     make([]byte, 0, 128),
+	}
+	decoders := []gopacket.DecodingLayer{
+		&p.eth, &p.loop, &p.sll, &p.dot1q,
+		&p.arp, &p.llc, &p.snap,
+		&p.ip4, &p.ip6,
+		&p.tcp, &p.udp, &p.icmp4, &p.icmp6,
+		&p.dns, &p.payload,
+	}
+	for _, first := range []gopacket.LayerType{
+		layers.LayerTypeEthernet, layers.LayerTypeLoopback, layers.LayerTypeLinuxSLL,
+		layers.LayerTypeIPv4, layers.LayerTypeIPv6,
+		// Upper layers of reassembled datagrams and IPv6 extension chains.
+		layers.LayerTypeTCP, layers.LayerTypeUDP, layers.LayerTypeICMPv4, layers.LayerTypeICMPv6,
+	} {
+		dlp := gopacket.NewDecodingLayerParser(first, decoders...)
+		dlp.IgnoreUnsupported = true
+		p.parsers[first] = dlp
+	}
+	return p
+}
+
+func (p *Parser) Parse(data []byte, ci gopacket.CaptureInfo, linkType layers.LinkType) types.PacketInfo {
+	ts := ci.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	info := p.parse(data, firstLayer(data, linkType), ts, 0)
+	if ci.Length != 0 {
+		info.Length = ci.Length
+	}
+	return info
+}
+
+func (p *Parser) parse(data []byte, first gopacket.LayerType, ts time.Time, depth int) types.PacketInfo {
+	p.decode(data, first)
+	p.reassemble(ts)
+	info := types.PacketInfo{Timestamp: ts, Length: len(data)}
+	p.Endpoints(&info)
+	p.Headers(&info)
+	info.Proto, info.Detail = p.Classify()
+	if p.frag.ok || len(p.exts) > 0 {
+		info.Detail = p.fragDetail(info.Detail)
+	}
+	info.Meta = p.ctx.Meta
+	if depth < maxTunnelDepth {
+		if t, ok := p.tunnel(); ok {
+			p.decapsulate(&info, t, depth)
+		}
+	}
+	return info
+}
+
+func (p *Parser) decode(data []byte, first gopacket.LayerType) {
+	p.decoded = p.decoded[:0]
+	p.hasEth, p.hasSLL, p.hasDot1Q = false, false, false
+	p.hasARP, p.hasLLC, p.hasSNAP = false, false, false
+	p.hasIPv4, p.hasIPv6 = false, false
+	p.hasTCP, p.hasUDP = false, false
+	p.hasICMPv4, p.hasICMPv6 = false, false
+	p.hasDNS = false
+
+	dlp, ok := p.parsers[first]
+	if !ok {
+		return
+	}
+	// Truncated or malformed packets still report the layers decoded so far.
+	_ = dlp.DecodeLayers(data, &p.decoded)
+	p.markDecoded(p.decoded)
+}
+
+func (p *Parser) markDecoded(decoded []gopacket.LayerType) {
+	for _, lt := range decoded {
+		switch lt {
+		case layers.LayerTypeEthernet:
+			p.hasEth = true
+		case layers.LayerTypeLinuxSLL:
+			p.hasSLL = true
+		case layers.LayerTypeDot1Q:
+			p.hasDot1Q = true
+		case layers.LayerTypeARP:
+			p.hasARP = true
+		case layers.LayerTypeLLC:
+			p.hasLLC = true
+		case layers.LayerTypeSNAP:
+			p.hasSNAP = true
+		case layers.LayerTypeIPv4:
+			p.hasIPv4 = true
+		case layers.LayerTypeIPv6:
+			p.hasIPv6 = true
+		case layers.LayerTypeTCP:
+			p.hasTCP = true
+		case layers.LayerTypeUDP:
+			p.hasUDP = true
+		case layers.LayerTypeICMPv4:
+			p.hasICMPv4 = true
+		case layers.LayerTypeICMPv6:
+			p.hasICMPv6 = true
+		case layers.LayerTypeDNS:
+			p.hasDNS = true
+		}
+	}
+}
+
+func firstLayer(data []byte, linkType layers.LinkType) gopacket.LayerType {
+	switch linkType {
+	case layers.LinkTypeEthernet:
+		return layers.LayerTypeEthernet
+	case layers.LinkTypeNull, layers.LinkTypeLoop:
+		return layers.LayerTypeLoopback
+	case layers.LinkTypeLinuxSLL:
+		return layers.LayerTypeLinuxSLL
+	case layers.LinkTypeIPv4:
+		return layers.LayerTypeIPv4
+	case layers.LinkTypeIPv6:
+		return layers.LayerTypeIPv6
+	case layers.LinkTypeRaw, 12: // DLT_RAW is 12 on BSD/macOS
+		if len(data) > 0 && data[0]>>4 == 6 {
+			return layers.LayerTypeIPv6
+		}
+		return layers.LayerTypeIPv4
+	default:
+		return gopacket.LayerTypeZero
+	}
+}
@@ -0,0 +1,31 @@
+package packet_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/fe-dudu/netmon/internal/network"
+	"github.com/fe-dudu/netmon/internal/packet"
+)
+
+// BenchmarkParse reports time and allocations per frame over the demo
+// traffic, which covers L2, tunnels, fragments and the L7 dissectors.
+func BenchmarkParse(b *testing.B) {
+	frames, err := network.DemoFrames()
+	if err != nil {
+		b.Fatal(err)
+	}
+	p := packet.NewParser()
+	ci := gopacket.CaptureInfo{Timestamp: time.Unix(1700000000, 0)}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		frame := frames[i%len(frames)]
+		ci.CaptureLength, ci.Length = len(frame), len(frame)
+		p.Parse(frame, ci, layers.LinkTypeEthernet)
+	}
+}
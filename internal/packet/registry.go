@@ -0,0 +1,140 @@
+package packet
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/fe-dudu/netmon/internal/types"
+)
+
+// Context is the transport-level view of a packet handed to dissectors. It is
+// reused by the parser, so dissectors must not keep references to it or to
+// Payload after returning.
+type Context struct {
+	Transport uint8
+	SrcPort   uint16
+	DstPort   uint16
+	Payload   []byte
+	// Meta may be set by Detail to pass structured data on to trackers.
+	Meta any
+
+	p *Parser
+}
+
+func (c *Context) HasPort(port uint16) bool {
+	return c.SrcPort == port || c.DstPort == port
+}
+
+type Dissector struct {
+	// Name is the protocol label shown in the packet list.
+	Name string
+	// Transport restricts the dissector to types.IPProtoTCP or
+	// types.IPProtoUDP; zero means both.
+	Transport uint8
+	// Ports are used when no dissector recognises the payload itself.
+	Ports []uint16
+	// Match inspects the payload and claims the packet on any port.
+	Match func(c *Context) bool
+	// Priority orders dissectors, lowest first, both for Match and for
+	// ports shared by several dissectors; ties keep registration order.
+	// The built-in ones use 10-99 for protocols the parser decodes or that
+	// start with a fixed signature, 100-199 for shape heuristics and
+	// 200-299 for port-only protocols. Zero means PriorityDefault.
+	Priority int
+	// Detail returns the text shown after the endpoints.
+	Detail func(c *Context) string
+	Color  string
+	// Tab, when set, adds a protocol tab with this description.
+	Tab string
+}
+
+// PriorityDefault places a dissector after the built-in ones.
+const PriorityDefault = 1000
+
+var (
+	dissectors []*Dissector
+	byName     = make(map[string]*Dissector)
+	byPort     = make(map[uint32][]*Dissector)
+)
+
+// Register adds a dissector. Dissectors are tried in Priority order, so
+// precedence does not depend on which init runs first. Register must be
+// called before capture starts, typically from init.
+func Register(d Dissector) {
+	if d.Name == "" {
+		panic("packet: dissector without a name")
+	}
+	if _, dup := byName[strings.ToUpper(d.Name)]; dup {
+		panic("packet: dissector " + d.Name + " registered twice")
+	}
+
+	if d.Priority == 0 {
+		d.Priority = PriorityDefault
+	}
+	reg := &d
+	dissectors = insertByPriority(dissectors, reg)
+	byName[strings.ToUpper(d.Name)] = reg
+	for _, port := range d.Ports {
+		for _, transport := range []uint8{types.IPProtoTCP, types.IPProtoUDP} {
+			if d.Transport == 0 || d.Transport == transport {
+				key := portKey(transport, port)
+				byPort[key] = insertByPriority(byPort[key], reg)
+			}
+		}
+	}
+	if d.Tab != "" {
+		types.ProtocolFilters = append(types.ProtocolFilters, types.FilterChoice{
+			Label:  d.Name,
+			Desc:   d.Tab,
+			Protos: []string{d.Name},
+		})
+	}
+}
+
+func insertByPriority(ds []*Dissector, d *Dissector) []*Dissector {
+	i := sort.Search(len(ds), func(i int) bool { return ds[i].Priority > d.Priority })
+	return slices.Insert(ds, i, d)
+}
+
+func Lookup(name string) *Dissector {
+	return byName[strings.ToUpper(name)]
+}
+
+func Dissectors() []*Dissector {
+	return dissectors
+}
+
+func portKey(transport uint8, port uint16) uint32 {
+	return uint32(transport)<<16 | uint32(port)
+}
+
+func dissect(c *Context) (*Dissector, string) {
+	for _, d := range dissectors {
+		if d.Match == nil || (d.Transport != 0 && d.Transport != c.Transport) {
+			continue
+		}
+		if d.Match(c) {
+			return d, detailOf(d, c)
+		}
+	}
+
+	// Prefer the well-known side: the lower port is usually the server.
+	first, second := c.SrcPort, c.DstPort
+	if second < first {
+		first, second = second, first
+	}
+	for _, port := range []uint16{first, second} {
+		if ds := byPort[portKey(c.Transport, port)]; len(ds) > 0 {
+			return ds[0], detailOf(ds[0], c)
+		}
+	}
+	return nil, ""
+}
+
+func detailOf(d *Dissector, c *Context) string {
+	if d.Detail == nil {
+		return ""
+	}
+	return d.Detail(c)
+}
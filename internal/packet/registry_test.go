@@ -0,0 +1,49 @@
+package packet_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/gopacket/layers"
+
+	"github.com/fe-dudu/netmon/internal/packet"
+)
+
+// TestDissectorOrder pins precedence so that renaming or adding a file in
+// this package cannot change which dissector claims a payload.
+func TestDissectorOrder(t *testing.T) {
+	want := []string{
+		"DNS", "TLS", "SSH", "QUIC", "SSDP", "HTTP", "PGSQL", "MySQL", "Redis", "AMQP", "MQTT",
+		"HTTP2", "Kafka", "DHCP", "Syslog",
+		"DHCPv6", "mDNS", "LLMNR", "NBNS", "NTP", "SNMP", "WebSocket",
+	}
+	var got []string
+	for _, d := range packet.Dissectors() {
+		got = append(got, d.Name)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("dissector order:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestDissectorPrecedence(t *testing.T) {
+	tests := []struct {
+		name    string
+		port    uint16
+		payload string
+		want    string
+	}{
+		// HTTP/2 frames on the Kafka port are claimed by content first.
+		{"h2 preface on kafka port", 9092, "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n\x00\x00\x00\x04\x00\x00\x00\x00\x00", "HTTP2"},
+		{"http on postgres port", 5432, "GET / HTTP/1.1\r\nHost: db\r\n\r\n", "HTTP"},
+		{"redis command on http port", 80, "*1\r\n$4\r\nPING\r\n", "Redis"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkt := parseFrame(tcpFrame(t, 50000, layers.TCPPort(tt.port), []byte(tt.payload)))
+			if pkt.Proto != tt.want {
+				t.Errorf("Proto = %q (%s), want %s", pkt.Proto, pkt.Detail, tt.want)
+			}
+		})
+	}
+}
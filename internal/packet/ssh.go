@@ -0,0 +1,180 @@
+package packet
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fe-dudu/netmon/internal/types"
+)
+
+const sshMsgKexInit = 20
+
+// sshDetail describes an identification string and/or KEXINIT. The sender is
+// taken to be the server when it uses the lower port.
+func (c *Context) sshDetail() string {
+	info := &types.SSHInfo{}
+	rest := c.Payload
+	var parts []string
+
+	if bytes.HasPrefix(rest, []byte("SSH-")) {
+		line := rest
+		if i := bytes.IndexByte(line, '\n'); i >= 0 {
+			line, rest = line[:i], rest[i+1:]
+		} else {
+			rest = nil
+		}
+		parseSSHBanner(info, strings.TrimRight(string(line), "\r"))
+		banner := info.Software
+		if info.Comments != "" {
+			banner += " " + info.Comments
+		}
+		if len(banner) > 80 {
+			banner = banner[:77] + "..."
+		}
+		parts = append(parts, fmt.Sprintf("%s proto=%s", printable([]byte(banner)), info.Version))
+		if SSHOutdated(info.Version, info.Software) {
+			parts = append(parts, "outdated")
+		}
+	}
+
+	if kex, ok := parseKexInit(rest); ok {
+		info.KexInit = kex
+		hassh := "hassh=" + kex.HASSH
+		if c.SrcPort < c.DstPort {
+			hassh = "hasshServer=" + kex.HASSHServer
+		}
+		parts = append(parts, "KEXINIT "+hassh,
+			"kex="+firstOf(kex.Kex),
+			"hostkey="+firstOf(kex.HostKey),
+			"enc="+firstOf(kex.CiphersC2S),
+			"mac="+firstOf(kex.MACsC2S))
+	}
+
+	if len(parts) == 0 {
+		return c.p.lenDetail(len(c.Payload))
+	}
+	c.Meta = info
+	return strings.Join(parts, " ")
+}
+
+// parseSSHBanner splits "SSH-protoversion-softwareversion SP comments"
+// (RFC 4253 section 4.2).
+func parseSSHBanner(info *types.SSHInfo, line string) {
+	if len(line) > 255 {
+		line = line[:255]
+	}
+	info.Banner = printable([]byte(line))
+	rest := strings.TrimPrefix(info.Banner, "SSH-")
+	version, software, _ := strings.Cut(rest, "-")
+	software, comments, _ := strings.Cut(software, " ")
+	info.Version, info.Software, info.Comments = version, software, comments
+}
+
+// SSHOutdated reports protocol 1 peers and OpenSSH releases before 8.0.
+func SSHOutdated(version, software string) bool {
+	if strings.HasPrefix(version, "1.") && version != "1.99" {
+		return true
+	}
+	if v, ok := strings.CutPrefix(software, "OpenSSH_"); ok {
+		major, _, _ := strings.Cut(v, ".")
+		if n, err := strconv.Atoi(major); err == nil && n < 8 {
+			return true
+		}
+	}
+	return false
+}
+
+func isKexInit(b []byte) bool {
+	_, ok := parseKexInit(b)
+	return ok
+}
+
+// parseKexInit decodes an unencrypted binary packet carrying SSH_MSG_KEXINIT.
+func parseKexInit(b []byte) (*types.SSHKexInit, bool) {
+	if len(b) < 6+16 || b[5] != sshMsgKexInit {
+		return nil, false
+	}
+	pktLen := int(binary.BigEndian.Uint32(b[0:4]))
+	padLen := int(b[4])
+	if pktLen > 35000 || padLen < 4 || pktLen < padLen+1+16+40 {
+		return nil, false
+	}
+	body := b[6+16:]
+	if end := pktLen - padLen - 1 - 16; end < len(body) {
+		body = body[:end]
+	}
+
+	var lists [8][]string
+	for i := range lists {
+		if len(body) < 4 {
+			return nil, false
+		}
+		n := int(binary.BigEndian.Uint32(body[0:4]))
+		if n > len(body)-4 {
+			return nil, false
+		}
+		name := body[4 : 4+n]
+		for _, ch := range name {
+			if ch <= ' ' || ch > '~' {
+				return nil, false
+			}
+		}
+		if n > 0 {
+			lists[i] = strings.Split(string(name), ",")
+		}
+		body = body[4+n:]
+	}
+	if len(lists[0]) == 0 || len(lists[1]) == 0 {
+		return nil, false
+	}
+
+	kex := &types.SSHKexInit{
+		Kex:        lists[0],
+		HostKey:    lists[1],
+		CiphersC2S: lists[2],
+		CiphersS2C: lists[3],
+		MACsC2S:    lists[4],
+		MACsS2C:    lists[5],
+		CompC2S:    lists[6],
+		CompS2C:    lists[7],
+	}
+	kex.HASSH = hassh(kex.Kex, kex.CiphersC2S, kex.MACsC2S, kex.CompC2S)
+	kex.HASSHServer = hassh(kex.Kex, kex.CiphersS2C, kex.MACsS2C, kex.CompS2C)
+	return kex, true
+}
+
+func hassh(lists ...[]string) string {
+	joined := make([]string, len(lists))
+	for i, l := range lists {
+		joined[i] = strings.Join(l, ",")
+	}
+	sum := md5.Sum([]byte(strings.Join(joined, ";")))
+	return hex.EncodeToString(sum[:])
+}
+
+func firstOf(list []string) string {
+	switch len(list) {
+	case 0:
+		return "-"
+	case 1:
+		return list[0]
+	}
+	return fmt.Sprintf("%s+%d", list[0], len(list)-1)
+}
+
+// SSHNegotiated returns the first client algorithm the server also offers.
+func SSHNegotiated(client, server []string) string {
+	for _, alg := range client {
+		for _, s := range server {
+			if alg == s {
+				return alg
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,188 @@
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/fe-dudu/netmon/internal/types"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const maxTunnelDepth = 3
+
+// ipv4 and ipv6 stop the decoding layer parser at IP-in-IP, which would
+// otherwise decode the inner header over the outer one.
+type ipv4 struct{ layers.IPv4 }
+
+func (ip *ipv4) NextLayerType() gopacket.LayerType {
+	if isIPinIP(ip.Protocol) {
+		return gopacket.LayerTypeZero
+	}
+	return ip.IPv4.NextLayerType()
+}
+
+type ipv6 struct{ layers.IPv6 }
+
+func (ip *ipv6) NextLayerType() gopacket.LayerType {
+	next := ip.NextHeader
+	if ip.HopByHop != nil {
+		next = ip.HopByHop.NextHeader
+	}
+	if isIPinIP(next) {
+		return gopacket.LayerTypeZero
+	}
+	return ip.IPv6.NextLayerType()
+}
+
+func isIPinIP(proto layers.IPProtocol) bool {
+	return proto == layers.IPProtocolIPv4 || proto == layers.IPProtocolIPv6
+}
+
+func isVLANType(t uint16) bool {
+	return t == uint16(layers.EthernetTypeDot1Q) || t == uint16(layers.EthernetTypeQinQ)
+}
+
+type tunnel struct {
+	name    string
+	detail  string
+	first   gopacket.LayerType
+	payload []byte
+}
+
+// tunnel recognises VXLAN, Geneve, GRE and IP-in-IP encapsulation and
+// returns the inner frame.
+func (p *Parser) tunnel() (tunnel, bool) {
+	if p.hasUDP {
+		payload := p.udp.Payload
+		switch {
+		case p.udpPort(4789) || p.udpPort(8472):
+			if len(payload) < 8 || payload[0]&0x08 == 0 {
+				break
+			}
+			return tunnel{
+				name:    "VXLAN",
+				detail:  fmt.Sprintf("vni=%d", binary.BigEndian.Uint32(payload[4:8])>>8),
+				first:   layers.LayerTypeEthernet,
+				payload: payload[8:],
+			}, true
+		case p.udpPort(6081):
+			if len(payload) < 8 || payload[0]>>6 != 0 {
+				break
+			}
+			hdrLen := 8 + int(payload[0]&0x3f)*4
+			first := innerLayer(binary.BigEndian.Uint16(payload[2:4]))
+			if first == gopacket.LayerTypeZero || len(payload) < hdrLen {
+				break
+			}
+			return tunnel{
+				name:    "GENEVE",
+				detail:  fmt.Sprintf("vni=%d", binary.BigEndian.Uint32(payload[4:8])>>8),
+				first:   first,
+				payload: payload[hdrLen:],
+			}, true
+		}
+		return tunnel{}, false
+	}
+
+	outer := "IPv4"
+	if !p.hasIPv4 {
+		outer = "IPv6"
+	}
+	switch p.ipProto {
+	case layers.IPProtocolGRE:
+		return greTunnel(p.ipPayload)
+	case layers.IPProtocolIPv4:
+		return tunnel{name: "IPIP", detail: "IPv4-in-" + outer, first: layers.LayerTypeIPv4, payload: p.ipPayload}, true
+	case layers.IPProtocolIPv6:
+		return tunnel{name: "IPIP", detail: "IPv6-in-" + outer, first: layers.LayerTypeIPv6, payload: p.ipPayload}, true
+	}
+	return tunnel{}, false
+}
+
+func (p *Parser) udpPort(port layers.UDPPort) bool {
+	return p.udp.DstPort == port || p.udp.SrcPort == port
+}
+
+// greTunnel decodes a version 0 GRE header (RFC 2784/2890), including
+// transparent Ethernet bridging and ERSPAN type II.
+func greTunnel(b []byte) (tunnel, bool) {
+	if len(b) < 4 || b[1]&0x07 != 0 {
+		return tunnel{}, false
+	}
+	flags := b[0]
+	hdrLen := 4
+	if flags&0x80 != 0 {
+		hdrLen += 4
+	}
+	keyAt := hdrLen
+	if flags&0x20 != 0 {
+		hdrLen += 4
+	}
+	if flags&0x10 != 0 {
+		hdrLen += 4
+	}
+	if len(b) < hdrLen {
+		return tunnel{}, false
+	}
+
+	t := tunnel{name: "GRE"}
+	if flags&0x20 != 0 {
+		t.detail = fmt.Sprintf("key=%d", binary.BigEndian.Uint32(b[keyAt:keyAt+4]))
+	}
+	proto := binary.BigEndian.Uint16(b[2:4])
+	t.payload = b[hdrLen:]
+	if proto == uint16(layers.EthernetTypeERSPAN) {
+		if len(t.payload) < 8 {
+			return tunnel{}, false
+		}
+		t.name = "ERSPAN"
+		t.detail = fmt.Sprintf("session=%d", binary.BigEndian.Uint16(t.payload[2:4])&0x03ff)
+		t.payload = t.payload[8:]
+		proto = uint16(layers.EthernetTypeTransparentEthernetBridging)
+	}
+	t.first = innerLayer(proto)
+	if t.first == gopacket.LayerTypeZero {
+		return tunnel{}, false
+	}
+	return t, true
+}
+
+func innerLayer(etherType uint16) gopacket.LayerType {
+	switch layers.EthernetType(etherType) {
+	case layers.EthernetTypeTransparentEthernetBridging:
+		return layers.LayerTypeEthernet
+	case layers.EthernetTypeIPv4:
+		return layers.LayerTypeIPv4
+	case layers.EthernetTypeIPv6:
+		return layers.LayerTypeIPv6
+	}
+	return gopacket.LayerTypeZero
+}
+
+// decapsulate parses the inner packet of a tunnel and describes it on the
+// outer one.
+func (p *Parser) decapsulate(info *types.PacketInfo, t tunnel, depth int) {
+	outer := t.name + " " + info.Src() + " > " + info.Dst()
+	if t.detail != "" {
+		outer += " " + t.detail
+	}
+
+	inner := p.parse(t.payload, t.first, info.Timestamp, depth+1)
+	inner.Tunnel = outer
+	if info.Tunnel != "" {
+		inner.Tunnel = info.Tunnel + ", " + outer
+	}
+
+	info.Proto = t.name
+	info.Detail = t.detail
+	if info.Detail != "" {
+		info.Detail += " "
+	}
+	info.Detail += inner.Proto + " " + inner.Src() + " > " + inner.Dst()
+	if inner.Detail != "" {
+		info.Detail += " " + inner.Detail
+	}
+	info.Meta = nil
+	info.Inner = &inner
+}
@@ -0,0 +1,242 @@
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/fe-dudu/netmon/internal/types"
+)
+
+const (
+	maxWebSocketFrames = 32
+	maxWebSocketLength = 1 << 30
+	wsPreviewLen       = 40
+)
+
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+var wsOpcodeNames = map[uint8]string{
+	wsContinuation: "cont", wsText: "text", wsBinary: "binary",
+	wsClose: "close", wsPing: "ping", wsPong: "pong",
+}
+
+var wsCloseNames = map[uint16]string{
+	1000: "normal", 1001: "going away", 1002: "protocol error", 1003: "unsupported data",
+	1005: "no status", 1006: "abnormal", 1007: "invalid payload", 1008: "policy violation",
+	1009: "message too big", 1010: "mandatory extension", 1011: "internal error",
+	1012: "service restart", 1013: "try again later", 1015: "TLS handshake",
+}
+
+func init() {
+	// WebSocket frames have no signature of their own. The dissector only
+	// names and colours packets that the tracker attributes to an upgraded
+	// connection.
+	Register(Dissector{
+		Name:      "WebSocket",
+		Priority:  260,
+		Transport: types.IPProtoTCP,
+		Color:     "dodgerblue",
+	})
+}
+
+// webSocketUpgrade returns the handshake info if an HTTP request or 101
+// response carries "Upgrade: websocket". Frames that follow a 101 response
+// in the same segment are decoded too.
+func webSocketUpgrade(b []byte) *types.WebSocketInfo {
+	head, body, complete := bytes.Cut(b, []byte("\r\n\r\n"))
+	line, rest, _ := bytes.Cut(head, []byte("\n"))
+	upgrade := false
+	for len(rest) > 0 && !upgrade {
+		var h []byte
+		h, rest, _ = bytes.Cut(rest, []byte("\n"))
+		name, value, ok := bytes.Cut(h, []byte(":"))
+		if ok && bytes.EqualFold(bytes.TrimSpace(name), []byte("upgrade")) {
+			for _, token := range bytes.Split(value, []byte(",")) {
+				if bytes.EqualFold(bytes.TrimSpace(token), []byte("websocket")) {
+					upgrade = true
+				}
+			}
+		}
+	}
+	if !upgrade {
+		return nil
+	}
+
+	info := &types.WebSocketInfo{}
+	if bytes.HasPrefix(line, []byte("HTTP/1.")) {
+		fields := bytes.Fields(line)
+		if len(fields) < 2 || !bytes.Equal(fields[1], []byte("101")) {
+			return nil
+		}
+		info.Accept = true
+		if complete && len(body) > 0 {
+			if frames, ok := ParseWebSocket(body); ok {
+				info.Frames, info.Missing = frames.Frames, frames.Missing
+			}
+		}
+		return info
+	}
+	info.Upgrade = true
+	if fields := bytes.Fields(line); len(fields) >= 2 {
+		path, _, _ := bytes.Cut(fields[1], []byte("?"))
+		if len(path) > 60 {
+			path = path[:60]
+		}
+		info.Path = printable(path)
+	}
+	return info
+}
+
+// ParseWebSocket decodes a run of frames that starts at the beginning of b.
+// Only the last frame may continue into later segments.
+func ParseWebSocket(b []byte) (*types.WebSocketInfo, bool) {
+	info := &types.WebSocketInfo{}
+	n := 0
+	for len(b) > 0 && n < maxWebSocketFrames {
+		if len(b) < 2 {
+			return nil, false
+		}
+		opcode := b[0] & 0x0f
+		if _, ok := wsOpcodeNames[opcode]; !ok || b[0]&0x30 != 0 {
+			return nil, false
+		}
+		control := opcode >= wsClose
+		compressed := b[0]&0x40 != 0
+		if control && (b[0]&0x80 == 0 || compressed) {
+			return nil, false
+		}
+
+		hdr := 2
+		length := int(b[1] & 0x7f)
+		switch length {
+		case 126:
+			if len(b) < 4 {
+				return nil, false
+			}
+			length = int(binary.BigEndian.Uint16(b[2:4]))
+			hdr = 4
+			if length < 126 {
+				return nil, false
+			}
+		case 127:
+			if len(b) < 10 {
+				return nil, false
+			}
+			v := binary.BigEndian.Uint64(b[2:10])
+			if v < 1<<16 || v > maxWebSocketLength {
+				return nil, false
+			}
+			length = int(v)
+			hdr = 10
+		}
+		if control && length > 125 {
+			return nil, false
+		}
+		masked := b[1]&0x80 != 0
+		var mask []byte
+		if masked {
+			if len(b) < hdr+4 {
+				return nil, false
+			}
+			mask = b[hdr : hdr+4]
+			hdr += 4
+		}
+
+		payload := b[hdr:]
+		missing := 0
+		if length <= len(payload) {
+			payload = payload[:length]
+		} else {
+			missing = length - len(payload)
+		}
+		info.Frames = append(info.Frames, webSocketFrame(b[0], opcode, length, masked, compressed, payload, mask))
+		info.Missing = missing
+		n++
+		if missing > 0 {
+			return info, true
+		}
+		b = b[hdr+length:]
+	}
+	return info, n > 0
+}
+
+func webSocketFrame(b0, opcode uint8, length int, masked, compressed bool, payload, mask []byte) types.WebSocketFrame {
+	f := types.WebSocketFrame{
+		Opcode: opcode, Fin: b0&0x80 != 0, Masked: masked,
+		Compressed: compressed, Length: length,
+	}
+	if compressed || (opcode != wsText && opcode != wsClose) {
+		return f
+	}
+	n := min(len(payload), wsPreviewLen+2)
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = payload[i]
+		if masked {
+			data[i] ^= mask[i%4]
+		}
+	}
+	if opcode == wsClose {
+		if len(data) < 2 {
+			return f
+		}
+		f.CloseCode = binary.BigEndian.Uint16(data[:2])
+		data = data[2:]
+		length -= 2
+	}
+	if len(data) > wsPreviewLen {
+		data = data[:wsPreviewLen]
+	}
+	f.Preview = printable(data)
+	if length > len(data) {
+		f.Preview += "..."
+	}
+	return f
+}
+
+// DescribeWebSocket summarises the frames of a segment, e.g.
+// `text len=5 masked "hello"; close 1000 normal`.
+func DescribeWebSocket(info *types.WebSocketInfo) string {
+	parts := make([]string, 0, len(info.Frames))
+	for _, f := range info.Frames {
+		var sb strings.Builder
+		sb.WriteString(wsOpcodeNames[f.Opcode])
+		if f.Opcode == wsClose {
+			if f.CloseCode != 0 {
+				fmt.Fprintf(&sb, " %d", f.CloseCode)
+				if name := WebSocketCloseName(f.CloseCode); name != "" {
+					sb.WriteString(" " + name)
+				}
+			}
+		} else {
+			fmt.Fprintf(&sb, " len=%d", f.Length)
+		}
+		if f.Masked {
+			sb.WriteString(" masked")
+		}
+		if f.Compressed {
+			sb.WriteString(" deflate")
+		}
+		if !f.Fin {
+			sb.WriteString(" more")
+		}
+		if f.Preview != "" {
+			sb.WriteString(` "` + f.Preview + `"`)
+		}
+		parts = append(parts, sb.String())
+	}
+	return joinDetails(parts)
+}
+
+func WebSocketCloseName(code uint16) string {
+	return wsCloseNames[code]
+}
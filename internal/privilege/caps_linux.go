@@ -0,0 +1,65 @@
+package privilege
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+var captureCapabilities = []struct {
+	bit  uint
+	name string
+}{
+	{unix.CAP_NET_RAW, "CAP_NET_RAW"},
+}
+
+func capget() ([2]unix.CapUserData, error) {
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		return data, fmt.Errorf("capget: %w", err)
+	}
+	return data, nil
+}
+
+func checkCaptureCapabilities() error {
+	data, err := capget()
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, c := range captureCapabilities {
+		if data[0].Effective&(1<<c.bit) == 0 {
+			missing = append(missing, c.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing %s.\nPlease run with sudo or as root, or grant the capabilities with:\n  sudo setcap cap_net_raw=eip $(command -v netmon)", strings.Join(missing, " and "))
+	}
+	return nil
+}
+
+// dropCapabilities clears the permitted, effective and inheritable sets, so
+// open capture handles keep working but no new ones can be opened, and stops
+// later execs from gaining privileges again.
+func dropCapabilities() error {
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("prctl(PR_SET_NO_NEW_PRIVS): %w", err)
+	}
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capset(&hdr, &data[0]); err != nil {
+		return fmt.Errorf("capset: %w", err)
+	}
+
+	data, err := capget()
+	if err != nil {
+		return err
+	}
+	if data[0].Permitted|data[1].Permitted != 0 {
+		return fmt.Errorf("capabilities still permitted after capset")
+	}
+	return nil
+}
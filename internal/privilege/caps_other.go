@@ -0,0 +1,13 @@
+//go:build unix && !linux
+
+package privilege
+
+import "fmt"
+
+func checkCaptureCapabilities() error {
+	return fmt.Errorf("root privileges are required.\nPlease run with sudo or as root")
+}
+
+func dropCapabilities() error {
+	return nil
+}
@@ -0,0 +1,56 @@
+//go:build unix
+
+package privilege
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+func CanCapture() error {
+	if os.Geteuid() == 0 {
+		return nil
+	}
+	return checkCaptureCapabilities()
+}
+
+func Drop(username string) error {
+	u, err := user.Lookup(username)
+	if err != nil {
+		return err
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return fmt.Errorf("user %s: invalid uid %q", username, u.Uid)
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return fmt.Errorf("user %s: invalid gid %q", username, u.Gid)
+	}
+
+	if os.Geteuid() != 0 {
+		if os.Geteuid() != uid {
+			return fmt.Errorf("switching to user %s requires starting as root", username)
+		}
+		// Started through file capabilities: stay this user and give up
+		// the capabilities instead.
+		return dropCapabilities()
+	}
+
+	if err := syscall.Setgroups([]int{gid}); err != nil {
+		return fmt.Errorf("setgroups: %w", err)
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return fmt.Errorf("setgid: %w", err)
+	}
+	if err := syscall.Setuid(uid); err != nil {
+		return fmt.Errorf("setuid: %w", err)
+	}
+	if os.Geteuid() == 0 {
+		return fmt.Errorf("still running as root after setuid")
+	}
+	return nil
+}
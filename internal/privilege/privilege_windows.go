@@ -0,0 +1,11 @@
+package privilege
+
+import "fmt"
+
+func CanCapture() error {
+	return nil
+}
+
+func Drop(username string) error {
+	return fmt.Errorf("dropping privileges is not supported on windows")
+}
@@ -1,58 +1,516 @@
 package types
 
 import (
+	"net/netip"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcap"
 	"github.com/rivo/tview"
+	"golang.org/x/net/http2/hpack"
 )
 
+const DefaultCaptureBPF = ""
+
 type FilterChoice struct {
-	Label string
-	BPF   string
-	Desc  string
+	Label  string
+	Desc   string
+	Protos []string
+	Ports  []int
+	Hosts  []string
+}
+
+type Frame struct {
+	Iface    string
+	LinkType layers.LinkType
+	Data     []byte
+	CI       gopacket.CaptureInfo
+}
+
+type MAC [6]byte
+
+func (m MAC) IsZero() bool {
+	return m == MAC{}
 }
 
+func (m MAC) String() string {
+	const hexDigits = "0123456789abcdef"
+	buf := make([]byte, 0, 17)
+	for i, b := range m {
+		if i > 0 {
+			buf = append(buf, ':')
+		}
+		buf = append(buf, hexDigits[b>>4], hexDigits[b&0xf])
+	}
+	return string(buf)
+}
+
+const (
+	TCPFlagFIN uint8 = 1 << iota
+	TCPFlagSYN
+	TCPFlagRST
+	TCPFlagPSH
+	TCPFlagACK
+	TCPFlagURG
+	TCPFlagECE
+	TCPFlagCWR
+)
+
+const (
+	IPProtoICMP   uint8 = 1
+	IPProtoTCP    uint8 = 6
+	IPProtoUDP    uint8 = 17
+	IPProtoICMPv6 uint8 = 58
+)
+
 type PacketInfo struct {
-	Timestamp time.Time
-	Iface     string
+	Timestamp  time.Time
+	Iface      string
+	Proto      string
+	SrcAddr    netip.Addr
+	DstAddr    netip.Addr
+	SrcPort    uint16
+	DstPort    uint16
+	HasPorts   bool
+	L4Proto    uint8
+	IPVersion  uint8
+	TTL        uint8
+	Length     int
+	PayloadLen int
+	VLAN       uint16
+	OuterVLAN  uint16
+	EtherType  uint16
+	SrcMAC     MAC
+	DstMAC     MAC
+	TCPFlags   uint8
+	Detail     string
+	// Meta carries protocol-specific data from dissectors to trackers.
+	Meta any
+	// Inner is the decapsulated packet of tunnelled traffic, and Tunnel
+	// describes the outer encapsulation on it.
+	Inner  *PacketInfo
+	Tunnel string
+}
+
+type DHCPInfo struct {
+	V6        bool
+	MsgType   string
+	ClientMAC MAC
+	ClientID  string
+	Addr      netip.Addr
+	Hostname  string
+	LeaseTime time.Duration
+	Server    netip.Addr
+	ServerID  string
+}
+
+// NTPInfo carries the timestamps of an NTP server reply so the tracker can
+// work out the clock offset against the capture time.
+type NTPInfo struct {
+	Mode     uint8
+	Origin   time.Time
+	Receive  time.Time
+	Transmit time.Time
+}
+
+// MessagingInfo lists the topics a MQTT, AMQP or Kafka segment publishes
+// to or consumes from.
+type MessagingInfo struct {
+	Topics []TopicEvent
+}
+
+// TopicEvent is one publish (or produce) when Publish is set, otherwise a
+// subscribe, consume, delivery or fetch. Bytes is the message size when
+// known.
+type TopicEvent struct {
+	Name    string
+	Publish bool
+	Bytes   int
+}
+
+// DiscoveryInfo is decoded from mDNS, SSDP, LLMNR and NBNS. Announce is set
+// when the sender describes itself rather than asking about others, and Bye
+// when it withdraws the announcement.
+type DiscoveryInfo struct {
+	Announce bool
+	Bye      bool
+	Names    []string
+	Services []string
+	Model    string
+	Server   string
+	Location string
+}
+
+type ICMPInfo struct {
+	ID    uint16
+	Seq   uint16
+	Reply bool
+}
+
+// SSHInfo is decoded from an SSH identification string and/or KEXINIT.
+type SSHInfo struct {
+	Banner   string
+	Version  string
+	Software string
+	Comments string
+	KexInit  *SSHKexInit
+}
+
+// SSHKexInit holds the algorithm name-lists of an SSH_MSG_KEXINIT. HASSH is
+// the fingerprint of the client-to-server lists and HASSHServer of the
+// server-to-client ones; which applies depends on the sender's role.
+type SSHKexInit struct {
+	Kex         []string
+	HostKey     []string
+	CiphersC2S  []string
+	CiphersS2C  []string
+	MACsC2S     []string
+	MACsS2C     []string
+	CompC2S     []string
+	CompS2C     []string
+	HASSH       string
+	HASSHServer string
+}
+
+type SSHSession struct {
+	ClientBanner string
+	ServerBanner string
+	ClientKex    *SSHKexInit
+	ServerKex    *SSHKexInit
+}
+
+// HTTP2Info lists the frames of an HTTP/2 segment. Header blocks are copied
+// so they can be decoded in capture order by the tracker.
+type HTTP2Info struct {
+	Preface bool
+	Frames  []HTTP2Frame
+}
+
+type HTTP2Frame struct {
+	Type     uint8
+	Flags    uint8
+	StreamID uint32
+	Length   int
+	Block    []byte
+	// Missing counts the bytes of a frame cut at the end of the segment.
+	// Block then holds the raw frame body seen so far.
+	Missing   int
+	Promised  uint32
+	ErrorCode uint32
+}
+
+// HTTP2Session holds the HPACK state of an h2c connection, indexed by
+// direction (0 from the client, 1 from the server). Head is the HEADERS or
+// PUSH_PROMISE frame that opened the pending block, and Cut a header frame
+// still waiting for the rest of its body.
+type HTTP2Session struct {
+	Decoders [2]*hpack.Decoder
+	Pending  [2][]byte
+	Head     [2]HTTP2Frame
+	Cut      [2]HTTP2Frame
+	Streams  map[uint32]*HTTP2Stream
+}
+
+type HTTP2Stream struct {
+	Method    string
+	Path      string
+	Authority string
+	GRPC      bool
+}
+
+// TCPSegment carries the payload of a TCP segment no dissector claimed, so
+// the tracker can continue a message cut at an earlier segment. It aliases
+// the frame and is dropped once tracked.
+type TCPSegment struct {
+	Payload []byte
+}
+
+// WebSocketInfo is decoded from an HTTP upgrade handshake or from a run of
+// WebSocket frames. Frames are only trusted once the flow has upgraded.
+// Missing counts the bytes of the last frame carried by later segments.
+type WebSocketInfo struct {
+	Upgrade bool
+	Accept  bool
+	Path    string
+	Frames  []WebSocketFrame
+	Missing int
+}
+
+type WebSocketFrame struct {
+	Opcode     uint8
+	Fin        bool
+	Masked     bool
+	Compressed bool
+	Length     int
+	CloseCode  uint16
+	Preview    string
+}
+
+// WebSocketSession follows an upgraded connection. Remaining is indexed by
+// direction (0 from the client, 1 from the server).
+type WebSocketSession struct {
+	Path      string
+	Open      bool
+	Frames    int
+	CloseCode uint16
+	Remaining [2]int
+}
+
+// FlowKey identifies a bidirectional flow; A is the lower endpoint.
+type FlowKey struct {
+	L4Proto uint8
+	A, B    netip.AddrPort
+}
+
+type Flow struct {
+	L4Proto     uint8
+	Proto       string
+	Client      netip.AddrPort
+	Server      netip.AddrPort
+	Packets     int
+	ClientBytes int
+	ServerBytes int
+	First       time.Time
+	Last        time.Time
+	State       string
+	SSH         *SSHSession
+	HTTP2       *HTTP2Session
+	WebSocket   *WebSocketSession
+}
+
+type EchoKey struct {
+	Src, Dst netip.Addr
+	ID, Seq  uint16
+}
+
+type Lease struct {
+	ClientID  string
+	ClientMAC MAC
+	Addr      netip.Addr
+	Hostname  string
+	Server    string
+	State     string
+	LeaseTime time.Duration
+	Expires   time.Time
+	Updated   time.Time
+	V6        bool
+}
+
+// Neighbor is a host that announced itself with a discovery protocol.
+type Neighbor struct {
+	Addr      netip.Addr
+	MAC       MAC
+	Names     []string
+	Services  []string
+	Protocols []string
+	Model     string
+	Server    string
+	Location  string
+	First     time.Time
+	Last      time.Time
+	Gone      bool
+}
+
+type TopicKey struct {
+	Proto string
+	Name  string
+}
+
+// Topic aggregates the messaging traffic seen for one topic, exchange
+// routing key or queue.
+type Topic struct {
 	Proto     string
-	Src       string
-	Dst       string
-	Detail    string
+	Name      string
+	Published int
+	Consumed  int
+	Bytes     int
+	Clients   []netip.Addr
+	First     time.Time
+	Last      time.Time
+}
+
+func (p PacketInfo) Src() string {
+	if !p.SrcAddr.IsValid() && !p.SrcMAC.IsZero() {
+		return p.SrcMAC.String()
+	}
+	return formatEndpoint(p.SrcAddr, p.SrcPort, p.HasPorts)
+}
+
+func (p PacketInfo) Dst() string {
+	if !p.DstAddr.IsValid() && !p.DstMAC.IsZero() {
+		return p.DstMAC.String()
+	}
+	return formatEndpoint(p.DstAddr, p.DstPort, p.HasPorts)
+}
+
+func formatEndpoint(addr netip.Addr, port uint16, hasPort bool) string {
+	host := "unknown"
+	if addr.IsValid() {
+		host = addr.String()
+	}
+	if !hasPort {
+		return host
+	}
+	return host + ":" + strconv.Itoa(int(port))
 }
 
 var ProtocolFilters = []FilterChoice{
-	{Label: "ALL", BPF: "ip or ip6", Desc: "All IPv4/IPv6 traffic (L3)"},
-	{Label: "HTTPS", BPF: "tcp port 443", Desc: "HTTPS (HTTP over TLS over TCP 443) (L7, encrypted)"},
-	{Label: "HTTP", BPF: "tcp port 80 or tcp port 8080", Desc: "HTTP over TCP ports 80/8080 (L7)"},
-	{Label: "DNS", BPF: "udp port 53 or tcp port 53", Desc: "DNS queries and responses (L7)"},
-	{Label: "TCP", BPF: "tcp", Desc: "All TCP packets (L4)"},
-	{Label: "UDP", BPF: "udp", Desc: "All UDP packets (L4)"},
-	{Label: "QUIC", BPF: "udp port 443", Desc: "QUIC over UDP port 443 (UDP-based transport)"},
-	{Label: "ICMP", BPF: "icmp or icmp6", Desc: "ICMP/ICMPv6 packets (L3)"},
+	{Label: "ALL", Desc: "All captured traffic"},
+	{Label: "HTTPS", Desc: "TLS over TCP, detected by record header or port 443 (L7, encrypted)"},
+	{Label: "HTTP", Desc: "HTTP/1.x request and status lines, WebSocket, h2c and gRPC on any TCP port (L7)"},
+	{Label: "DNS", Desc: "DNS queries and responses (L7)"},
+	{Label: "TCP", Desc: "All TCP packets (L4)"},
+	{Label: "UDP", Desc: "All UDP packets (L4)"},
+	{Label: "QUIC", Desc: "QUIC long headers on any UDP port, short headers on 443 (UDP-based transport)"},
+	{Label: "ICMP", Desc: "ICMP/ICMPv6 packets (L3)"},
+	{Label: "L2", Desc: "ARP, LLDP, CDP, STP and other non-IP frames (L2)"},
+}
+
+type Sink interface {
+	Write(PacketInfo) error
+	Flush() error
+	Close() error
+}
+
+type InterfaceOptions struct {
+	IncludeLoopback bool
+	IncludeVPN      bool
+}
+
+type Event struct {
+	Timestamp time.Time
+	Iface     string
+	Message   string
+}
+
+type SourceStats struct {
+	Received  uint64
+	Dropped   uint64
+	IfDropped uint64
+}
+
+// PacketReader reads one queue of frames. ReadPacketData returns a copy that
+// the caller owns and unblocks with an error once the source is closed.
+type PacketReader interface {
+	ReadPacketData() ([]byte, gopacket.CaptureInfo, error)
+}
+
+// PacketSource delivers raw frames. It is defined here rather than in
+// network because Capture holds one; network aliases it.
+type PacketSource interface {
+	PacketReader
+	Name() string
+	LinkType() layers.LinkType
+	SetFilter(expr string) error
+	Stats() (SourceStats, error)
+	Close()
+}
+
+// RingSource is a PacketSource backed by several kernel rings, such as an
+// afpacket fanout group. Each ring gets its own reader so they drain in
+// parallel instead of through the source's ReadPacketData.
+type RingSource interface {
+	PacketSource
+	Rings() []PacketReader
+	RingStats() ([]SourceStats, error)
+}
+
+type CaptureOptions struct {
+	Backend string
+	Fanout  int
+	RingMB  int
+}
+
+type Capture struct {
+	Iface  pcap.Interface
+	Source PacketSource
+	StopCh chan struct{}
+	// Offline captures (files and synthetic sources) wait for the parsers
+	// instead of dropping frames when they fall behind.
+	Offline bool
+	// Backlog counts live frames dropped because the parsers were behind,
+	// one counter per reader.
+	Backlog []atomic.Uint64
 }
 
 type App struct {
-	App         *tview.Application
-	PacketView  *tview.TextView
-	FilterView  *tview.TextView
-	ModeView    *tview.TextView
-	SearchInput *tview.InputField
-	MainFlex    *tview.Flex
+	App          *tview.Application
+	PacketView   *tview.TextView
+	FilterView   *tview.TextView
+	ModeView     *tview.TextView
+	SearchInput  *tview.InputField
+	IfaceList    *tview.List
+	BPFInput     *tview.InputField
+	BPFStatus    *tview.TextView
+	EventView    *tview.TextView
+	LeaseView    *tview.TextView
+	FlowView     *tview.TextView
+	NeighborView *tview.TextView
+	TopicView    *tview.TextView
+	MainFlex     *tview.Flex
+	Pages        *tview.Pages
 
 	Packets      []PacketInfo
 	PacketsMutex sync.RWMutex
+	MaxPackets   int
+	Sinks        []Sink
+	ProtoColors  map[string]string
 
 	CurrentFilterIdx int
+	CaptureBPF       string
+	DefaultBPF       string
+	BPFHistory       []string
+	BPFHistoryIdx    int
 	SearchIP         string
 	IsSearchMode     bool
 	IsExpandedMode   bool
+	InnerView        bool
+	ActivePanel      string
+
+	Devices       []pcap.Interface
+	AutoIfaces    map[string]bool
+	AutoSelect    bool
+	Offline       bool
+	CaptureOpts   CaptureOptions
+	IfaceOpts     InterfaceOptions
+	WantedIfaces  map[string]bool
+	CaptureErrors map[string]string
+	Captures      map[string]*Capture
+	CapturesMutex sync.Mutex
+	// Capturing is set once StartPacketCapture has started the readers of
+	// the captures opened so far; later captures start their own.
+	Capturing bool
+	// Unprivileged is set after --user dropped the privileges needed to
+	// open new capture handles.
+	Unprivileged bool
+
+	Events      []Event
+	EventsMutex sync.Mutex
+
+	Leases      map[string]*Lease
+	LeasesMutex sync.Mutex
+
+	Flows      map[FlowKey]*Flow
+	FlowsMutex sync.Mutex
+
+	Neighbors      map[netip.Addr]*Neighbor
+	NeighborsMutex sync.Mutex
+
+	Topics      map[TopicKey]*Topic
+	TopicsMutex sync.Mutex
+
+	// EchoRequests is only touched by the packet store goroutine.
+	EchoRequests map[EchoKey]time.Time
 
-	Ifaces   []pcap.Interface
-	Handles  []*pcap.Handle
+	FrameCh  chan Frame
 	PacketCh chan PacketInfo
-	StopCh   chan struct{}
-	Wg       *sync.WaitGroup
+	Workers  int
+	// RedactQueries hides literals in decoded database queries.
+	RedactQueries bool
+	StopCh        chan struct{}
+	Wg            *sync.WaitGroup
 }
@@ -0,0 +1,89 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/fe-dudu/netmon/internal/types"
+	"github.com/fe-dudu/netmon/internal/utils"
+)
+
+const discoveryPanel = "discovery"
+
+func newDiscoveryView(a *types.App) tview.Primitive {
+	a.NeighborView = tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true).
+		SetWrap(false)
+	a.NeighborView.SetBorder(true).
+		SetBorderColor(tcell.ColorYellow).
+		SetTitle("[yellow]📡 Discovery [gray](D or ESC close)[white]").
+		SetTitleAlign(tview.AlignLeft)
+	return Centered(a.NeighborView, 170, 30)
+}
+
+func OpenDiscoveryPanel(a *types.App) {
+	UpdateDiscoveryView(a)
+	OpenPanel(a, discoveryPanel, a.NeighborView)
+}
+
+func UpdateDiscoveryView(a *types.App) {
+	a.NeighborsMutex.Lock()
+	neighbors := make([]types.Neighbor, 0, len(a.Neighbors))
+	for _, n := range a.Neighbors {
+		neighbor := *n
+		neighbor.Names = append([]string(nil), n.Names...)
+		neighbor.Services = append([]string(nil), n.Services...)
+		neighbor.Protocols = append([]string(nil), n.Protocols...)
+		neighbors = append(neighbors, neighbor)
+	}
+	a.NeighborsMutex.Unlock()
+
+	sort.Slice(neighbors, func(i, j int) bool {
+		return neighbors[i].Last.After(neighbors[j].Last)
+	})
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "[gray]%-26s %-17s %-28s %-18s %-28s %-9s %s[white]\n",
+		"ADDRESS", "MAC", "NAME", "PROTOCOLS", "MODEL / SERVER", "SEEN", "SERVICES")
+	if len(neighbors) == 0 {
+		builder.WriteString("[white]No mDNS, SSDP, LLMNR or NBNS announcements seen yet.[white]\n")
+	}
+	for _, n := range neighbors {
+		mac := "-"
+		if !n.MAC.IsZero() {
+			mac = n.MAC.String()
+		}
+		model := n.Model
+		if model == "" {
+			model = n.Server
+		}
+		color := "white"
+		seen := time.Since(n.Last).Round(time.Second).String() + " ago"
+		if n.Gone {
+			color, seen = "gray", "gone"
+		}
+		fmt.Fprintf(&builder, "[%s]%-26s %-17s %-28s %-18s %-28s %-9s %s[white]\n",
+			color,
+			utils.TruncateString(n.Addr.String(), 26),
+			mac,
+			utils.TruncateString(utils.SanitizeForDisplay(orDash(strings.Join(n.Names, ","))), 28),
+			utils.TruncateString(strings.Join(n.Protocols, ","), 18),
+			utils.TruncateString(utils.SanitizeForDisplay(orDash(model)), 28),
+			seen,
+			utils.SanitizeForDisplay(strings.Join(n.Services, ", ")))
+	}
+	a.NeighborView.SetText(builder.String())
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,164 @@
+package ui
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/fe-dudu/netmon/internal/packet"
+	"github.com/fe-dudu/netmon/internal/types"
+	"github.com/fe-dudu/netmon/internal/utils"
+)
+
+const (
+	flowPanel    = "flows"
+	maxFlowLines = 500
+)
+
+func newFlowView(a *types.App) tview.Primitive {
+	a.FlowView = tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true).
+		SetWrap(false)
+	a.FlowView.SetBorder(true).
+		SetBorderColor(tcell.ColorYellow).
+		SetTitle("[yellow]🔀 Flows [gray](F or ESC close)[white]").
+		SetTitleAlign(tview.AlignLeft)
+	return Centered(a.FlowView, 170, 30)
+}
+
+func OpenFlowPanel(a *types.App) {
+	UpdateFlowView(a)
+	OpenPanel(a, flowPanel, a.FlowView)
+}
+
+func UpdateFlowView(a *types.App) {
+	a.FlowsMutex.Lock()
+	flows := make([]types.Flow, 0, len(a.Flows))
+	for _, f := range a.Flows {
+		flow := *f
+		if f.SSH != nil {
+			ssh := *f.SSH
+			flow.SSH = &ssh
+		}
+		if f.WebSocket != nil {
+			ws := *f.WebSocket
+			flow.WebSocket = &ws
+		}
+		flows = append(flows, flow)
+	}
+	a.FlowsMutex.Unlock()
+
+	sort.Slice(flows, func(i, j int) bool {
+		return flows[i].Last.After(flows[j].Last)
+	})
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "[gray]%-8s %-40s %-40s %7s %-17s %-9s %-7s %s[white]\n",
+		"PROTO", "CLIENT", "SERVER", "PKTS", "BYTES ↑/↓", "DURATION", "STATE", "INFO")
+	if len(flows) == 0 {
+		builder.WriteString("[white]No TCP/UDP flows seen yet.[white]\n")
+	}
+	for i, f := range flows {
+		if i == maxFlowLines {
+			fmt.Fprintf(&builder, "[gray]... %d more[white]\n", len(flows)-maxFlowLines)
+			break
+		}
+		state := f.State
+		if state == "" {
+			state = "-"
+		}
+		fmt.Fprintf(&builder, "[%s]%-8s[white] %-40s %-40s %7d %-17s %-9s %-7s %s\n",
+			GetProtoColor(f.Proto), utils.TruncateString(f.Proto, 8),
+			endpoint(f.Client), endpoint(f.Server), f.Packets,
+			formatBytes(f.ClientBytes)+"/"+formatBytes(f.ServerBytes),
+			f.Last.Sub(f.First).Round(time.Millisecond),
+			state, flowInfo(f))
+	}
+	a.FlowView.SetText(builder.String())
+}
+
+func flowInfo(f types.Flow) string {
+	switch {
+	case f.SSH != nil:
+		return sshFlowInfo(f.SSH)
+	case f.WebSocket != nil:
+		return webSocketFlowInfo(f.WebSocket)
+	}
+	return ""
+}
+
+func webSocketFlowInfo(ws *types.WebSocketSession) string {
+	var parts []string
+	if ws.Path != "" {
+		parts = append(parts, "path="+ws.Path)
+	}
+	if !ws.Open {
+		parts = append(parts, "upgrade pending")
+	} else {
+		parts = append(parts, "frames="+strconv.Itoa(ws.Frames))
+	}
+	if ws.CloseCode != 0 {
+		text := "close=" + strconv.Itoa(int(ws.CloseCode))
+		if name := packet.WebSocketCloseName(ws.CloseCode); name != "" {
+			text += " " + name
+		}
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, " ")
+}
+
+func sshFlowInfo(s *types.SSHSession) string {
+	var parts []string
+	for _, side := range []struct{ role, banner string }{{"client", s.ClientBanner}, {"server", s.ServerBanner}} {
+		if side.banner == "" {
+			continue
+		}
+		software := strings.TrimPrefix(side.banner, "SSH-")
+		version, software, _ := strings.Cut(software, "-")
+		software, _, _ = strings.Cut(software, " ")
+		text := side.role + "=" + utils.SanitizeForDisplay(software)
+		if packet.SSHOutdated(version, software) {
+			text = "[red]" + text + " (outdated)[white]"
+		}
+		parts = append(parts, text)
+	}
+	if s.ClientKex != nil {
+		parts = append(parts, "hassh="+s.ClientKex.HASSH)
+	}
+	if s.ServerKex != nil {
+		parts = append(parts, "hasshServer="+s.ServerKex.HASSHServer)
+	}
+	if s.ClientKex != nil && s.ServerKex != nil {
+		if kex := packet.SSHNegotiated(s.ClientKex.Kex, s.ServerKex.Kex); kex != "" {
+			parts = append(parts, "kex="+kex)
+		}
+		if enc := packet.SSHNegotiated(s.ClientKex.CiphersC2S, s.ServerKex.CiphersC2S); enc != "" {
+			parts = append(parts, "enc="+enc)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// endpoint formats like the packet list, without brackets around IPv6.
+func endpoint(ap netip.AddrPort) string {
+	return ap.Addr().String() + ":" + strconv.Itoa(int(ap.Port()))
+}
+
+func formatBytes(n int) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fG", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fM", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fK", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", n)
+}
@@ -0,0 +1,95 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/fe-dudu/netmon/internal/types"
+	"github.com/fe-dudu/netmon/internal/utils"
+)
+
+const leasePanel = "leases"
+
+func newLeaseView(a *types.App) tview.Primitive {
+	a.LeaseView = tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true).
+		SetWrap(false)
+	a.LeaseView.SetBorder(true).
+		SetBorderColor(tcell.ColorYellow).
+		SetTitle("[yellow]📇 DHCP leases [gray](L or ESC close)[white]").
+		SetTitleAlign(tview.AlignLeft)
+	return Centered(a.LeaseView, 130, 24)
+}
+
+func OpenLeasePanel(a *types.App) {
+	UpdateLeaseView(a)
+	OpenPanel(a, leasePanel, a.LeaseView)
+}
+
+func UpdateLeaseView(a *types.App) {
+	a.LeasesMutex.Lock()
+	leases := make([]types.Lease, 0, len(a.Leases))
+	for _, l := range a.Leases {
+		leases = append(leases, *l)
+	}
+	a.LeasesMutex.Unlock()
+
+	sort.Slice(leases, func(i, j int) bool {
+		return leases[i].Updated.After(leases[j].Updated)
+	})
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "[gray]%-12s %-40s %-20s %-20s %-16s %-10s %s[white]\n",
+		"STATE", "ADDRESS", "CLIENT", "HOSTNAME", "SERVER", "LEASE", "EXPIRES")
+	if len(leases) == 0 {
+		builder.WriteString("[white]No DHCP traffic seen yet.[white]\n")
+	}
+	for _, l := range leases {
+		addr := "-"
+		if l.Addr.IsValid() {
+			addr = l.Addr.String()
+		}
+		client := l.ClientID
+		if !l.ClientMAC.IsZero() {
+			client = l.ClientMAC.String()
+		}
+		lease, expires := "-", "-"
+		if l.LeaseTime > 0 {
+			lease = l.LeaseTime.String()
+		}
+		if !l.Expires.IsZero() {
+			if left := time.Until(l.Expires).Round(time.Second); left > 0 {
+				expires = "in " + left.String()
+			} else {
+				expires = "expired"
+			}
+		}
+		fmt.Fprintf(&builder, "[%s]%-12s[white] %-40s %-20s %-20s %-16s %-10s %s\n",
+			leaseStateColor(l.State), l.State,
+			addr,
+			utils.TruncateString(client, 20),
+			utils.TruncateString(utils.SanitizeForDisplay(l.Hostname), 20),
+			utils.TruncateString(l.Server, 16),
+			lease, expires)
+	}
+	a.LeaseView.SetText(builder.String())
+}
+
+func leaseStateColor(state string) string {
+	switch state {
+	case "bound":
+		return "green"
+	case "offered", "requesting":
+		return "yellow"
+	case "rejected", "declined":
+		return "red"
+	default:
+		return "gray"
+	}
+}
@@ -0,0 +1,80 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"github.com/fe-dudu/netmon/internal/types"
+	"github.com/fe-dudu/netmon/internal/utils"
+)
+
+const topicPanel = "topics"
+
+func newTopicView(a *types.App) tview.Primitive {
+	a.TopicView = tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true).
+		SetWrap(false)
+	a.TopicView.SetBorder(true).
+		SetBorderColor(tcell.ColorYellow).
+		SetTitle("[yellow]📨 Topics [gray](P or ESC close)[white]").
+		SetTitleAlign(tview.AlignLeft)
+	return Centered(a.TopicView, 170, 30)
+}
+
+func OpenTopicPanel(a *types.App) {
+	UpdateTopicView(a)
+	OpenPanel(a, topicPanel, a.TopicView)
+}
+
+func UpdateTopicView(a *types.App) {
+	a.TopicsMutex.Lock()
+	topics := make([]types.Topic, 0, len(a.Topics))
+	for _, t := range a.Topics {
+		topic := *t
+		topic.Clients = append(topic.Clients[:0:0], t.Clients...)
+		topics = append(topics, topic)
+	}
+	a.TopicsMutex.Unlock()
+
+	sort.Slice(topics, func(i, j int) bool {
+		ti, tj := topics[i].Published+topics[i].Consumed, topics[j].Published+topics[j].Consumed
+		if ti != tj {
+			return ti > tj
+		}
+		return topics[i].Bytes > topics[j].Bytes
+	})
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "[gray]%-6s %-56s %8s %8s %9s %7s %-9s %s[white]\n",
+		"PROTO", "TOPIC", "PUB", "CONSUMED", "BYTES", "RATE/s", "LAST", "CLIENTS")
+	if len(topics) == 0 {
+		builder.WriteString("[white]No MQTT, AMQP or Kafka messages seen yet.[white]\n")
+	}
+	for _, t := range topics {
+		rate := "-"
+		if span := t.Last.Sub(t.First).Seconds(); span >= 1 {
+			rate = strconv.FormatFloat(float64(t.Published+t.Consumed)/span, 'f', 1, 64)
+		}
+		clients := make([]string, len(t.Clients))
+		for i, c := range t.Clients {
+			clients[i] = c.String()
+		}
+		fmt.Fprintf(&builder, "[white]%-6s %-56s %8d %8d %9s %7s %-9s %s[white]\n",
+			t.Proto,
+			utils.TruncateString(utils.SanitizeForDisplay(t.Name), 56),
+			t.Published,
+			t.Consumed,
+			formatBytes(t.Bytes),
+			rate,
+			time.Since(t.Last).Round(time.Second).String()+" ago",
+			strings.Join(clients, ", "))
+	}
+	a.TopicView.SetText(builder.String())
+}
@@ -3,9 +3,11 @@ package ui
 import (
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/google/gopacket/pcap"
@@ -17,18 +19,51 @@ import (
 	"github.com/fe-dudu/netmon/internal/utils"
 )
 
-func NewApp(ifaces []pcap.Interface, handles []*pcap.Handle, filterIdx int) *types.App {
+const (
+	ifacePanel = "interfaces"
+	bpfPanel   = "bpf"
+)
+
+// panel is a page toggled by a key. refresh redraws it on every tick while
+// it is shown, and input marks panels that take text, which their key must
+// not close.
+type panel struct {
+	key     rune
+	open    func(*types.App)
+	refresh func(*types.App)
+	input   bool
+}
+
+var panels = map[string]panel{
+	ifacePanel:     {key: 'i', open: OpenInterfacePanel},
+	bpfPanel:       {key: 'b', open: OpenBPFPanel, input: true},
+	leasePanel:     {key: 'l', open: OpenLeasePanel, refresh: UpdateLeaseView},
+	flowPanel:      {key: 'f', open: OpenFlowPanel, refresh: UpdateFlowView},
+	discoveryPanel: {key: 'd', open: OpenDiscoveryPanel, refresh: UpdateDiscoveryView},
+	topicPanel:     {key: 'p', open: OpenTopicPanel, refresh: UpdateTopicView},
+}
+
+func NewApp(devices []pcap.Interface, autoIfaces []pcap.Interface, filterIdx int, captureBPF string) *types.App {
 
 	app := &types.App{
 		App:              tview.NewApplication(),
 		Packets:          make([]types.PacketInfo, 0),
 		CurrentFilterIdx: filterIdx,
+		CaptureBPF:       captureBPF,
+		DefaultBPF:       captureBPF,
 		IsExpandedMode:   false,
-		Ifaces:           ifaces,
-		Handles:          handles,
+		Devices:          devices,
+		AutoIfaces:       make(map[string]bool, len(autoIfaces)),
+		WantedIfaces:     make(map[string]bool),
+		CaptureErrors:    make(map[string]string),
+		Captures:         make(map[string]*types.Capture),
+		FrameCh:          make(chan types.Frame, 4096),
 		PacketCh:         make(chan types.PacketInfo, 1000),
 		StopCh:           make(chan struct{}),
 	}
+	for _, iface := range autoIfaces {
+		app.AutoIfaces[iface.Name] = true
+	}
 
 	app.PacketView = tview.NewTextView().
 		SetDynamicColors(true).
@@ -83,6 +118,14 @@ func NewApp(ifaces []pcap.Interface, handles []*pcap.Handle, filterIdx int) *typ
 		SetTitleAlign(tview.AlignLeft).
 		SetBackgroundColor(tcell.ColorBlack)
 
+	app.EventView = tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(false)
+	app.EventView.SetBorder(true).
+		SetBorderColor(tcell.ColorGray).
+		SetTitle("[gray]📡 Events[white]").
+		SetTitleAlign(tview.AlignLeft)
+
 	app.MainFlex = tview.NewFlex().
 		SetDirection(tview.FlexColumn).
 		AddItem(
@@ -95,11 +138,67 @@ func NewApp(ifaces []pcap.Interface, handles []*pcap.Handle, filterIdx int) *typ
 			tview.NewFlex().
 				SetDirection(tview.FlexRow).
 				AddItem(app.PacketView, 0, 1, true).
+				AddItem(app.EventView, 3, 0, false).
 				AddItem(app.SearchInput, 3, 0, false),
 			0, 1, true)
 
+	app.IfaceList = tview.NewList().
+		SetHighlightFullLine(true).
+		SetSelectedBackgroundColor(tcell.ColorYellow).
+		SetSelectedTextColor(tcell.ColorBlack).
+		SetSecondaryTextColor(tcell.ColorGray)
+	app.IfaceList.SetBorder(true).
+		SetBorderColor(tcell.ColorYellow).
+		SetTitle("[yellow]🔌 Interfaces [gray](Enter/Space toggle, ESC close)[white]").
+		SetTitleAlign(tview.AlignLeft)
+
+	app.BPFInput = tview.NewInputField().
+		SetLabel("bpf> ").
+		SetPlaceholder("e.g. host 10.1.2.3 and not port 22").
+		SetPlaceholderStyle(tcell.StyleDefault.Foreground(tcell.ColorGray).Background(tcell.ColorBlack)).
+		SetFieldBackgroundColor(tcell.ColorBlack).
+		SetDoneFunc(func(key tcell.Key) {
+			if key == tcell.KeyEnter {
+				ApplyCaptureFilter(app, app.BPFInput.GetText())
+			}
+		})
+	app.BPFInput.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyUp:
+			BrowseBPFHistory(app, -1)
+			return nil
+		case tcell.KeyDown:
+			BrowseBPFHistory(app, 1)
+			return nil
+		}
+		return event
+	})
+
+	app.BPFStatus = tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(true)
+
+	bpfBox := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(app.BPFInput, 1, 0, true).
+		AddItem(app.BPFStatus, 0, 1, false)
+	bpfBox.SetBorder(true).
+		SetBorderColor(tcell.ColorYellow).
+		SetTitle("[yellow]🧪 Capture filter (BPF) [gray](Enter apply, empty resets, ↑/↓ history, ESC close)[white]").
+		SetTitleAlign(tview.AlignLeft)
+
+	app.Pages = tview.NewPages().
+		AddPage("main", app.MainFlex, true, true).
+		AddPage(ifacePanel, Centered(app.IfaceList, 80, 20), true, false).
+		AddPage(bpfPanel, Centered(bpfBox, 90, 6), true, false).
+		AddPage(leasePanel, newLeaseView(app), true, false).
+		AddPage(flowPanel, newFlowView(app), true, false).
+		AddPage(discoveryPanel, newDiscoveryView(app), true, false).
+		AddPage(topicPanel, newTopicView(app), true, false)
+
 	UpdateFilterView(app)
 	UpdateModeView(app)
+	UpdateEventView(app)
 	SetupKeyBindings(app)
 
 	return app
@@ -133,10 +232,32 @@ func SetupKeyBindings(a *types.App) {
 			return event
 		}
 
+		if a.ActivePanel != "" {
+			if event.Key() == tcell.KeyEscape {
+				ClosePanel(a)
+				return nil
+			}
+			if a.ActivePanel == ifacePanel && event.Key() == tcell.KeyRune && event.Rune() == ' ' {
+				ToggleInterface(a, a.IfaceList.GetCurrentItem())
+				return nil
+			}
+			if p := panels[a.ActivePanel]; !p.input && event.Key() == tcell.KeyRune && unicode.ToLower(event.Rune()) == p.key {
+				ClosePanel(a)
+				return nil
+			}
+			return event
+		}
+
 		switch event.Key() {
 		case tcell.KeyEscape, tcell.KeyCtrlC:
 			Stop(a)
 			return nil
+		case tcell.KeyTab:
+			ChangeFilter(a, (a.CurrentFilterIdx+1)%len(types.ProtocolFilters))
+			return nil
+		case tcell.KeyBacktab:
+			ChangeFilter(a, (a.CurrentFilterIdx+len(types.ProtocolFilters)-1)%len(types.ProtocolFilters))
+			return nil
 		case tcell.KeyEnter:
 			a.IsSearchMode = true
 			a.App.SetFocus(a.SearchInput)
@@ -144,7 +265,7 @@ func SetupKeyBindings(a *types.App) {
 			return nil
 		case tcell.KeyRune:
 			switch event.Rune() {
-			case '1', '2', '3', '4', '5', '6', '7', '8':
+			case '1', '2', '3', '4', '5', '6', '7', '8', '9':
 				idx := int(event.Rune() - '1')
 				if idx < len(types.ProtocolFilters) {
 					ChangeFilter(a, idx)
@@ -155,39 +276,235 @@ func SetupKeyBindings(a *types.App) {
 				UpdateModeView(a)
 				UpdateDisplay(a)
 				return nil
+			case 't', 'T':
+				a.InnerView = !a.InnerView
+				UpdateFilterView(a)
+				UpdateDisplay(a)
+				return nil
+			}
+			for _, p := range panels {
+				if unicode.ToLower(event.Rune()) == p.key {
+					p.open(a)
+					return nil
+				}
 			}
 		}
 		return event
 	})
 }
 
+func Centered(p tview.Primitive, width, height int) tview.Primitive {
+	return tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().
+			SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(p, height, 1, true).
+			AddItem(nil, 0, 1, false), width, 1, true).
+		AddItem(nil, 0, 1, false)
+}
+
+func OpenPanel(a *types.App, name string, focus tview.Primitive) {
+	a.ActivePanel = name
+	a.Pages.ShowPage(name)
+	a.App.SetFocus(focus)
+}
+
+func ClosePanel(a *types.App) {
+	if a.ActivePanel == "" {
+		return
+	}
+	a.Pages.HidePage(a.ActivePanel)
+	a.ActivePanel = ""
+	a.App.SetFocus(a.PacketView)
+}
+
+func OpenInterfacePanel(a *types.App) {
+	UpdateIfaceView(a)
+	OpenPanel(a, ifacePanel, a.IfaceList)
+}
+
+func UpdateIfaceView(a *types.App) {
+	current := a.IfaceList.GetCurrentItem()
+	a.IfaceList.Clear()
+
+	a.CapturesMutex.Lock()
+	for i, dev := range a.Devices {
+		idx := i
+		mark, color := "[ ]", "white"
+		if _, ok := a.Captures[dev.Name]; ok {
+			mark, color = "[x]", "green"
+		}
+		main := fmt.Sprintf("[%s]%s[white] %s", color, tview.Escape(mark), dev.Name)
+		a.IfaceList.AddItem(main, DescribeInterface(a, dev), 0, func() {
+			ToggleInterface(a, idx)
+		})
+	}
+	a.CapturesMutex.Unlock()
+
+	if current >= 0 && current < a.IfaceList.GetItemCount() {
+		a.IfaceList.SetCurrentItem(current)
+	}
+}
+
+func DescribeInterface(a *types.App, dev pcap.Interface) string {
+	parts := make([]string, 0, 3)
+	if a.AutoIfaces[dev.Name] {
+		parts = append(parts, "active")
+	} else {
+		parts = append(parts, "inactive")
+	}
+	for _, addr := range dev.Addresses {
+		if addr.IP != nil {
+			parts = append(parts, addr.IP.String())
+			break
+		}
+	}
+	if dev.Description != "" {
+		parts = append(parts, dev.Description)
+	}
+	if c, ok := a.Captures[dev.Name]; ok {
+		if st, err := c.Source.Stats(); err == nil {
+			parts = append(parts, fmt.Sprintf("rx %d drop %d", st.Received, st.Dropped+st.IfDropped))
+		}
+		backlog := make([]uint64, len(c.Backlog))
+		for i := range c.Backlog {
+			backlog[i] = c.Backlog[i].Load()
+		}
+		if rs, ok := c.Source.(types.RingSource); ok && len(backlog) > 1 {
+			if rings, err := rs.RingStats(); err == nil {
+				drops := make([]uint64, len(rings))
+				for i, st := range rings {
+					drops[i] = st.Dropped
+				}
+				parts = append(parts, "ring drop "+joinCounts(drops))
+			}
+			parts = append(parts, "ring backlog drop "+joinCounts(backlog))
+		} else if len(backlog) == 1 && backlog[0] > 0 {
+			parts = append(parts, fmt.Sprintf("parser backlog drop %d", backlog[0]))
+		}
+	}
+	return "    " + utils.SanitizeForDisplay(strings.Join(parts, " · "))
+}
+
+func joinCounts(counts []uint64) string {
+	s := make([]string, len(counts))
+	for i, n := range counts {
+		s[i] = strconv.FormatUint(n, 10)
+	}
+	return strings.Join(s, "/")
+}
+
+func ToggleInterface(a *types.App, idx int) {
+	if a.Offline {
+		// Files and the demo source can't be reopened as pcap devices.
+		network.RecordEvent(a, "", "offline sources cannot be toggled")
+		return
+	}
+	a.CapturesMutex.Lock()
+	if idx < 0 || idx >= len(a.Devices) {
+		a.CapturesMutex.Unlock()
+		return
+	}
+	dev := a.Devices[idx]
+	_, capturing := a.Captures[dev.Name]
+	a.CapturesMutex.Unlock()
+
+	if capturing {
+		network.StopInterface(a, dev.Name)
+		network.RecordEvent(a, dev.Name, "capture stopped by user")
+	} else if a.Unprivileged {
+		network.RecordEvent(a, dev.Name, "cannot start capture: privileges were dropped with --user")
+	} else if err := network.StartInterface(a, dev); err != nil {
+		network.RecordEvent(a, dev.Name, "open failed: "+err.Error())
+	} else {
+		network.RecordEvent(a, dev.Name, "capture started by user")
+	}
+
+	UpdateIfaceView(a)
+}
+
 func ChangeFilter(a *types.App, idx int) {
 	if idx == a.CurrentFilterIdx {
 		return
 	}
 
 	a.CurrentFilterIdx = idx
-	filter := types.ProtocolFilters[idx]
 
-	for i, handle := range a.Handles {
-		if handle == nil {
-			continue
-		}
-		if err := handle.SetBPFFilter(filter.BPF); err != nil {
-			log.Printf("Failed to change filter on %s: %v", a.Ifaces[i].Name, err)
-		}
+	UpdateFilterView(a)
+	UpdateDisplay(a)
+}
+
+func OpenBPFPanel(a *types.App) {
+	a.BPFInput.SetText(a.CaptureBPF)
+	a.BPFHistoryIdx = len(a.BPFHistory)
+	current := a.CaptureBPF
+	if current == "" {
+		current = "(all traffic)"
 	}
+	a.BPFStatus.SetText(fmt.Sprintf("[gray]Current: %s[white]", tview.Escape(current)))
+	OpenPanel(a, bpfPanel, a.BPFInput)
+}
+
+func ApplyCaptureFilter(a *types.App, expr string) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		expr = a.DefaultBPF
+	}
+
+	if err := network.CompileFilter(a, expr); err != nil {
+		a.BPFStatus.SetText(fmt.Sprintf("[red]%s[white]", tview.Escape(err.Error())))
+		return
+	}
+
+	network.ApplyFilter(a, expr)
+	AddBPFHistory(a, expr)
 
+	ClosePanel(a)
 	UpdateFilterView(a)
 	UpdateDisplay(a)
 }
 
+func AddBPFHistory(a *types.App, expr string) {
+	for i, h := range a.BPFHistory {
+		if h == expr {
+			a.BPFHistory = append(a.BPFHistory[:i], a.BPFHistory[i+1:]...)
+			break
+		}
+	}
+	a.BPFHistory = append(a.BPFHistory, expr)
+	if len(a.BPFHistory) > 20 {
+		a.BPFHistory = a.BPFHistory[len(a.BPFHistory)-20:]
+	}
+	a.BPFHistoryIdx = len(a.BPFHistory)
+}
+
+func BrowseBPFHistory(a *types.App, delta int) {
+	if len(a.BPFHistory) == 0 {
+		return
+	}
+	idx := a.BPFHistoryIdx + delta
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(a.BPFHistory) {
+		a.BPFHistoryIdx = len(a.BPFHistory)
+		a.BPFInput.SetText("")
+		return
+	}
+	a.BPFHistoryIdx = idx
+	a.BPFInput.SetText(a.BPFHistory[idx])
+}
+
 func UpdateFilterView(a *types.App) {
 	var builder strings.Builder
 
 	for i, filter := range types.ProtocolFilters {
 		num := i + 1
 		text := fmt.Sprintf("[%d] %s", num, filter.Label)
+		if num > 9 {
+			text = fmt.Sprintf("[+] %s", filter.Label)
+		}
 
 		if i == a.CurrentFilterIdx {
 			fmt.Fprintf(&builder, "[black:yellow:bi]%-12s[black:white]", text)
@@ -196,6 +513,20 @@ func UpdateFilterView(a *types.App) {
 		}
 	}
 
+	bpfText := tview.Escape("[b] BPF")
+	if a.CaptureBPF != a.DefaultBPF {
+		fmt.Fprintf(&builder, "[black:yellow:bi]%-13s[black:white]", bpfText)
+	} else {
+		fmt.Fprintf(&builder, "[white:black]%-13s[white]", bpfText)
+	}
+
+	innerText := tview.Escape("[t] Inner")
+	if a.InnerView {
+		fmt.Fprintf(&builder, "[black:yellow:bi]%-13s[black:white]", innerText)
+	} else {
+		fmt.Fprintf(&builder, "[white:black]%-13s[white]", innerText)
+	}
+
 	if a.IsSearchMode {
 		a.SearchInput.SetTitle("[red]🔍 Search [red](ESC to close)[white]")
 	} else {
@@ -223,7 +554,10 @@ func UpdateDisplay(a *types.App) {
 
 	var builder strings.Builder
 	count := 0
-	maxDisplay := 50000
+	maxDisplay := a.MaxPackets
+	if maxDisplay <= 0 {
+		maxDisplay = 50000
+	}
 
 	if len(a.Packets) == 0 {
 		fmt.Fprintf(&builder, "[white]Waiting for packets...[white]\n")
@@ -232,6 +566,9 @@ func UpdateDisplay(a *types.App) {
 
 	for i := len(a.Packets) - 1; i >= 0 && count < maxDisplay; i-- {
 		pkt := a.Packets[i]
+		if a.InnerView {
+			pkt = Innermost(pkt)
+		}
 
 		if !packet.MatchesFilter(a.CurrentFilterIdx, pkt) {
 			continue
@@ -243,15 +580,21 @@ func UpdateDisplay(a *types.App) {
 		}
 
 		protoColor := GetProtoColor(pkt.Proto)
+		if c, ok := a.ProtoColors[pkt.Proto]; ok {
+			protoColor = c
+		}
 
-		safeSrc := utils.SanitizeForDisplay(pkt.Src)
-		safeDst := utils.SanitizeForDisplay(pkt.Dst)
+		safeSrc := utils.SanitizeForDisplay(pkt.Src())
+		safeDst := utils.SanitizeForDisplay(pkt.Dst())
 		safeDetail := utils.SanitizeForDisplay(pkt.Detail)
 
 		detailStr := ""
 		if safeDetail != "" {
 			detailStr = fmt.Sprintf(" [yellow]%s[white]", safeDetail)
 		}
+		if pkt.Tunnel != "" {
+			detailStr += " [gray]via " + utils.SanitizeForDisplay(pkt.Tunnel) + "[white]"
+		}
 
 		var srcDisplay, dstDisplay string
 		var srcWidth, dstWidth int
@@ -263,6 +606,7 @@ func UpdateDisplay(a *types.App) {
 			srcWidth = 50
 			dstWidth = 50
 			timeFormat = "15:04:05.000"
+			detailStr = " [gray]" + expandedInfo(pkt) + "[white]" + detailStr
 		} else {
 			srcDisplay = HighlightSearch(utils.TruncateString(safeSrc, 35), a.SearchIP, "white")
 			dstDisplay = HighlightSearch(utils.TruncateString(safeDst, 35), a.SearchIP, "white")
@@ -281,6 +625,40 @@ func UpdateDisplay(a *types.App) {
 	a.PacketView.SetText(builder.String())
 }
 
+func UpdateEventView(a *types.App) {
+	a.EventsMutex.Lock()
+	defer a.EventsMutex.Unlock()
+
+	if len(a.Events) == 0 {
+		a.EventView.SetText("[gray]No interface events yet[white]")
+		return
+	}
+
+	var builder strings.Builder
+	start := len(a.Events) - 1
+	for i := start; i >= 0 && i > start-3; i-- {
+		ev := a.Events[i]
+		if i != start {
+			builder.WriteString(" [gray]│[white] ")
+		}
+		fmt.Fprintf(&builder, "[gray]%s[white] %s %s",
+			ev.Timestamp.Format("15:04:05"), utils.SanitizeForDisplay(ev.Iface), colorizeEvent(ev.Message))
+	}
+	a.EventView.SetText(builder.String())
+}
+
+func colorizeEvent(msg string) string {
+	safe := utils.SanitizeForDisplay(msg)
+	switch {
+	case strings.Contains(msg, "up"), strings.Contains(msg, "started"):
+		return "[green]" + safe + "[white]"
+	case strings.Contains(msg, "down"), strings.Contains(msg, "stopped"), strings.Contains(msg, "failed"):
+		return "[red]" + safe + "[white]"
+	default:
+		return safe
+	}
+}
+
 func HighlightSearch(text, search, defaultColor string) string {
 	terms := ParseSearchTerms(search)
 	if len(terms) == 0 {
@@ -325,9 +703,34 @@ func ParseSearchTerms(search string) []string {
 	return terms
 }
 
+func expandedInfo(pkt types.PacketInfo) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "len=%d", pkt.Length)
+	if pkt.IPVersion != 0 {
+		fmt.Fprintf(&b, " ttl=%d", pkt.TTL)
+		if !pkt.SrcMAC.IsZero() {
+			fmt.Fprintf(&b, " mac=%s>%s", pkt.SrcMAC, pkt.DstMAC)
+		}
+	}
+	if pkt.OuterVLAN != 0 {
+		fmt.Fprintf(&b, " vlan=%d.%d", pkt.OuterVLAN, pkt.VLAN)
+	} else if pkt.VLAN != 0 {
+		fmt.Fprintf(&b, " vlan=%d", pkt.VLAN)
+	}
+	return b.String()
+}
+
+// Innermost returns the packet carried by the deepest tunnel, or pkt itself.
+func Innermost(pkt types.PacketInfo) types.PacketInfo {
+	for pkt.Inner != nil {
+		pkt = *pkt.Inner
+	}
+	return pkt
+}
+
 func MatchesAnySearchTerm(pkt types.PacketInfo, terms []string) bool {
-	srcLower := strings.ToLower(pkt.Src)
-	dstLower := strings.ToLower(pkt.Dst)
+	srcLower := strings.ToLower(pkt.Src())
+	dstLower := strings.ToLower(pkt.Dst())
 	detailLower := strings.ToLower(pkt.Detail)
 
 	return FirstMatchingTerm(srcLower, terms) != "" ||
@@ -345,23 +748,26 @@ func FirstMatchingTerm(text string, terms []string) string {
 }
 
 func GetProtoColor(proto string) string {
+	if d := packet.Lookup(proto); d != nil && d.Color != "" {
+		return d.Color
+	}
 	switch proto {
-	case "DNS":
-		return "green"
-	case "HTTP":
-		return "blue"
-	case "TLS":
-		return "yellow"
-	case "HTTPS":
-		return "orange"
 	case "TCP":
 		return "darkgreen"
 	case "UDP":
 		return "blue"
-	case "QUIC":
-		return "purple"
 	case "ICMP", "ICMPv6":
 		return "red"
+	case "ARP":
+		return "aqua"
+	case "LLDP", "CDP", "STP":
+		return "fuchsia"
+	case "ETH", "LLC":
+		return "gray"
+	case "gRPC":
+		return "blue"
+	case "VXLAN", "GENEVE", "GRE", "ERSPAN", "IPIP":
+		return "orange"
 	default:
 		return "white"
 	}
@@ -369,6 +775,9 @@ func GetProtoColor(proto string) string {
 
 func Run(a *types.App) {
 	network.StartPacketCapture(a)
+	if !a.Offline {
+		network.WatchInterfaces(a)
+	}
 
 	if a.Wg == nil {
 		a.Wg = &sync.WaitGroup{}
@@ -379,25 +788,45 @@ func Run(a *types.App) {
 		ticker := time.NewTicker(100 * time.Millisecond)
 		defer ticker.Stop()
 
+		lastEvents := 0
 		for {
 			select {
 			case <-a.StopCh:
 				return
 			case <-ticker.C:
 				UpdateDisplay(a)
+				a.EventsMutex.Lock()
+				eventCount := len(a.Events)
+				a.EventsMutex.Unlock()
+				eventsChanged := eventCount != lastEvents
+				if eventsChanged {
+					lastEvents = eventCount
+					UpdateEventView(a)
+				}
+				// ActivePanel belongs to the UI goroutine, so check it there.
+				a.App.QueueUpdateDraw(func() {
+					if a.ActivePanel == ifacePanel && eventsChanged {
+						UpdateIfaceView(a)
+					}
+					if p := panels[a.ActivePanel]; p.refresh != nil {
+						p.refresh(a)
+					}
+				})
 			}
 		}
 	}()
 
 	a.App.EnableMouse(true)
 
-	if err := a.App.SetRoot(a.MainFlex, true).SetFocus(a.PacketView).Run(); err != nil {
+	if err := a.App.SetRoot(a.Pages, true).SetFocus(a.PacketView).Run(); err != nil {
 		log.Fatalf("Application error: %v", err)
 	}
 }
 
 func Stop(a *types.App) {
 	close(a.StopCh)
+	// Readers block in ReadPacketData until their source is closed.
+	network.CloseCaptures(a)
 	if a.Wg != nil {
 		a.Wg.Wait()
 	}
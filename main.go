@@ -2,13 +2,18 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
-	"os"
-	"runtime"
+	"strings"
+	"time"
 
+	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcap"
 
+	"github.com/fe-dudu/netmon/internal/config"
 	"github.com/fe-dudu/netmon/internal/network"
+	"github.com/fe-dudu/netmon/internal/output"
+	"github.com/fe-dudu/netmon/internal/privilege"
 	"github.com/fe-dudu/netmon/internal/types"
 	"github.com/fe-dudu/netmon/internal/ui"
 )
@@ -18,55 +23,179 @@ func main() {
 
 	includeLoopback := flag.Bool("include-loopback", false, "include loopback interfaces such as lo0")
 	includeVPN := flag.Bool("include-vpn", false, "include VPN and tunnel interfaces such as utun/tun/wg")
+	ifaceNames := flag.String("i", "", "comma-separated interfaces to capture on (e.g. eth0,wlan0)")
+	captureFilter := flag.String("capture-filter", types.DefaultCaptureBPF, "BPF expression applied at capture time; protocol tabs only filter the view")
+	backend := flag.String("backend", network.BackendPcap, "capture backend: pcap or afpacket (Linux TPACKET_V3 ring)")
+	fanout := flag.Int("fanout", 0, "afpacket: number of fanout sockets per interface (e.g. number of CPUs)")
+	ringMB := flag.Int("ring-mb", 64, "afpacket: ring buffer size per interface in MiB")
+	workers := flag.Int("workers", 0, "number of packet parsing workers (default: number of CPUs)")
+	readFile := flag.String("r", "", "read packets from a pcap/pcapng file instead of capturing live")
+	demo := flag.Bool("demo", false, "run with synthetic in-memory traffic (no root or network interface needed)")
+	configPath := flag.String("config", "", "path to the config file (default ~/.config/netmon/config.toml)")
+	profile := flag.String("profile", "", "named profile from the config file")
+	redactQueries := flag.Bool("redact-queries", false, "replace literals in decoded database queries and Redis keys and values with ?")
+	dropUser := flag.String("user", "", "switch to this user after opening capture handles (when started as root); name the current user to drop capabilities instead")
 	flag.Parse()
 
-	if runtime.GOOS != "windows" {
-		if os.Geteuid() != 0 {
-			log.Fatal("This program requires root privileges for packet capture.\nPlease run with sudo or as root.")
-		}
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("config: %v", err)
 	}
-
-	devices, err := pcap.FindAllDevs()
+	settings, err := cfg.Resolve(*profile)
 	if err != nil {
-		log.Fatalf("pcap: failed to list interfaces: %v", err)
+		log.Fatalf("config: %v", err)
 	}
-	if len(devices) == 0 {
-		log.Fatalf("pcap: no interfaces found (need capture permission?)")
+
+	if err := settings.ApplyFlags(flag.CommandLine); err != nil {
+		log.Fatalf("config: %v", err)
+	}
+
+	for _, tab := range settings.Tabs {
+		if tab.Label == "" {
+			log.Fatalf("config: custom tab is missing a label")
+		}
+		types.ProtocolFilters = append(types.ProtocolFilters, types.FilterChoice{
+			Label:  tab.Label,
+			Desc:   tab.Desc,
+			Protos: tab.Protos,
+			Ports:  tab.Ports,
+			Hosts:  tab.Hosts,
+		})
 	}
 
 	filterIdx := 0
-	activeIfaces := network.ActiveInterfaces(devices, network.InterfaceOptions{
+	if settings.DefaultTab != nil {
+		filterIdx = -1
+		for i, f := range types.ProtocolFilters {
+			if strings.EqualFold(f.Label, *settings.DefaultTab) {
+				filterIdx = i
+				break
+			}
+		}
+		if filterIdx == -1 {
+			log.Fatalf("config: unknown default_tab %q", *settings.DefaultTab)
+		}
+	}
+
+	expanded := false
+	if settings.DisplayMode != nil {
+		switch strings.ToLower(*settings.DisplayMode) {
+		case "expanded":
+			expanded = true
+		case "compact":
+		default:
+			log.Fatalf("config: display_mode must be \"compact\" or \"expanded\", got %q", *settings.DisplayMode)
+		}
+	}
+
+	ifaceOpts := types.InterfaceOptions{
 		IncludeLoopback: *includeLoopback,
 		IncludeVPN:      *includeVPN,
-	})
-	if len(activeIfaces) == 0 {
-		log.Fatalf("pcap: no active interfaces detected")
 	}
 
-	handles := make([]*pcap.Handle, 0, len(activeIfaces))
-	for _, iface := range activeIfaces {
-		handle, err := network.OpenHandle(iface.Name)
+	offline := *readFile != "" || *demo
+	var devices, autoIfaces, activeIfaces []pcap.Interface
+	if !offline {
+		if err := privilege.CanCapture(); err != nil {
+			log.Fatalf("This program requires packet capture privileges: %v", err)
+		}
+		devices, autoIfaces, activeIfaces = discoverInterfaces(ifaceOpts, *ifaceNames)
+	}
+
+	app := ui.NewApp(devices, autoIfaces, filterIdx, *captureFilter)
+	app.IfaceOpts = ifaceOpts
+	app.AutoSelect = *ifaceNames == "" && !offline
+	app.Offline = offline
+	app.CaptureOpts = types.CaptureOptions{
+		Backend: *backend,
+		Fanout:  *fanout,
+		RingMB:  *ringMB,
+	}
+	app.Workers = *workers
+	app.RedactQueries = *redactQueries
+	app.IsExpandedMode = expanded
+	app.ProtoColors = settings.Colors
+	if settings.BufferSize != nil {
+		app.MaxPackets = *settings.BufferSize
+	}
+	ui.UpdateModeView(app)
+
+	if offline {
+		src, err := openOfflineSource(*readFile)
 		if err != nil {
+			log.Fatalf("pcap: %v", err)
+		}
+		if err := network.AddSource(app, src); err != nil {
+			src.Close()
+			log.Fatalf("pcap: %s: %v", src.Name(), err)
+		}
+	}
+	for _, iface := range activeIfaces {
+		if err := network.StartInterface(app, iface); err != nil {
+			network.CloseCaptures(app)
 			log.Fatalf("pcap: failed to open %s: %v", iface.Name, err)
 		}
-		handles = append(handles, handle)
+	}
+	defer network.CloseCaptures(app)
+
+	if *dropUser != "" {
+		if err := privilege.Drop(*dropUser); err != nil {
+			network.CloseCaptures(app)
+			log.Fatalf("privilege: failed to switch to %s: %v", *dropUser, err)
+		}
+		app.Unprivileged = true
+		network.RecordEvent(app, "", "running as "+*dropUser)
+	}
+
+	// Output files are created after the drop so they belong to the user
+	// netmon runs as.
+	for _, out := range settings.Outputs {
+		sink, err := output.Open(out.Type, out.Path)
+		if err != nil {
+			log.Fatalf("config: %v", err)
+		}
+		app.Sinks = append(app.Sinks, sink)
 	}
 	defer func() {
-		for _, h := range handles {
-			if h != nil {
-				h.Close()
-			}
+		for _, sink := range app.Sinks {
+			sink.Close()
 		}
 	}()
 
-	filter := types.ProtocolFilters[filterIdx]
-	for i, handle := range handles {
-		if err := handle.SetBPFFilter(filter.BPF); err != nil {
-			log.Fatalf("pcap: failed to set filter %q on %s: %v", filter.BPF, activeIfaces[i].Name, err)
-		}
+	ui.Run(app)
+}
+
+func discoverInterfaces(opts types.InterfaceOptions, names string) ([]pcap.Interface, []pcap.Interface, []pcap.Interface) {
+	devices, err := pcap.FindAllDevs()
+	if err != nil {
+		log.Fatalf("pcap: failed to list interfaces: %v", err)
+	}
+	if len(devices) == 0 {
+		log.Fatalf("pcap: no interfaces found (need capture permission?)")
 	}
 
-	app := ui.NewApp(activeIfaces, handles, filterIdx)
+	autoIfaces := network.ActiveInterfaces(devices, opts)
 
-	ui.Run(app)
+	activeIfaces := autoIfaces
+	if names != "" {
+		activeIfaces, err = network.SelectInterfaces(devices, strings.Split(names, ","))
+		if err != nil {
+			log.Fatalf("pcap: %v", err)
+		}
+	}
+	if len(activeIfaces) == 0 {
+		log.Fatalf("pcap: no active interfaces detected")
+	}
+	return devices, autoIfaces, activeIfaces
+}
+
+func openOfflineSource(path string) (network.PacketSource, error) {
+	if path == "" {
+		frames, err := network.DemoFrames()
+		if err != nil {
+			return nil, fmt.Errorf("demo capture: %w", err)
+		}
+		return network.NewSyntheticSource("demo", layers.LinkTypeEthernet, frames, 50*time.Millisecond, true), nil
+	}
+	return network.OpenFile(path)
 }